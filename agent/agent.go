@@ -1,8 +1,6 @@
 package agent
 
 import (
-	"time"
-
 	"github.com/adevinta/vulcan-check-sdk/config"
 	vulcanreport "github.com/adevinta/vulcan-report"
 )
@@ -16,13 +14,43 @@ const (
 	StatusAborted = "ABORTED"
 	// StatusFailed represents the state for a check when has failed it's execution
 	StatusFailed = "FAILED"
+	// StatusInconclusive represents the state for a check when it could not
+	// determine whether the target is vulnerable
+	StatusInconclusive = "INCONCLUSIVE"
 )
 
 // State holds all the data that must be sent to the agent to communicate check status and report.
+// When the checker sets the final status of the check before returning, e.g.:
+// calling state.State.Finish, the agent receives a second state with the same
+// final status once the checker returns, containing the end time of the check
+// and the complete report, so the agents must accept a final status being
+// sent more than once and keep the last state received.
 type State struct {
 	Status   string              `json:"status,omitempty"`
 	Progress float32             `json:"progress,omitempty"`
 	Report   vulcanreport.Report `json:"report,omitempty"`
+	// Page is only set when the check sends its report in pages. In that case
+	// the report of the state only contains the vulnerabilities of the page.
+	Page *Page `json:"page,omitempty"`
+}
+
+// Page describes the vulnerabilities contained in the report of a state sent
+// in paged mode, where each push only contains the vulnerabilities added to
+// the report since the previous one, split in pages of a maximum size. The
+// agent assembles the full list of vulnerabilities by placing the ones of each
+// page at its offset.
+type Page struct {
+	// Offset is the position, in the full list of vulnerabilities of the
+	// report, of the first vulnerability of the page. The final state can
+	// start again at offset 0 when sending a previous page failed, so the
+	// vulnerabilities already received at an offset must be replaced.
+	Offset int `json:"offset"`
+	// More is the continuation marker, it's true when the state has been
+	// split and more pages of it follow.
+	More bool `json:"more,omitempty"`
+	// Complete is true in the last page of the last state of the check, that
+	// is, when the agent has received all the vulnerabilities of the report.
+	Complete bool `json:"complete,omitempty"`
 }
 
 // NewReportFromConfig creates a new report initializing the fields that should be extracted from the config.
@@ -30,7 +58,7 @@ func NewReportFromConfig(c config.CheckConfig) vulcanreport.Report {
 	return vulcanreport.Report{
 		CheckData: vulcanreport.CheckData{
 			CheckID:          c.CheckID,
-			StartTime:        time.Now(),
+			StartTime:        Now(),
 			ChecktypeName:    c.CheckTypeName,
 			ChecktypeVersion: c.CheckTypeVersion,
 			Options:          c.Opts,
@@ -0,0 +1,36 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// CanonicalJSON returns a deterministic JSON encoding of the given state,
+// suitable for hashing or signing it. The keys of the objects are sorted, no
+// insignificant whitespace is added, the HTML characters are not escaped and
+// the times of the report are encoded in UTC, so two semantically equal states
+// are always encoded to the same bytes.
+func CanonicalJSON(s State) ([]byte, error) {
+	s.Report.StartTime = s.Report.StartTime.UTC()
+	s.Report.EndTime = s.Report.EndTime.UTC()
+	content, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	// Decoding the state into generic values and encoding them again sorts
+	// the keys of all the objects, including the ones of the structs.
+	d := json.NewDecoder(bytes.NewReader(content))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(v); err != nil {
+		return nil, err
+	}
+	// Remove the trailing new line added by the encoder.
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
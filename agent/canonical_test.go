@@ -0,0 +1,59 @@
+package agent
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	vulcanreport "github.com/adevinta/vulcan-report"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	newState := func(loc *time.Location, rows ...map[string]string) State {
+		return State{
+			Status:   StatusFinished,
+			Progress: 1,
+			Report: vulcanreport.Report{
+				CheckData: vulcanreport.CheckData{
+					CheckID:   "id",
+					Target:    "<www.example.com>",
+					StartTime: start.In(loc),
+				},
+				ResultData: vulcanreport.ResultData{
+					Vulnerabilities: []vulcanreport.Vulnerability{
+						{
+							Summary: "vuln",
+							Resources: []vulcanreport.ResourcesGroup{
+								{Header: []string{"a", "b"}, Rows: rows},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+	rowA := make(map[string]string)
+	rowA["a"] = "1"
+	rowA["b"] = "2"
+	rowB := make(map[string]string)
+	rowB["b"] = "2"
+	rowB["a"] = "1"
+	a, err := CanonicalJSON(newState(time.UTC, rowA))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := CanonicalJSON(newState(time.FixedZone("UTC+2", 2*60*60), rowB))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a, b) {
+		t.Errorf("canonical encodings differ:\n%s\n%s", a, b)
+	}
+	if bytes.Contains(a, []byte(" ")) || bytes.HasSuffix(a, []byte("\n")) {
+		t.Errorf("canonical encoding contains insignificant whitespace: %s", a)
+	}
+	if !bytes.Contains(a, []byte(`"progress":1,"report":{`)) {
+		t.Errorf("canonical encoding keys are not sorted: %s", a)
+	}
+}
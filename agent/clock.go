@@ -0,0 +1,43 @@
+package agent
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the current time to the sdk. It allows to replace the time
+// set in the reports, e.g.: to assert exact times in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+// RealClock is the Clock that returns the current local time.
+type RealClock struct{}
+
+// Now returns the current local time.
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+var (
+	clockMu sync.RWMutex
+	clock   Clock = RealClock{}
+)
+
+// SetClock sets the clock used by the sdk to set the start and end times of
+// the reports. A nil clock restores the RealClock.
+func SetClock(c Clock) {
+	if c == nil {
+		c = RealClock{}
+	}
+	clockMu.Lock()
+	defer clockMu.Unlock()
+	clock = c
+}
+
+// Now returns the current time according to the clock set with SetClock.
+func Now() time.Time {
+	clockMu.RLock()
+	defer clockMu.RUnlock()
+	return clock.Now()
+}
@@ -10,6 +10,9 @@ import (
 
 	"github.com/adevinta/vulcan-check-sdk/agent"
 	"github.com/adevinta/vulcan-check-sdk/config"
+	"github.com/adevinta/vulcan-check-sdk/helpers"
+	"github.com/adevinta/vulcan-check-sdk/helpers/ratelimit"
+	"github.com/adevinta/vulcan-check-sdk/helpers/report"
 	"github.com/adevinta/vulcan-check-sdk/internal/local"
 	"github.com/adevinta/vulcan-check-sdk/internal/logging"
 	"github.com/adevinta/vulcan-check-sdk/internal/push"
@@ -19,11 +22,18 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// stdinTarget is the value of the r flag that makes the check read the targets
+// from the standard input.
+const stdinTarget = "-"
+
 var (
 	testMode     bool
 	runTarget    string
 	options      string
 	json         bool
+	jsonLines    bool
+	greenbone    bool
+	teeStates    bool
 	cachedConfig *config.Config
 
 	// VoidCheckerCleanUp defines a clean up function that does nothing this is usefull
@@ -37,9 +47,12 @@ func mustParseFlags() {
 		set = flag.NewFlagSet("", flag.ExitOnError)
 	}
 	set.BoolVar(&testMode, "t", false, "executes a check in test mode locally")
-	set.StringVar(&runTarget, "r", "", "executes a check from the command line using the target specified in this flag")
-	set.StringVar(&options, "o", "", "specifies the options to pass to the check, applies only when using the r flag")
+	set.StringVar(&runTarget, "r", "", "executes a check from the command line using the target specified in this flag, or the targets read from the standard input, one per line, if the value is -")
+	set.StringVar(&options, "o", "", "specifies the options to pass to the check, or @path to read them from a file, applies only when using the r flag")
 	set.BoolVar(&json, "j", false, "sets the output format to json, applies only when using the r flag")
+	set.BoolVar(&jsonLines, "jl", false, "writes each vulnerability as a json document in its own line, followed by a line with the rest of the result, applies only when using the j flag")
+	set.BoolVar(&greenbone, "gb", false, "sets the output format to a Greenbone (OpenVAS) xml report, applies only when using the r flag")
+	set.BoolVar(&teeStates, "tee", false, "writes every state sent to the agent to the standard output as a json document per line, applies only in push mode")
 	_ = set.Parse(os.Args[1:]) // nolint
 }
 
@@ -49,6 +62,15 @@ type Check interface {
 	Shutdown() error
 }
 
+// Cancelable defines the methods of the checks that, apart from reacting to
+// the SIGINT and SIGTERM signals, can be aborted programmatically. The checks
+// created to be run in push mode implement it.
+type Cancelable interface {
+	Cancel() error
+}
+
+var _ Cancelable = (*push.Check)(nil)
+
 // Checker defines the shape a checker must have in order to be executed as vulcan-check.
 type Checker interface {
 	Run(ctx context.Context, target string, opts string, state state.State) error
@@ -71,8 +93,38 @@ func (handler CheckerHandleCleanUp) CleanUp(ctx context.Context, target string,
 	(handler(ctx, target, opts))
 }
 
+// CheckOption defines an option of the checks created with NewCheck.
+type CheckOption func(o *checkOptions)
+
+// checkOptions contains the settings of a check modified by the options.
+type checkOptions struct {
+	conf *config.Config
+	// middlewares wrap the checker in the same order they are added.
+	middlewares []Middleware
+}
+
+// Active declares the check as active, that is, as a check that performs
+// probes that can modify the state of the targets, e.g.: login attempts with
+// default credentials. The active checks are recorded as such in the report and
+// refuse to run against the target, finishing in the inconclusive status, when
+// the config disallows active checks.
+func Active() CheckOption {
+	return func(o *checkOptions) {
+		o.conf.ActiveCheck = true
+	}
+}
+
+// WithRetryPolicy makes the check re-invoke the Run method of the checker
+// when it fails with an error the given policy considers retryable. See the
+// Retry middleware for the details.
+func WithRetryPolicy(p RetryPolicy) CheckOption {
+	return func(o *checkOptions) {
+		o.middlewares = append(o.middlewares, Retry(p))
+	}
+}
+
 // NewCheckFromHandlerWithCleanUp creates a new check given a checker run handler.
-func NewCheckFromHandlerWithCleanUp(name string, run CheckerHandleRun, cleanUp CheckerHandleCleanUp) Check {
+func NewCheckFromHandlerWithCleanUp(name string, run CheckerHandleRun, cleanUp CheckerHandleCleanUp, opts ...CheckOption) Check {
 	checkerAdapter := struct {
 		CheckerHandleRun
 		CheckerHandleCleanUp
@@ -80,11 +132,11 @@ func NewCheckFromHandlerWithCleanUp(name string, run CheckerHandleRun, cleanUp C
 		run,
 		cleanUp,
 	}
-	return NewCheck(name, checkerAdapter)
+	return NewCheck(name, checkerAdapter, opts...)
 }
 
 // NewCheckFromHandler creates a new check given a checker run handler.
-func NewCheckFromHandler(name string, run CheckerHandleRun) Check {
+func NewCheckFromHandler(name string, run CheckerHandleRun, opts ...CheckOption) Check {
 	checkerAdapter := struct {
 		CheckerHandleRun
 		CheckerHandleCleanUp
@@ -92,21 +144,39 @@ func NewCheckFromHandler(name string, run CheckerHandleRun) Check {
 		run,
 		VoidCheckerCleanUp,
 	}
-	return NewCheck(name, checkerAdapter)
+	return NewCheck(name, checkerAdapter, opts...)
 }
 
 // NewCheck creates a check given a Checker.
-func NewCheck(name string, checker Checker) Check {
+func NewCheck(name string, checker Checker, opts ...CheckOption) Check {
 	mustParseFlags()
 	conf, err := config.BuildConfig()
 	if err != nil {
 		// In case config can not be built the the only thing we can do is to raise a panic!!
 		panic(err)
 	}
+	if teeStates {
+		conf.TeeStatesToStdout = true
+	}
+	o := &checkOptions{conf: conf}
+	for _, opt := range opts {
+		opt(o)
+	}
+	checker = WithMiddleware(checker, o.middlewares...)
 
 	var c Check
 	logger := logging.BuildRootLogWithNameAndConfig("check", conf, name)
 	logger.WithFields(log.Fields{"config": conf}).Debug("Building check with configuration")
+	if err := helpers.SetSOCKS5Proxy(conf.SOCKS5Proxy); err != nil {
+		logger.WithError(err).Error("Error setting the SOCKS5 proxy")
+	}
+	helpers.SetUserAgent(conf.UserAgent)
+	ratelimit.SetMaxProcesses(conf.MaxProcesses)
+	if err := report.SetSeverityBands(conf.SeverityBands); err != nil {
+		// The bands are validated when the config is built, but they can be
+		// modified by the options.
+		panic(err)
+	}
 
 	b := true
 	if testMode {
@@ -123,17 +193,62 @@ func NewCheck(name string, checker Checker) Check {
 			conf.AllowPrivateIPs = &b
 		}
 
-		conf.Check.Target = runTarget
-		conf.Check.Opts = options
-		c = newLocalCheck(name, checker, logger, conf, json)
+		opts, err := config.ResolveOptions(options)
+		if err != nil {
+			panic(err)
+		}
+		conf.Check.Opts = opts
+		conf.EnsureCheckID()
+		var lc *local.Check
+		if runTarget == stdinTarget {
+			// Read the targets, one per line, from the standard input.
+			targets, err := local.ReadTargets(os.Stdin)
+			if err != nil {
+				panic(err)
+			}
+			lc = local.NewMultiTargetCheck(name, checker, logger, conf, json, jsonLines, targets)
+		} else {
+			conf.Check.Target = runTarget
+			lc = local.NewCheck(name, checker, logger, conf, json, jsonLines)
+		}
+		if greenbone {
+			lc.UseGreenboneFormat()
+		}
+		c = lc
 	} else {
 		logger.Debug("Push mode")
 		c = push.NewCheckWithConfig(name, checker, logger, conf)
 	}
+	setGuardPolicy(conf, logger)
 	cachedConfig = conf
 	return c
 }
 
+// setGuardPolicy makes the guarded http clients of the helpers refuse to
+// connect to the same targets the check refuses to scan according to the
+// given config.
+func setGuardPolicy(conf *config.Config, logger *log.Entry) {
+	p := helpers.GuardPolicy{AllowPrivateIPs: conf.AllowPrivateIPs != nil && *conf.AllowPrivateIPs}
+	var err error
+	if p.AllowList, err = helpers.NewTargetMatcher(conf.ScannableAllowList); err != nil {
+		logger.WithError(err).Error("Error parsing the scannable allow list")
+	}
+	if conf.DenyListFile != "" {
+		if p.DenyList, err = helpers.LoadTargetList(conf.DenyListFile); err != nil {
+			logger.WithError(err).Error("Error loading the deny list")
+		}
+	}
+	helpers.SetGuardPolicy(p)
+}
+
+// EffectiveConfig returns the configuration used by the last check created,
+// that is, the configuration resulting of applying the overrides from the
+// config file, the env vars and the options. Returns nil if no check has been
+// created yet.
+func EffectiveConfig() *config.Config {
+	return cachedConfig
+}
+
 // NewCheckLog creates a log suitable to be used by a check
 func NewCheckLog(name string) *log.Entry {
 	var l *log.Entry
@@ -156,6 +271,17 @@ func NewCheckFromHandlerWithConfig(name string, conf *config.Config, run Checker
 	}
 	var c Check
 	logger := logging.BuildRootLogWithNameAndConfig("check", conf, name)
+	if err := helpers.SetSOCKS5Proxy(conf.SOCKS5Proxy); err != nil {
+		logger.WithError(err).Error("Error setting the SOCKS5 proxy")
+	}
+	helpers.SetUserAgent(conf.UserAgent)
+	ratelimit.SetMaxProcesses(conf.MaxProcesses)
+	if err := report.SetSeverityBands(conf.SeverityBands); err != nil {
+		// As in NewCheck, the check can't report the severities the user
+		// expects.
+		panic(err)
+	}
+	setGuardPolicy(conf, logger)
 	c = push.NewCheckWithConfig(name, checkerAdapter, logger, conf)
 	cachedConfig = conf
 	return c
@@ -201,10 +327,8 @@ func (t *testCheck) Shutdown() error {
 }
 
 func newCheckWithTestAgent(name string, c Checker, logger *log.Entry, conf *config.Config) Check {
-	if conf.Check.CheckID == "" {
-		// Set a default checkID, this is needed for the test agent.
-		conf.Check.CheckID = "testCheckID"
-	}
+	// A checkID is needed for the test agent.
+	conf.EnsureCheckID()
 	r := tools.NewReporter(conf.Check.CheckID)
 	conf.Push.AgentAddr = r.URL
 	logger.WithField("URL", r.URL).Warn("Building test agent listening on URL")
@@ -216,8 +340,3 @@ func newCheckWithTestAgent(name string, c Checker, logger *log.Entry, conf *conf
 	}
 	return t
 }
-
-func newLocalCheck(name string, checker Checker, logger *log.Entry, conf *config.Config, json bool) Check {
-	check := local.NewCheck(name, checker, logger, conf, json)
-	return check
-}
@@ -0,0 +1,36 @@
+package check
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/adevinta/vulcan-check-sdk/state"
+)
+
+func TestEffectiveConfig(t *testing.T) {
+	envVars := map[string]string{
+		"VULCAN_CHECK_TARGET": "www.example.com",
+		"VULCAN_CHECK_ID":     "effectiveConfigID",
+	}
+	for k, v := range envVars {
+		if err := os.Setenv(k, v); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Unsetenv(k) // nolint
+	}
+	run := func(ctx context.Context, target string, opts string, s state.State) error {
+		return nil
+	}
+	NewCheckFromHandler("effectiveConfig", run)
+	conf := EffectiveConfig()
+	if conf == nil {
+		t.Fatal("EffectiveConfig() returned nil after creating a check")
+	}
+	if conf.Check.Target != envVars["VULCAN_CHECK_TARGET"] {
+		t.Errorf("want target %s, got %s", envVars["VULCAN_CHECK_TARGET"], conf.Check.Target)
+	}
+	if conf.Check.CheckID != envVars["VULCAN_CHECK_ID"] {
+		t.Errorf("want check id %s, got %s", envVars["VULCAN_CHECK_ID"], conf.Check.CheckID)
+	}
+}
@@ -1,14 +1,19 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 
+	"github.com/adevinta/vulcan-check-sdk/helpers/report"
 	"github.com/adevinta/vulcan-check-sdk/internal/push/rest"
 )
 
@@ -16,23 +21,105 @@ const (
 	loggerLevelEnv     = "VULCAN_CHECK_LOG_LVL"
 	loggerFormatterEnv = "VULCAN_CHECK_FMT"
 
+	// Comma separated list of the fields of the check attached to the logs.
+	loggerFieldsEnv = "VULCAN_CHECK_LOG_FIELDS"
+
+	// Maximum length of the options of the check attached to the logs.
+	loggerMaxOptsLenEnv = "VULCAN_CHECK_LOG_MAX_OPTS_LEN"
+
 	checkTargetEnv      = "VULCAN_CHECK_TARGET"
 	checkOptionsEnv     = "VULCAN_CHECK_OPTIONS"
 	checkIDEnv          = "VULCAN_CHECK_ID"
 	checkTypeNameEnv    = "VULCAN_CHECKTYPE_NAME"
 	checkTypeVersionEnv = "VULCAN_CHECKTYPE_VERSION"
 
-	commModeEnv      = "VULCAN_CHECK_COMM_MODE"
-	pushAgentAddr    = "VULCAN_AGENT_ADDRESS"
-	pushMsgBufferLen = "VULCAN_CHECK_MSG_BUFF_LEN"
+	commModeEnv           = "VULCAN_CHECK_COMM_MODE"
+	pushAgentAddr         = "VULCAN_AGENT_ADDRESS"
+	pushFallbackAgentAddr = "VULCAN_AGENT_FALLBACK_ADDRESS"
+	pushMsgBufferLen      = "VULCAN_CHECK_MSG_BUFF_LEN"
 
 	// Allows scanning private / reserved IP addresses.
 	allowPrivateIPs = "VULCAN_ALLOW_PRIVATE_IPS"
 
+	// Time the checker is given to finish after an abort has been requested
+	// and before its context is cancelled.
+	abortGracePeriodEnv = "VULCAN_CHECK_ABORT_GRACE_PERIOD"
+
+	// Maximum time the check waits for the pending messages to be sent to the
+	// agent when shutting down.
+	shutdownTimeoutEnv = "VULCAN_CHECK_SHUTDOWN_TIMEOUT"
+
+	// Maximum size in bytes of the state sent to the agent.
+	maxReportBytesEnv = "VULCAN_CHECK_MAX_REPORT_BYTES"
+
+	// Address of the SOCKS5 proxy used by the network helpers.
+	socks5ProxyEnv = "VULCAN_CHECK_SOCKS5_PROXY"
+
+	// User-Agent sent by the http helpers.
+	userAgentEnv = "VULCAN_CHECK_USER_AGENT"
+
+	// Sends the state to the agent each time a vulnerability is added.
+	streamFindingsEnv = "VULCAN_CHECK_STREAM_FINDINGS"
+
+	// Cancels the checker after the first vulnerability is found.
+	cancelOnFirstFindingEnv = "VULCAN_CHECK_CANCEL_ON_FIRST_FINDING"
+
+	// Minimum severity of the vulnerabilities that make a check run from the
+	// command line exit with the gating exit code.
+	failOnSeverityEnv = "VULCAN_CHECK_FAIL_ON_SEVERITY"
+
+	// Minimum scores of the severities, in the form
+	// "critical=9.5,high=8,medium=5,low=0.1".
+	severityBandsEnv = "VULCAN_CHECK_SEVERITY_BANDS"
+
+	// Maximum number of vulnerabilities sent in each push in paged mode.
+	reportPageSizeEnv = "VULCAN_CHECK_REPORT_PAGE_SIZE"
+
+	// Path of the file where the states sent to the agent are archived.
+	stateArchiveFileEnv = "VULCAN_CHECK_STATE_ARCHIVE_FILE"
+
+	// Writes the states sent to the agent also to the standard output.
+	teeStatesToStdoutEnv = "VULCAN_CHECK_TEE_STATES_STDOUT"
+
+	// File, or file descriptor in the form fd:N, where the progress of the
+	// check is written.
+	progressFileEnv = "VULCAN_CHECK_PROGRESS_FILE"
+
+	// Stops sending the states to every sink when one of them fails.
+	stateSinksFailFastEnv = "VULCAN_CHECK_STATE_SINKS_FAIL_FAST"
+
+	// Verifies the target is reachable before running the checker.
+	reachabilityPreflightEnv = "VULCAN_CHECK_REACHABILITY_PREFLIGHT"
+
+	// Path of the file with the list of targets that must not be scanned.
+	denyListFileEnv = "VULCAN_CHECK_DENY_LIST_FILE"
+
+	// Number of targets checked simultaneously when running a check from the
+	// command line against several targets.
+	targetConcurrencyEnv = "VULCAN_CHECK_TARGET_CONCURRENCY"
+
+	// Maximum number of processes launched by the helpers running at the
+	// same time.
+	maxProcessesEnv = "VULCAN_CHECK_MAX_PROCESSES"
+
+	// Makes the checks declared as active refuse to run.
+	disallowActiveChecksEnv = "VULCAN_CHECK_DISALLOW_ACTIVE"
+
+	// Prefix of the env vars that enable or disable features of the check,
+	// e.g.: VULCAN_CHECK_FEATURE_EXPERIMENTAL_SCANNER=true.
+	featureEnvPrefix = "VULCAN_CHECK_FEATURE_"
+
+	// Comma separated list of targets that are always considered scannable.
+	scannableAllowListEnv = "VULCAN_CHECK_SCANNABLE_ALLOW_LIST"
+
 	// CommModePull Defines the string representing pull communication for check.
 	CommModePull = "pull"
 	// CommModePush Defines the string representing push communication for check.
 	CommModePush = "push"
+	// CommModeGRPC Defines the string representing push communication for
+	// check using the gRPC API of the agent instead of the REST one. Only the
+	// AgentAddr and BufferLen fields of the Push config apply to this mode.
+	CommModeGRPC = "grpc"
 
 	confFilePath = "local.toml"
 )
@@ -50,6 +137,14 @@ type CheckConfig struct {
 type LogConfig struct {
 	LogFmt   string `json:"log_fmt"`
 	LogLevel string `json:"log_level"`
+	// LogFields defines the fields of the check, among: "target", "opts",
+	// "checkID", "checkTypeName" and "checkTypeVersion", attached to every log
+	// line. An empty list means all the fields are attached.
+	LogFields []string `json:"log_fields"`
+	// LogMaxOptsLen defines the maximum length of the value of the "opts"
+	// field of the log lines, the values exceeding it are truncated. A zero
+	// value means no limit.
+	LogMaxOptsLen int `json:"log_max_opts_len"`
 }
 
 // Config holds all values regarding configuration
@@ -59,6 +154,149 @@ type Config struct {
 	CommMode        string
 	Push            rest.RestPusherConfig `toml:"Push"`
 	AllowPrivateIPs *bool
+	// AbortGracePeriod defines the time a checker has, after an abort has been
+	// requested, to finish before its context is cancelled. A zero value means
+	// the context is cancelled immediately.
+	AbortGracePeriod time.Duration
+	// ShutdownTimeout defines the maximum time a check in push mode waits,
+	// when shutting down, for the messages pending to be sent to the agent. A
+	// zero value means the check waits until all of them are sent.
+	ShutdownTimeout time.Duration
+	// MaxReportBytes defines the maximum size, in bytes, of the serialized
+	// state sent to the agent. When the state exceeds this size the
+	// vulnerabilities with lower severity are removed from it. A zero value
+	// means no limit.
+	MaxReportBytes int
+	// SOCKS5Proxy defines the address, in the form host:port, of the SOCKS5
+	// proxy the network helpers use to connect to the targets. An empty value
+	// means the helpers connect directly.
+	SOCKS5Proxy string
+	// UserAgent defines the User-Agent header sent in the requests performed
+	// by the http helpers. An empty value means the default one,
+	// "vulcan-check-sdk/<version>", is sent.
+	UserAgent string
+	// StreamFindings makes the check send its state to the agent each time a
+	// vulnerability is added to the report, instead of only when the progress
+	// or the status change.
+	StreamFindings bool
+	// CancelOnFirstFinding makes the check cancel the context of the checker
+	// as soon as the first vulnerability is added to the report and finish
+	// with the vulnerabilities found so far, e.g.: for pass/fail gates only
+	// interested in whether any vulnerability exists.
+	CancelOnFirstFinding bool
+	// StateArchiveFile defines the path of a file where, in push mode, the
+	// states sent to the agent are also archived, one JSON document per line,
+	// e.g.: for auditing purposes. An empty value means the states are not
+	// archived.
+	StateArchiveFile string
+	// TeeStatesToStdout makes the check, in push mode, write the states sent
+	// to the agent also to the standard output, one JSON document per line,
+	// e.g.: to debug what a check sends to the agent.
+	TeeStatesToStdout bool
+	// ProgressFile defines where, in push mode, the status and the progress of
+	// the check are also written, one JSON document per line, e.g.: for
+	// orchestrators reading the progress from a dedicated file descriptor. It
+	// can be the path of a file or a file descriptor inherited from the
+	// process that runs the check in the form "fd:N", e.g.: "fd:3". An empty
+	// value means the progress is only sent to the agent.
+	ProgressFile string
+	// ReportPageSize makes the check, in push mode, send its report in pages,
+	// as described by the agent.Page type, of at most the given number of
+	// vulnerabilities. The pages are sent on a best-effort basis, a page that
+	// can't be sent is not retried. A value lower than 1 means the full report
+	// is sent in each push.
+	ReportPageSize int
+	// StateSinksFailFast makes the check stop sending its states to a sink
+	// other than the agent, e.g.: the archive file, as soon as sending a state
+	// to it fails. The states are always sent to the agent. By default the
+	// failures are logged and the states keep being sent to every sink.
+	StateSinksFailFast bool
+	// ReachabilityPreflight makes the check verify, in push mode, that the
+	// target is reachable, as described by helpers.CheckReachable, before
+	// running the checker. The IP targets are not verified, as the sdk doesn't
+	// know the ports the checker scans. When it's not, the checker is not run and the
+	// check finishes with the inconclusive status and the reason in the notes
+	// of the report.
+	ReachabilityPreflight bool
+	// DenyListFile defines the path of a file containing the targets, in the
+	// format accepted by helpers.LoadTargetList, the check must refuse to scan.
+	DenyListFile string
+	// ScannableAllowList defines a list of IPs, CIDRs and hostname globs, in
+	// the format accepted by helpers.NewTargetMatcher, of the targets that are
+	// always scanned, even if they are, or resolve to, private IPs.
+	ScannableAllowList []string
+	// TargetConcurrency defines how many targets are checked simultaneously
+	// when running a check from the command line against several targets. A
+	// value lower than 2 means the targets are checked one after the other.
+	TargetConcurrency int
+	// MaxProcesses defines the maximum number of processes launched by the
+	// helpers of the sdk, e.g.: by the nmap helper, that run at the same time.
+	// A value lower than 1 means the number of processes is not limited.
+	MaxProcesses int
+	// FailOnSeverity defines the minimum severity, one of "none", "low",
+	// "medium", "high" or "critical", of the vulnerabilities that make a check
+	// run from the command line exit with the code report.GateExitCode of the
+	// helpers, so CI pipelines can fail when issues are found. An empty value
+	// means the exit code doesn't depend on the vulnerabilities found.
+	FailOnSeverity string
+	// SeverityBands overrides the minimum score, by severity name, of the
+	// vulnerabilities of each severity, e.g.: {"critical" = 9.5, "high" = 8,
+	// "medium" = 5, "low" = 0.1}, used by the formatters and by the severity
+	// helpers of the sdk. The scores lower than the minimum of every severity
+	// have the severity "none". An empty value means the thresholds of the
+	// vulcan-report package are used.
+	SeverityBands map[string]float32
+	// ActiveCheck declares the check as active, that is, as a check that
+	// performs probes that can modify the state of the targets, e.g.: login
+	// attempts with default credentials. It can only be set programmatically.
+	ActiveCheck bool `toml:"-" json:"-"`
+	// DisallowActiveChecks makes the checks declared as active refuse to run,
+	// finishing in the inconclusive status.
+	DisallowActiveChecks bool
+	// Features defines the features of the check, like experimental scanners
+	// or extra modules, that are enabled or disabled, by their lowercased
+	// names. The features are checked with FeatureEnabled.
+	Features map[string]bool
+}
+
+// FeatureEnabled returns true if the feature with the given name, case
+// insensitive, is enabled in the config. The features that are not present in
+// the config are disabled.
+func (c *Config) FeatureEnabled(name string) bool {
+	return c.Features[strings.ToLower(name)]
+}
+
+// EnsureCheckID sets the CheckID of the config, when it's empty, to a new run
+// ID generated with NewRunID, so the reports of the checks run without an
+// agent, e.g.: from the command line or in tests, don't collide.
+func (c *Config) EnsureCheckID() {
+	if c.Check.CheckID == "" {
+		c.Check.CheckID = NewRunID()
+	}
+}
+
+// NewRunID returns a random identifier, in the form of a version 4 UUID, for
+// a run of a check.
+func NewRunID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// The random generator of the system is not available, the ID can
+		// still be unique using the current time.
+		binary.BigEndian.PutUint64(b[:8], uint64(time.Now().UnixNano()))
+		binary.BigEndian.PutUint64(b[8:], uint64(os.Getpid()))
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// AllowPrivate sets whether the check is allowed to scan targets that are, or
+// resolve to, private or reserved IPs. It overrides the value set in the config
+// file or in the env vars and returns the config so it can be used when
+// building a config programmatically.
+func (c *Config) AllowPrivate(allow bool) *Config {
+	c.AllowPrivateIPs = &allow
+	return c
 }
 
 type optionsLogConfig struct {
@@ -81,13 +319,188 @@ func OverrideConfigFromOptions(c *Config) {
 
 // OverrideConfigFromEnvVars overrides config object with values setted in env vars.
 func OverrideConfigFromEnvVars(c *Config) error {
-	overrideConfigLogEnvVars(c)
-	overrideConfigCheckEnvVars(c)
+	if err := overrideConfigLogEnvVars(c); err != nil {
+		return err
+	}
+	if err := overrideConfigCheckEnvVars(c); err != nil {
+		return err
+	}
 	overrideCommConfigEnvVars(c)
+	overrideNetworkConfigEnvVars(c)
+	if err := overrideAbortConfigEnvVars(c); err != nil {
+		return err
+	}
+	if err := overrideReportConfigEnvVars(c); err != nil {
+		return err
+	}
+	if err := overrideConcurrencyConfigEnvVars(c); err != nil {
+		return err
+	}
+	if err := overrideFeaturesConfigEnvVars(c); err != nil {
+		return err
+	}
 	return overrideValidationConfigEnvVars(c)
 }
 
+func overrideFeaturesConfigEnvVars(c *Config) error {
+	for _, env := range os.Environ() {
+		if !strings.HasPrefix(env, featureEnvPrefix) {
+			continue
+		}
+		kv := strings.SplitN(env, "=", 2)
+		name := strings.ToLower(strings.TrimPrefix(kv[0], featureEnvPrefix))
+		if name == "" || len(kv) != 2 {
+			continue
+		}
+		b, err := strconv.ParseBool(kv[1])
+		if err != nil {
+			return fmt.Errorf("can not parse feature %s from env var (%s=%s): %v", name, kv[0], kv[1], err)
+		}
+		if c.Features == nil {
+			c.Features = map[string]bool{}
+		}
+		c.Features[name] = b
+	}
+	return nil
+}
+
+func overrideConcurrencyConfigEnvVars(c *Config) error {
+	concurrency := os.Getenv(targetConcurrencyEnv)
+	if concurrency != "" {
+		n, err := strconv.Atoi(concurrency)
+		if err != nil {
+			return fmt.Errorf("can not parse target concurrency from env var (%s=%s): %v", targetConcurrencyEnv, concurrency, err)
+		}
+		c.TargetConcurrency = n
+	}
+	max := os.Getenv(maxProcessesEnv)
+	if max != "" {
+		n, err := strconv.Atoi(max)
+		if err != nil {
+			return fmt.Errorf("can not parse max processes from env var (%s=%s): %v", maxProcessesEnv, max, err)
+		}
+		c.MaxProcesses = n
+	}
+	return nil
+}
+
+func overrideReportConfigEnvVars(c *Config) error {
+	max := os.Getenv(maxReportBytesEnv)
+	if max != "" {
+		n, err := strconv.Atoi(max)
+		if err != nil {
+			return fmt.Errorf("can not parse max report bytes from env var (%s=%s): %v", maxReportBytesEnv, max, err)
+		}
+		c.MaxReportBytes = n
+	}
+	stream := os.Getenv(streamFindingsEnv)
+	if stream != "" {
+		b, err := strconv.ParseBool(stream)
+		if err != nil {
+			return fmt.Errorf("can not parse stream findings option from env var (%s=%s): %v", streamFindingsEnv, stream, err)
+		}
+		c.StreamFindings = b
+	}
+	cancelOnFirst := os.Getenv(cancelOnFirstFindingEnv)
+	if cancelOnFirst != "" {
+		b, err := strconv.ParseBool(cancelOnFirst)
+		if err != nil {
+			return fmt.Errorf("can not parse cancel on first finding option from env var (%s=%s): %v", cancelOnFirstFindingEnv, cancelOnFirst, err)
+		}
+		c.CancelOnFirstFinding = b
+	}
+	failOn := os.Getenv(failOnSeverityEnv)
+	if failOn != "" {
+		c.FailOnSeverity = failOn
+	}
+	bands := os.Getenv(severityBandsEnv)
+	if bands != "" {
+		m, err := parseSeverityBands(bands)
+		if err != nil {
+			return fmt.Errorf("can not parse severity bands from env var (%s=%s): %v", severityBandsEnv, bands, err)
+		}
+		c.SeverityBands = m
+	}
+	pageSize := os.Getenv(reportPageSizeEnv)
+	if pageSize != "" {
+		n, err := strconv.Atoi(pageSize)
+		if err != nil {
+			return fmt.Errorf("can not parse report page size from env var (%s=%s): %v", reportPageSizeEnv, pageSize, err)
+		}
+		c.ReportPageSize = n
+	}
+	archive := os.Getenv(stateArchiveFileEnv)
+	if archive != "" {
+		c.StateArchiveFile = archive
+	}
+	tee := os.Getenv(teeStatesToStdoutEnv)
+	if tee != "" {
+		b, err := strconv.ParseBool(tee)
+		if err != nil {
+			return fmt.Errorf("can not parse tee states to stdout option from env var (%s=%s): %v", teeStatesToStdoutEnv, tee, err)
+		}
+		c.TeeStatesToStdout = b
+	}
+	progress := os.Getenv(progressFileEnv)
+	if progress != "" {
+		c.ProgressFile = progress
+	}
+	failFast := os.Getenv(stateSinksFailFastEnv)
+	if failFast != "" {
+		b, err := strconv.ParseBool(failFast)
+		if err != nil {
+			return fmt.Errorf("can not parse state sinks fail fast option from env var (%s=%s): %v", stateSinksFailFastEnv, failFast, err)
+		}
+		c.StateSinksFailFast = b
+	}
+	return nil
+}
+
+func overrideAbortConfigEnvVars(c *Config) error {
+	grace := os.Getenv(abortGracePeriodEnv)
+	if grace != "" {
+		d, err := time.ParseDuration(grace)
+		if err != nil {
+			return fmt.Errorf("can not parse abort grace period from env var (%s=%s): %v", abortGracePeriodEnv, grace, err)
+		}
+		c.AbortGracePeriod = d
+	}
+	timeout := os.Getenv(shutdownTimeoutEnv)
+	if timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return fmt.Errorf("can not parse shutdown timeout from env var (%s=%s): %v", shutdownTimeoutEnv, timeout, err)
+		}
+		c.ShutdownTimeout = d
+	}
+	return nil
+}
+
 func overrideValidationConfigEnvVars(c *Config) error {
+	denyList := os.Getenv(denyListFileEnv)
+	if denyList != "" {
+		c.DenyListFile = denyList
+	}
+	allowList := os.Getenv(scannableAllowListEnv)
+	if allowList != "" {
+		c.ScannableAllowList = strings.Split(allowList, ",")
+	}
+	disallowActive := os.Getenv(disallowActiveChecksEnv)
+	if disallowActive != "" {
+		b, err := strconv.ParseBool(disallowActive)
+		if err != nil {
+			return fmt.Errorf("can not parse disallow active checks option from env var (%s=%s): %v", disallowActiveChecksEnv, disallowActive, err)
+		}
+		c.DisallowActiveChecks = b
+	}
+	preflight := os.Getenv(reachabilityPreflightEnv)
+	if preflight != "" {
+		b, err := strconv.ParseBool(preflight)
+		if err != nil {
+			return fmt.Errorf("can not parse reachability preflight option from env var (%s=%s): %v", reachabilityPreflightEnv, preflight, err)
+		}
+		c.ReachabilityPreflight = b
+	}
 	allow := os.Getenv(allowPrivateIPs)
 	if allow == "" {
 		return nil
@@ -114,6 +527,10 @@ func overrideCommConfigEnvVars(c *Config) {
 	if pushEndPoint != "" {
 		c.Push.AgentAddr = pushEndPoint
 	}
+	fallbackEndPoint := os.Getenv(pushFallbackAgentAddr)
+	if fallbackEndPoint != "" {
+		c.Push.FallbackAgentAddr = fallbackEndPoint
+	}
 
 	msgBuffLen := os.Getenv(pushMsgBufferLen)
 	len, err := strconv.ParseInt(msgBuffLen, 0, 32)
@@ -127,7 +544,18 @@ func overrideCommConfigEnvVars(c *Config) {
 	}
 }
 
-func overrideConfigLogEnvVars(c *Config) {
+func overrideNetworkConfigEnvVars(c *Config) {
+	socks5Proxy := os.Getenv(socks5ProxyEnv)
+	if socks5Proxy != "" {
+		c.SOCKS5Proxy = socks5Proxy
+	}
+	userAgent := os.Getenv(userAgentEnv)
+	if userAgent != "" {
+		c.UserAgent = userAgent
+	}
+}
+
+func overrideConfigLogEnvVars(c *Config) error {
 	logLevel := os.Getenv(loggerLevelEnv)
 	if logLevel != "" {
 		c.Log.LogLevel = logLevel
@@ -136,12 +564,34 @@ func overrideConfigLogEnvVars(c *Config) {
 	if logFmt != "" {
 		c.Log.LogFmt = logFmt
 	}
+	fields := os.Getenv(loggerFieldsEnv)
+	if fields != "" {
+		c.Log.LogFields = nil
+		for _, f := range strings.Split(fields, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				c.Log.LogFields = append(c.Log.LogFields, f)
+			}
+		}
+	}
+	maxOptsLen := os.Getenv(loggerMaxOptsLenEnv)
+	if maxOptsLen != "" {
+		n, err := strconv.Atoi(maxOptsLen)
+		if err != nil {
+			return fmt.Errorf("can not parse log max opts len from env var (%s=%s): %v", loggerMaxOptsLenEnv, maxOptsLen, err)
+		}
+		c.Log.LogMaxOptsLen = n
+	}
+	return nil
 }
 
-func overrideConfigCheckEnvVars(c *Config) {
+func overrideConfigCheckEnvVars(c *Config) error {
 	opts := os.Getenv(checkOptionsEnv)
 	if opts != "" {
-		c.Check.Opts = opts
+		resolved, err := ResolveOptions(opts)
+		if err != nil {
+			return fmt.Errorf("can not read options from env var (%s=%s): %v", checkOptionsEnv, opts, err)
+		}
+		c.Check.Opts = resolved
 	}
 	target := os.Getenv(checkTargetEnv)
 	if target != "" {
@@ -159,6 +609,22 @@ func overrideConfigCheckEnvVars(c *Config) {
 	if checkTypeName != "" {
 		c.Check.CheckTypeVersion = checkTypeVer
 	}
+	return nil
+}
+
+// ResolveOptions returns the options of a check given its raw value. If the
+// value starts with "@" the rest of the value is considered the path of a file
+// and its contents are returned as the options, otherwise the value is returned
+// as it is.
+func ResolveOptions(opts string) (string, error) {
+	if !strings.HasPrefix(opts, "@") {
+		return opts, nil
+	}
+	content, err := ioutil.ReadFile(strings.TrimPrefix(opts, "@")) //nolint
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
 }
 
 // LoadConfigFromFile loads configuration file from a path
@@ -185,19 +651,71 @@ func fileExists(filePath string) (exists bool) {
 // BuildConfig builds a configuration struct by reading, if exists, the conf file
 // and overriding the conf values from env vars.
 func BuildConfig() (*Config, error) {
-	c := &Config{}
+	var paths []string
 	if fileExists(confFilePath) {
-		fileConf, err := LoadConfigFromFile(confFilePath)
+		paths = append(paths, confFilePath)
+	}
+	return BuildConfigFromFiles(paths...)
+	// NOTE: what happens if there no config file and also no env vars setted?
+}
+
+// BuildConfigFromFiles builds a configuration struct by reading the given conf
+// files in order, so the values defined in a file override the ones defined in
+// the previous files, and overriding the resulting conf values from env vars.
+// The values not defined in a file are kept untouched, e.g.: a base local.toml
+// can be composed with an overlay only defining the values specific to an
+// environment.
+func BuildConfigFromFiles(paths ...string) (*Config, error) {
+	c := &Config{}
+	for _, path := range paths {
+		configData, err := ioutil.ReadFile(path) //nolint
 		if err != nil {
 			return nil, err
 		}
-		c = fileConf
+		if _, err := toml.Decode(string(configData), c); err != nil {
+			return nil, fmt.Errorf("can not decode config file %s: %w", path, err)
+		}
 	}
 	if err := OverrideConfigFromEnvVars(c); err != nil {
 		return nil, err
 	}
 
 	OverrideConfigFromOptions(c)
+	if err := validateConfig(c); err != nil {
+		return nil, err
+	}
 	return c, nil
-	// NOTE: what happens if there no config file and also no env vars setted?
+}
+
+// validateConfig returns an error if any of the values of the given config,
+// read from the config files or from the env vars, is invalid, so the check
+// doesn't start instead of behaving unexpectedly once it has run.
+func validateConfig(c *Config) error {
+	if c.FailOnSeverity != "" {
+		if _, err := report.ParseSeverity(c.FailOnSeverity); err != nil {
+			return fmt.Errorf("invalid fail on severity config: %v", err)
+		}
+	}
+	if _, err := report.ParseSeverityBands(c.SeverityBands); err != nil {
+		return fmt.Errorf("invalid severity bands config: %v", err)
+	}
+	return nil
+}
+
+// parseSeverityBands parses a list of minimum scores by severity name in the
+// form "critical=9.5,high=8".
+func parseSeverityBands(s string) (map[string]float32, error) {
+	bands := map[string]float32{}
+	for _, band := range strings.Split(s, ",") {
+		kv := strings.SplitN(band, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid severity band %q", band)
+		}
+		score, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 32)
+		if err != nil {
+			return nil, err
+		}
+		bands[strings.TrimSpace(kv[0])] = float32(score)
+	}
+	return bands, nil
 }
@@ -2,8 +2,10 @@ package config
 
 import (
 	"errors"
+	"io/ioutil"
 	"os"
 	"reflect"
+	"regexp"
 	"strconv"
 	"testing"
 
@@ -149,6 +151,62 @@ func TestOverrideConfigFromOpts(t *testing.T) {
 	}
 }
 
+func TestOverrideConfigFromEnvVarsOptionsFile(t *testing.T) {
+	want, err := ioutil.ReadFile("testdata/opts.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = setEnvVars(map[string]string{checkOptionsEnv: "@testdata/opts.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(checkOptionsEnv) // nolint
+	got := &Config{}
+	if err := OverrideConfigFromEnvVars(got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Check.Opts != string(want) {
+		t.Errorf("want options %q, got %q", string(want), got.Check.Opts)
+	}
+}
+
+func TestResolveOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "InlineJSON",
+			opts: `{"debug":true}`,
+			want: `{"debug":true}`,
+		},
+		{
+			name: "File",
+			opts: "@testdata/opts.json",
+			want: "{\"debug\":true,\"ports\":[\"80\",\"443\"]}\n",
+		},
+		{
+			name:    "NotExistingFile",
+			opts:    "@testdata/notexisting.json",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveOptions(tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("want %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
 func setEnvVars(envVars map[string]string) error {
 	for k, v := range envVars {
 		err := os.Setenv(k, v)
@@ -158,3 +216,117 @@ func setEnvVars(envVars map[string]string) error {
 	}
 	return nil
 }
+
+func TestAllowPrivate(t *testing.T) {
+	c := &Config{}
+	if got := c.AllowPrivate(true); got != c {
+		t.Fatalf("want the same config returned")
+	}
+	if c.AllowPrivateIPs == nil || !*c.AllowPrivateIPs {
+		t.Errorf("want AllowPrivateIPs true, got %v", c.AllowPrivateIPs)
+	}
+	c.AllowPrivate(false)
+	if c.AllowPrivateIPs == nil || *c.AllowPrivateIPs {
+		t.Errorf("want AllowPrivateIPs false, got %v", c.AllowPrivateIPs)
+	}
+}
+
+func TestFeatureEnabled(t *testing.T) {
+	envVars := map[string]string{
+		featureEnvPrefix + "EXPERIMENTAL_SCANNER": "true",
+		featureEnvPrefix + "EXTRA_MODULES":        "false",
+	}
+	if err := setEnvVars(envVars); err != nil {
+		t.Fatal(err)
+	}
+	for k := range envVars {
+		defer os.Unsetenv(k) // nolint
+	}
+	c := &Config{
+		Features: map[string]bool{"from_file": true, "extra_modules": true},
+	}
+	if err := OverrideConfigFromEnvVars(c); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]bool{
+		"experimental_scanner": true,
+		"EXPERIMENTAL_SCANNER": true,
+		"from_file":            true,
+		"extra_modules":        false,
+		"unknown":              false,
+	}
+	for name, want := range tests {
+		if got := c.FeatureEnabled(name); got != want {
+			t.Errorf("FeatureEnabled(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	os.Setenv(featureEnvPrefix+"INVALID", "maybe")  // nolint
+	defer os.Unsetenv(featureEnvPrefix + "INVALID") // nolint
+	if err := OverrideConfigFromEnvVars(&Config{}); err == nil {
+		t.Errorf("want error parsing an invalid feature value")
+	}
+}
+
+func TestBuildConfigFromFiles(t *testing.T) {
+	got, err := BuildConfigFromFiles("testdata/BaseConfig.toml", "testdata/OverlayConfig.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The overlay wins for the overlapping keys.
+	if got.UserAgent != "overlay-agent" {
+		t.Errorf("want user agent %q, got %q", "overlay-agent", got.UserAgent)
+	}
+	if !reflect.DeepEqual(got.Log.LogFields, []string{"target"}) {
+		t.Errorf("want log fields %v, got %v", []string{"target"}, got.Log.LogFields)
+	}
+	// The keys only defined in one of the files are kept.
+	if got.SOCKS5Proxy != "proxy:1080" {
+		t.Errorf("want socks5 proxy %q, got %q", "proxy:1080", got.SOCKS5Proxy)
+	}
+	if got.Log.LogMaxOptsLen != 100 {
+		t.Errorf("want log max opts len 100, got %d", got.Log.LogMaxOptsLen)
+	}
+	if got.ReportPageSize != 50 {
+		t.Errorf("want report page size 50, got %d", got.ReportPageSize)
+	}
+
+	if _, err := BuildConfigFromFiles("testdata/BaseConfig.toml", "testdata/NotExists.toml"); err == nil {
+		t.Errorf("want error loading a file that doesn't exist")
+	}
+}
+
+func TestBuildConfigFromFilesInvalidFailOnSeverity(t *testing.T) {
+	os.Setenv(failOnSeverityEnv, "urgent") // nolint
+	defer os.Unsetenv(failOnSeverityEnv)   // nolint
+	if _, err := BuildConfigFromFiles("testdata/BaseConfig.toml"); err == nil {
+		t.Errorf("want error building a config with an invalid fail on severity")
+	}
+}
+
+func TestBuildConfigFromFilesInvalidSeverityBands(t *testing.T) {
+	// The minimum scores must increase with the severities.
+	os.Setenv(severityBandsEnv, "critical=5,high=8") // nolint
+	defer os.Unsetenv(severityBandsEnv)              // nolint
+	if _, err := BuildConfigFromFiles("testdata/BaseConfig.toml"); err == nil {
+		t.Errorf("want error building a config with invalid severity bands")
+	}
+}
+
+func TestEnsureCheckID(t *testing.T) {
+	uuidRegex := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	a, b := &Config{}, &Config{}
+	a.EnsureCheckID()
+	b.EnsureCheckID()
+	if !uuidRegex.MatchString(a.Check.CheckID) {
+		t.Errorf("got check ID %q, want a version 4 UUID", a.Check.CheckID)
+	}
+	if a.Check.CheckID == b.Check.CheckID {
+		t.Errorf("got the same check ID %s twice, want distinct IDs", a.Check.CheckID)
+	}
+	c := &Config{Check: CheckConfig{CheckID: "id"}}
+	c.EnsureCheckID()
+	if c.Check.CheckID != "id" {
+		t.Errorf("got check ID %q, want the explicit one kept", c.Check.CheckID)
+	}
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ValidateFile loads the config file in the given path and returns an error if
+// it's not valid, that is: if it's not a valid TOML document, if it contains
+// keys that don't correspond to any field of the config, e.g.: because of a
+// typo, or if the type of a value doesn't match the one of its field. The
+// error describes every unknown key and, when it can be determined, the line
+// of the file where the problem is.
+func ValidateFile(path string) error {
+	content, err := ioutil.ReadFile(path) //nolint
+	if err != nil {
+		return err
+	}
+	var c Config
+	md, err := toml.Decode(string(content), &c)
+	if err != nil {
+		// The errors returned by the decoder already contain the line, when
+		// it's known, and the last key decoded.
+		return fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	var problems []string
+	undecoded := map[string]bool{}
+	for _, k := range md.Undecoded() {
+		key := strings.Join(k, ".")
+		undecoded[key] = true
+		// The keys of an unknown table are not reported, only the table.
+		if len(k) > 1 && undecoded[strings.Join(k[:len(k)-1], ".")] {
+			continue
+		}
+		problem := fmt.Sprintf("unknown key %q", key)
+		if line := keyLine(content, k); line > 0 {
+			problem = fmt.Sprintf("line %d: %s", line, problem)
+		}
+		problems = append(problems, problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config file %s: %s", path, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// keyLine returns the line where the given key is defined in a TOML document,
+// or 0 if it can not be found. Only the keys defined in their own line or as
+// table headers are found.
+func keyLine(content []byte, key toml.Key) int {
+	want := strings.Join(key, ".")
+	var table string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			end := strings.Index(line, "]")
+			if end == -1 {
+				continue
+			}
+			table = unquoteKey(strings.Trim(line[:end], "[ "))
+			if table == want {
+				return n
+			}
+			continue
+		}
+		eq := strings.Index(line, "=")
+		if eq == -1 {
+			continue
+		}
+		name := unquoteKey(line[:eq])
+		if table != "" {
+			name = table + "." + name
+		}
+		if name == want {
+			return n
+		}
+	}
+	return 0
+}
+
+// unquoteKey returns a dotted TOML key with the spaces around its parts and
+// the quotes of its parts removed.
+func unquoteKey(key string) string {
+	parts := strings.Split(key, ".")
+	for i, p := range parts {
+		parts[i] = strings.Trim(strings.TrimSpace(p), `"'`)
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		wantErr []string
+	}{
+		{
+			name: "Valid",
+			path: "testdata/BaseConfig.toml",
+		},
+		{
+			name: "UnknownKeys",
+			path: "testdata/UnknownKeyConfig.toml",
+			wantErr: []string{
+				`line 2: unknown key "UserAgnt"`,
+				`line 7: unknown key "Check.CheckType"`,
+				`line 9: unknown key "Unknown"`,
+			},
+		},
+		{
+			name:    "MistypedValue",
+			path:    "testdata/MistypedConfig.toml",
+			wantErr: []string{"line 3", "Push.BufferLen", "incompatible types"},
+		},
+		{
+			name:    "NotExists",
+			path:    "testdata/NotExists.toml",
+			wantErr: []string{"NotExists.toml"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateFile(tt.path)
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("want no error, got %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("want error, got nil")
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("want error containing %q, got %q", want, err)
+				}
+			}
+			if strings.Contains(err.Error(), "Unknown.Key") {
+				t.Errorf("want only the unknown table reported, got %q", err)
+			}
+		})
+	}
+}
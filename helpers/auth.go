@@ -0,0 +1,119 @@
+package helpers
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/adevinta/vulcan-check-sdk/helpers/redact"
+)
+
+const (
+	// AuthBasic is the type of the credentials sent using the HTTP basic
+	// authentication scheme.
+	AuthBasic = "basic"
+	// AuthBearer is the type of the credentials sent as a bearer token.
+	AuthBearer = "bearer"
+	// AuthHeader is the type of the credentials sent as the value of a custom
+	// header, e.g.: "X-API-Key".
+	AuthHeader = "header"
+)
+
+// AuthCredentials contains the credentials, usually provided in the options of
+// a check, attached to the probe requests sent to the targets. The secrets are
+// masked when the credentials are formatted, so they can be logged.
+type AuthCredentials struct {
+	// Type is one of AuthBasic, AuthBearer or AuthHeader.
+	Type     string `json:"type"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// Token is the bearer token or the value of the custom header.
+	Token string `json:"token,omitempty"`
+	// Header is the name of the custom header.
+	Header string `json:"header,omitempty"`
+}
+
+// Apply sets in the given request the header corresponding to the credentials.
+func (c AuthCredentials) Apply(req *http.Request) error {
+	switch strings.ToLower(c.Type) {
+	case AuthBasic:
+		req.SetBasicAuth(c.Username, c.Password)
+	case AuthBearer:
+		if c.Token == "" {
+			return errors.New("empty bearer token")
+		}
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	case AuthHeader:
+		if c.Header == "" {
+			return errors.New("empty auth header name")
+		}
+		req.Header.Set(c.Header, c.Token)
+	default:
+		return fmt.Errorf("invalid auth type %q", c.Type)
+	}
+	return nil
+}
+
+// String returns the credentials with the secrets masked.
+func (c AuthCredentials) String() string {
+	masked := c
+	if masked.Password != "" {
+		masked.Password = redact.Mask
+	}
+	if masked.Token != "" {
+		masked.Token = redact.Mask
+	}
+	return fmt.Sprintf("{Type:%s Username:%s Password:%s Token:%s Header:%s}", masked.Type, masked.Username, masked.Password, masked.Token, masked.Header)
+}
+
+// NewAuthenticatedRequest returns a request with the given context, method,
+// url and body, and the header corresponding to the credentials set.
+func NewAuthenticatedRequest(ctx context.Context, method, url string, body io.Reader, creds AuthCredentials) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := creds.Apply(req); err != nil {
+		return nil, err
+	}
+	return req.WithContext(ctx), nil
+}
+
+// ProbeClient returns an http client to send probe requests to the targets
+// that connects through the dialer of the network helpers, refuses to connect
+// to the addresses not allowed by the policy set with SetGuardPolicy, times
+// out after the given duration, sends the User-Agent set with SetUserAgent and
+// doesn't follow redirects, so the credentials attached to the requests with
+// NewAuthenticatedRequest are never sent to other hosts.
+func ProbeClient(timeout time.Duration) *http.Client {
+	return guardedHTTPClient(timeout)
+}
+
+// ParseAuthorization parses the value of an Authorization header using the
+// basic or the bearer scheme and returns the corresponding credentials.
+func ParseAuthorization(value string) (AuthCredentials, error) {
+	parts := strings.SplitN(strings.TrimSpace(value), " ", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return AuthCredentials{}, errors.New("invalid authorization value")
+	}
+	switch strings.ToLower(parts[0]) {
+	case AuthBasic:
+		decoded, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return AuthCredentials{}, fmt.Errorf("invalid basic credentials: %v", err)
+		}
+		userPass := strings.SplitN(string(decoded), ":", 2)
+		if len(userPass) != 2 {
+			return AuthCredentials{}, errors.New("invalid basic credentials: missing password separator")
+		}
+		return AuthCredentials{Type: AuthBasic, Username: userPass[0], Password: userPass[1]}, nil
+	case AuthBearer:
+		return AuthCredentials{Type: AuthBearer, Token: parts[1]}, nil
+	}
+	return AuthCredentials{}, fmt.Errorf("unsupported authorization scheme %q", parts[0])
+}
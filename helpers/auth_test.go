@@ -0,0 +1,124 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewAuthenticatedRequest(t *testing.T) {
+	defer withGuardPolicy(GuardPolicy{AllowPrivateIPs: true})()
+	tests := []struct {
+		name       string
+		creds      AuthCredentials
+		wantHeader string
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:       "Basic",
+			creds:      AuthCredentials{Type: AuthBasic, Username: "user", Password: "pass"},
+			wantHeader: "Authorization",
+			want:       "Basic dXNlcjpwYXNz",
+		},
+		{
+			name:       "Bearer",
+			creds:      AuthCredentials{Type: "Bearer", Token: "t0k3n"},
+			wantHeader: "Authorization",
+			want:       "Bearer t0k3n",
+		},
+		{
+			name:       "CustomHeader",
+			creds:      AuthCredentials{Type: AuthHeader, Header: "X-API-Key", Token: "k3y"},
+			wantHeader: "X-API-Key",
+			want:       "k3y",
+		},
+		{
+			name:    "InvalidType",
+			creds:   AuthCredentials{Type: "digest"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Get(tt.wantHeader)
+			}))
+			defer srv.Close()
+			req, err := NewAuthenticatedRequest(context.Background(), http.MethodGet, srv.URL, nil, tt.creds)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewAuthenticatedRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			resp, err := ProbeClient(5 * time.Second).Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close() // nolint
+			if got != tt.want {
+				t.Errorf("want header %s to be %q, got %q", tt.wantHeader, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAuthCredentialsString(t *testing.T) {
+	creds := AuthCredentials{Type: AuthBasic, Username: "user", Password: "pass", Token: "t0k3n"}
+	got := fmt.Sprintf("%v", creds)
+	if strings.Contains(got, "pass") || strings.Contains(got, "t0k3n") {
+		t.Errorf("want the secrets masked, got %s", got)
+	}
+	if !strings.Contains(got, "user") {
+		t.Errorf("want the username present, got %s", got)
+	}
+}
+
+func TestParseAuthorization(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    AuthCredentials
+		wantErr bool
+	}{
+		{
+			name:  "Basic",
+			value: "Basic dXNlcjpwYXNz",
+			want:  AuthCredentials{Type: AuthBasic, Username: "user", Password: "pass"},
+		},
+		{
+			name:  "Bearer",
+			value: "bearer t0k3n",
+			want:  AuthCredentials{Type: AuthBearer, Token: "t0k3n"},
+		},
+		{
+			name:    "UnsupportedScheme",
+			value:   "Digest username=user",
+			wantErr: true,
+		},
+		{
+			name:    "InvalidBasic",
+			value:   "Basic !!",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAuthorization(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAuthorization() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("credentials differ, diff %s", diff)
+			}
+		})
+	}
+}
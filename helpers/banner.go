@@ -0,0 +1,60 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"regexp"
+	"time"
+)
+
+// MaxBannerBytes defines the maximum number of bytes read by GrabBanner.
+const MaxBannerBytes = 1024
+
+// GrabBanner connects to the TCP service listening in the given host and port
+// and returns the initial bytes, up to MaxBannerBytes, the service sends. The
+// timeout applies to the whole operation, that is: connecting and reading the
+// banner, and it's shortened if the context has an earlier deadline. Reading
+// stops when the service closes the connection, the limit is reached or the
+// timeout expires, in that last case the bytes read so far are returned without
+// error if any. The connection is established through the SOCKS5 proxy set
+// with SetSOCKS5Proxy, if any.
+func GrabBanner(ctx context.Context, host, port string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := dial(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close() // nolint
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return "", err
+	}
+	banner := make([]byte, 0, MaxBannerBytes)
+	buf := make([]byte, MaxBannerBytes)
+	for len(banner) < MaxBannerBytes {
+		n, err := conn.Read(buf[:MaxBannerBytes-len(banner)])
+		banner = append(banner, buf[:n]...)
+		if err == nil {
+			continue
+		}
+		var netErr net.Error
+		if err == io.EOF || (errors.As(err, &netErr) && netErr.Timeout() && len(banner) > 0) {
+			break
+		}
+		return "", err
+	}
+	return string(banner), nil
+}
+
+// MatchBanner returns true if the given banner matches the regular expression.
+func MatchBanner(banner, regex string) (bool, error) {
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return false, err
+	}
+	return re.MatchString(banner), nil
+}
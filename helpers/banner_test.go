@@ -0,0 +1,85 @@
+package helpers
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestGrabBanner(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close() // nolint
+	banner := "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3\r\n"
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close() // nolint
+		// Write the banner but keep the connection open, as most services do,
+		// to check the banner is returned after the timeout.
+		conn.Write([]byte(banner)) // nolint
+		time.Sleep(time.Second)
+	}()
+	host, port, err := net.SplitHostPort(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := GrabBanner(context.Background(), host, port, 500*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != banner {
+		t.Errorf("want banner %q, got %q", banner, got)
+	}
+	match, err := MatchBanner(got, `^SSH-2\.0-OpenSSH_[0-9.]+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !match {
+		t.Errorf("want banner %q to match", got)
+	}
+}
+
+func TestMatchBanner(t *testing.T) {
+	tests := []struct {
+		name    string
+		banner  string
+		regex   string
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:   "Match",
+			banner: "220 mail.example.com ESMTP Postfix",
+			regex:  `^220 .* ESMTP`,
+			want:   true,
+		},
+		{
+			name:   "NoMatch",
+			banner: "220 mail.example.com ESMTP Postfix",
+			regex:  `^SSH-`,
+		},
+		{
+			name:    "InvalidRegex",
+			banner:  "220",
+			regex:   `(`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MatchBanner(tt.banner, tt.regex)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MatchBanner() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("MatchBanner() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const cdnTimeout = 10 * time.Second
+
+// cdnProvider defines the signals that identify the hosts fronted by a CDN or
+// a WAF provider.
+type cdnProvider struct {
+	name string
+	// ranges contains the CIDRs of the edge servers of the provider.
+	ranges []string
+	// ptrSuffixes contains the suffixes of the names returned by the reverse
+	// lookups of the IPs of the edge servers.
+	ptrSuffixes []string
+	// headers contains the names of the headers the edge servers add to the
+	// responses.
+	headers []string
+	// servers contains the lowercased prefixes of the values of the Server
+	// header returned by the edge servers.
+	servers []string
+}
+
+// cdnProviders contains the providers detected by DetectCDN. The lists of IP
+// ranges only contain the main, published, ranges of each provider.
+var cdnProviders = []cdnProvider{
+	{
+		name: "Cloudflare",
+		ranges: []string{
+			"173.245.48.0/20", "103.21.244.0/22", "104.16.0.0/13",
+			"172.64.0.0/13", "162.158.0.0/15", "2606:4700::/32",
+		},
+		headers: []string{"CF-Ray", "CF-Cache-Status"},
+		servers: []string{"cloudflare"},
+	},
+	{
+		name:        "Akamai",
+		ranges:      []string{"23.32.0.0/11", "23.192.0.0/11", "2.16.0.0/13"},
+		ptrSuffixes: []string{".akamaitechnologies.com", ".akamaiedge.net"},
+		headers:     []string{"X-Akamai-Transformed", "Akamai-Grn"},
+		servers:     []string{"akamaighost", "akamainetstorage"},
+	},
+	{
+		name:        "CloudFront",
+		ranges:      []string{"13.32.0.0/15", "13.224.0.0/14", "54.230.0.0/16", "54.239.128.0/18"},
+		ptrSuffixes: []string{".cloudfront.net"},
+		headers:     []string{"X-Amz-Cf-Id", "X-Amz-Cf-Pop"},
+		servers:     []string{"cloudfront"},
+	},
+	{
+		name:    "Fastly",
+		ranges:  []string{"151.101.0.0/16", "199.232.0.0/16", "2a04:4e40::/32"},
+		headers: []string{"X-Fastly-Request-ID"},
+	},
+	{
+		name:    "Imperva",
+		headers: []string{"X-Iinfo"},
+	},
+	{
+		name:    "Sucuri",
+		headers: []string{"X-Sucuri-ID"},
+		servers: []string{"sucuri"},
+	},
+}
+
+// DetectCDN returns true if the given host, a hostname or an IP, is fronted by
+// a CDN or a WAF, e.g.: Cloudflare or Akamai, and the name of the provider.
+// The provider is detected, in order, by matching the IPs of the host against
+// the IP ranges of the providers, by the names returned by the reverse lookups
+// of the IPs and by the headers returned by the web server of the host, which
+// is requested using https, or http if that fails, through the dialer of the
+// network helpers. An error is only returned when the host can not be
+// resolved, the other signals are considered missing when they can not be
+// gathered.
+func DetectCDN(ctx context.Context, host string) (provider string, behind bool, err error) {
+	ips, err := hostIPs(ctx, host)
+	if err != nil {
+		return "", false, err
+	}
+	for _, ip := range ips {
+		if provider := cdnByRange(ip); provider != "" {
+			return provider, true, nil
+		}
+	}
+	for _, ip := range ips {
+		names, err := ptrResolver.LookupAddr(ctx, ip.String())
+		if err != nil {
+			continue
+		}
+		if provider := cdnByPTR(names); provider != "" {
+			return provider, true, nil
+		}
+	}
+	header, ok := cdnResponseHeader(ctx, host)
+	if !ok {
+		return "", false, nil
+	}
+	if provider := cdnByHeader(header); provider != "" {
+		return provider, true, nil
+	}
+	return "", false, nil
+}
+
+// hostIPs returns the IPs the given host resolves to or the host itself if it
+// is an IP.
+func hostIPs(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	addrs, err := resolver.LookupIPAddr(ctx, toASCIIHostname(host))
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no IPs found for %s", host)
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		ips = append(ips, addr.IP)
+	}
+	return ips, nil
+}
+
+func cdnByRange(ip net.IP) string {
+	for _, p := range cdnProviders {
+		for _, r := range p.ranges {
+			_, n, err := net.ParseCIDR(r)
+			if err == nil && n.Contains(ip) {
+				return p.name
+			}
+		}
+	}
+	return ""
+}
+
+func cdnByPTR(names []string) string {
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSuffix(name, "."))
+		for _, p := range cdnProviders {
+			for _, suffix := range p.ptrSuffixes {
+				if strings.HasSuffix(name, suffix) {
+					return p.name
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func cdnByHeader(header http.Header) string {
+	server := strings.ToLower(header.Get("Server"))
+	for _, p := range cdnProviders {
+		for _, h := range p.headers {
+			if header.Get(h) != "" {
+				return p.name
+			}
+		}
+		for _, s := range p.servers {
+			if server != "" && strings.HasPrefix(server, s) {
+				return p.name
+			}
+		}
+	}
+	return ""
+}
+
+// cdnResponseHeader returns the headers of the response to a GET request to
+// the root of the web server of the host, trying https first and then http.
+// It returns false if none of the requests succeeds.
+func cdnResponseHeader(ctx context.Context, host string) (http.Header, bool) {
+	addr := host
+	if ip := net.ParseIP(host); ip != nil && strings.Contains(host, ":") {
+		addr = "[" + host + "]"
+	}
+	client := guardedHTTPClient(cdnTimeout)
+	for _, scheme := range []string{"https", "http"} {
+		req, err := http.NewRequest(http.MethodGet, scheme+"://"+addr+"/", nil)
+		if err != nil {
+			return nil, false
+		}
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			continue
+		}
+		io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxFingerprintBodyBytes)) // nolint
+		resp.Body.Close()                                                           // nolint
+		return resp.Header, true
+	}
+	return nil, false
+}
@@ -0,0 +1,104 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fixedAddrDialer connects to the same address whatever the address dialed.
+type fixedAddrDialer string
+
+func (d fixedAddrDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	var nd net.Dialer
+	return nd.DialContext(ctx, network, string(d))
+}
+
+func TestDetectCDN(t *testing.T) {
+	defer withGuardPolicy(GuardPolicy{AllowPrivateIPs: true})()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host == "headers.example.com" {
+			w.Header().Set("X-Amz-Cf-Id", "abcd")
+		}
+		w.Header().Set("Server", "nginx")
+	}))
+	defer srv.Close()
+	defer setDialer(setDialer(fixedAddrDialer(srv.Listener.Addr().String())))
+
+	ips := map[string][]net.IPAddr{
+		"range.example.com":   {{IP: net.ParseIP("203.0.113.1")}, {IP: net.ParseIP("104.16.1.1")}},
+		"ptr.example.com":     {{IP: net.ParseIP("203.0.113.2")}},
+		"headers.example.com": {{IP: net.ParseIP("203.0.113.3")}},
+		"origin.example.com":  {{IP: net.ParseIP("203.0.113.4")}},
+	}
+	defer withResolver(stubResolver(func(host string) ([]net.IPAddr, error) {
+		if addrs, ok := ips[host]; ok {
+			return addrs, nil
+		}
+		return nil, errors.New("lookup " + host + ": no such host")
+	}))()
+	prevPTR := ptrResolver
+	ptrResolver = stubAddrResolver(func(addr string) ([]string, error) {
+		if addr == "203.0.113.2" {
+			return []string{"a203-0-113-2.deploy.static.AkamaiTechnologies.com."}, nil
+		}
+		return nil, errors.New("lookup " + addr + ": no such host")
+	})
+	defer func() { ptrResolver = prevPTR }()
+
+	tests := []struct {
+		name         string
+		host         string
+		wantProvider string
+		wantBehind   bool
+		wantErr      bool
+	}{
+		{
+			name:         "IPRange",
+			host:         "range.example.com",
+			wantProvider: "Cloudflare",
+			wantBehind:   true,
+		},
+		{
+			name:         "IP",
+			host:         "2606:4700::1",
+			wantProvider: "Cloudflare",
+			wantBehind:   true,
+		},
+		{
+			name:         "ReverseDNS",
+			host:         "ptr.example.com",
+			wantProvider: "Akamai",
+			wantBehind:   true,
+		},
+		{
+			name:         "Headers",
+			host:         "headers.example.com",
+			wantProvider: "CloudFront",
+			wantBehind:   true,
+		},
+		{
+			name: "NotBehindCDN",
+			host: "origin.example.com",
+		},
+		{
+			name:    "NotResolvable",
+			host:    "notexists.example.com",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, behind, err := DetectCDN(context.Background(), tt.host)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if provider != tt.wantProvider || behind != tt.wantBehind {
+				t.Errorf("want %q and %v, got %q and %v", tt.wantProvider, tt.wantBehind, provider, behind)
+			}
+		})
+	}
+}
@@ -10,11 +10,42 @@ import (
 	"os"
 	"os/exec"
 	"syscall"
+	"time"
 
+	"github.com/adevinta/vulcan-check-sdk/helpers/ratelimit"
 	"github.com/adevinta/vulcan-check-sdk/internal/logging"
 	log "github.com/sirupsen/logrus"
 )
 
+var limiter *ratelimit.Limiter
+
+// SetLimiter sets a limiter that throttles the processes launched by the
+// functions of this package. The same limiter can be shared with other
+// helpers, for instance check.NewProcessCheckerWithLimiter, to throttle all
+// the processes launched by a check. A nil value disables the throttling.
+// This function is not safe to be called concurrently with the execution of commands.
+func SetLimiter(l *ratelimit.Limiter) {
+	limiter = l
+}
+
+// ContextWithMaxDuration returns a child of the given context that is done
+// after the given maximum duration or when the parent is done, whatever happens
+// first, so it can be used to cap the time a command runs without exceeding the
+// remaining time of the check. A max value less or equal than zero doesn't cap
+// the duration of the child context. The returned cancel function must be
+// called to release the resources associated with the child context.
+func ContextWithMaxDuration(ctx context.Context, max time.Duration) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if max <= 0 {
+		return context.WithCancel(ctx)
+	}
+	// The deadline of the child context is the minimum of the deadline of the
+	// parent and now plus max.
+	return context.WithTimeout(ctx, max)
+}
+
 // ParseError reports a failure when trying to parse a process output.
 type ParseError struct {
 	// ProcessOutput output of the process that couldn't be parsed.
@@ -40,6 +71,9 @@ func (e *ParseError) Error() string {
 // Returns the outputs of the process written to the standard output and error, also returns the status code returned by the command.
 // Note that, contrary to the standard library, the function doesn't return an error if the command execution returned a value different from 0.
 // The new process where the command is executed inherits all the env vars of the current process.
+// The process is killed when the context is done, for instance, when its deadline expires, in that case the
+// error of the context is returned.
+// The process is not launched until the limit set with ratelimit.SetMaxProcesses allows it.
 func ExecuteWithStdErr(ctx context.Context, logger *log.Entry, exe string, params ...string) ([]byte, []byte, int, error) {
 	if ctx == nil {
 		ctx = context.Background()
@@ -48,6 +82,14 @@ func ExecuteWithStdErr(ctx context.Context, logger *log.Entry, exe string, param
 		logger = logging.BuildRootLog("sdk.process")
 	}
 	logger = logger.WithFields(log.Fields{"cmd": exe, "params": params})
+	if err := limiter.Wait(ctx); err != nil {
+		return nil, nil, 0, err
+	}
+	release, err := ratelimit.AcquireProcess(ctx)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer release()
 	var returnCode int
 	cmd := exec.CommandContext(ctx, exe, params...) //nolint
 	cmd.Env = os.Environ()
@@ -56,10 +98,14 @@ func ExecuteWithStdErr(ctx context.Context, logger *log.Entry, exe string, param
 	stdOut := &bytes.Buffer{}
 	cmd.Stderr = stdErr
 	cmd.Stdout = stdOut
-	err := cmd.Run()
+	err = cmd.Run()
 	output := stdOut.Bytes()
 	errOutput := stdErr.Bytes()
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			// The process was killed because the context is done.
+			return output, errOutput, 0, ctxErr
+		}
 		if exitE, ok := err.(*exec.ExitError); ok {
 			// Cmd will only return an error of type exec.ExitError when the process returned a different value than zero,
 			// at least in the unix family.
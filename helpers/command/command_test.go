@@ -3,8 +3,12 @@ package command
 import (
 	"context"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/adevinta/vulcan-check-sdk/helpers/ratelimit"
+	"github.com/adevinta/vulcan-check-sdk/internal/logging"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -154,3 +158,93 @@ func TestExecuteJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestExecuteWithLimiter(t *testing.T) {
+	l, err := ratelimit.New(10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetLimiter(l)
+	defer SetLimiter(nil)
+	n := 4
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		if _, _, err := Execute(nil, nil, "true"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	elapsed := time.Since(start)
+	// The limiter allows the first command immediately and one every 100ms after that.
+	min := time.Duration(n-1) * 100 * time.Millisecond
+	if elapsed < min-10*time.Millisecond {
+		t.Errorf("want the commands to take at least %s, got %s", min, elapsed)
+	}
+}
+
+func TestExecuteWithMaxProcesses(t *testing.T) {
+	ratelimit.SetMaxProcesses(1)
+	defer ratelimit.SetMaxProcesses(0)
+	n := 4
+	// The logger is built once instead of by each command.
+	logger := logging.BuildRootLog("sdk.test")
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := Execute(nil, logger, "sleep", "0.1")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	elapsed := time.Since(start)
+	// The commands run one after the other.
+	min := time.Duration(n) * 100 * time.Millisecond
+	if elapsed < min-10*time.Millisecond {
+		t.Errorf("want the commands to take at least %s, got %s", min, elapsed)
+	}
+}
+
+func TestExecuteParentDeadline(t *testing.T) {
+	parent, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	ctx, cancelCmd := ContextWithMaxDuration(parent, time.Minute)
+	defer cancelCmd()
+	parentDeadline, _ := parent.Deadline()
+	if deadline, ok := ctx.Deadline(); !ok || !deadline.Equal(parentDeadline) {
+		t.Errorf("want the deadline of the parent %s, got %s", parentDeadline, deadline)
+	}
+	start := time.Now()
+	_, _, err := Execute(ctx, nil, "sleep", "10")
+	if err != context.DeadlineExceeded {
+		t.Errorf("want error %v, got %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("want the command killed when the parent deadline expires, took %s", elapsed)
+	}
+}
+
+func TestContextWithMaxDuration(t *testing.T) {
+	ctx, cancel := ContextWithMaxDuration(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		t.Fatal("want a deadline in the child context")
+	}
+	if remaining := time.Until(deadline); remaining > 100*time.Millisecond {
+		t.Errorf("want the child capped at 100ms, remaining %s", remaining)
+	}
+	ctx, cancel = ContextWithMaxDuration(context.Background(), 0)
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Errorf("want no deadline when max is 0")
+	}
+}
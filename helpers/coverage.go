@@ -0,0 +1,42 @@
+package helpers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/adevinta/vulcan-check-sdk/state"
+)
+
+// ProbeCoverage probes, using IsPortOpen, the given TCP ports of the given
+// hosts and returns the coverage of the probes, where a host responded if any
+// of its ports is open and a port responded if it's open. The coverage can be
+// recorded in the report with the SetCoverage method of the state.
+func ProbeCoverage(ctx context.Context, hosts, ports []string, timeout time.Duration) state.Coverage {
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		responded = map[string]int{}
+	)
+	for _, host := range hosts {
+		for _, port := range ports {
+			wg.Add(1)
+			go func(host, port string) {
+				defer wg.Done()
+				open, err := IsPortOpen(ctx, host, port, timeout)
+				if err != nil || !open {
+					return
+				}
+				mu.Lock()
+				responded[host]++
+				mu.Unlock()
+			}(host, port)
+		}
+	}
+	wg.Wait()
+	var respondedPorts int
+	for _, n := range responded {
+		respondedPorts += n
+	}
+	return state.NewCoverage(len(hosts), len(responded), len(hosts)*len(ports), respondedPorts)
+}
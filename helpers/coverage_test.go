@@ -0,0 +1,42 @@
+package helpers
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/adevinta/vulcan-check-sdk/state"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestProbeCoverage(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close() // nolint
+	openPort := strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := strconv.Itoa(closed.Addr().(*net.TCPAddr).Port)
+	closed.Close() // nolint
+
+	// 127.0.0.2 isn't listening in any of the ports.
+	hosts := []string{"127.0.0.1", "127.0.0.2"}
+	got := ProbeCoverage(context.Background(), hosts, []string{openPort, closedPort}, time.Second)
+	want := state.Coverage{
+		IntendedHosts:  2,
+		RespondedHosts: 1,
+		HostsPercent:   50,
+		IntendedPorts:  4,
+		RespondedPorts: 1,
+		PortsPercent:   25,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ProbeCoverage() != want, diff %s", diff)
+	}
+}
@@ -0,0 +1,232 @@
+// Package cpe provides helpers to parse and compare Common Platform
+// Enumeration (CPE) names, like the ones reported by nmap or used by the
+// vulnerability databases to identify the affected products.
+package cpe
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	// Any is the value of the attributes that match any value.
+	Any = "*"
+	// NA is the value of the attributes that are not applicable.
+	NA = "-"
+
+	formattedPrefix = "cpe:2.3:"
+	uriPrefix       = "cpe:/"
+	attributes      = 11
+)
+
+// ErrInvalidCPE is returned, wrapped with the reason, when parsing a string
+// that is not a valid CPE.
+var ErrInvalidCPE = errors.New("invalid CPE")
+
+// CPE contains the attributes of a CPE name. The values are unescaped and
+// lowercased, the attributes not specified in the name have the value Any.
+type CPE struct {
+	// Part is "a" for applications, "o" for operating systems and "h" for
+	// hardware devices.
+	Part      string
+	Vendor    string
+	Product   string
+	Version   string
+	Update    string
+	Edition   string
+	Language  string
+	SWEdition string
+	TargetSW  string
+	TargetHW  string
+	Other     string
+}
+
+// Parse parses a CPE name in the 2.3 formatted string binding, e.g.:
+// "cpe:2.3:a:openbsd:openssh:7.4:*:*:*:*:*:*:*", or in the 2.2 URI binding,
+// e.g.: "cpe:/a:openbsd:openssh:7.4". The trailing attributes can be omitted
+// in both formats.
+func Parse(cpe string) (CPE, error) {
+	var values []string
+	var err error
+	lower := strings.ToLower(cpe)
+	switch {
+	case strings.HasPrefix(lower, formattedPrefix):
+		values, err = splitFormatted(cpe[len(formattedPrefix):])
+	case strings.HasPrefix(lower, uriPrefix):
+		values, err = splitURI(cpe[len(uriPrefix):])
+	default:
+		return CPE{}, fmt.Errorf("%w: %q doesn't start with %q or %q", ErrInvalidCPE, cpe, formattedPrefix, uriPrefix)
+	}
+	if err != nil {
+		return CPE{}, fmt.Errorf("%w: %q: %v", ErrInvalidCPE, cpe, err)
+	}
+	if len(values) > attributes {
+		return CPE{}, fmt.Errorf("%w: %q has %d attributes, max %d", ErrInvalidCPE, cpe, len(values), attributes)
+	}
+	for len(values) < attributes {
+		values = append(values, Any)
+	}
+	c := CPE{
+		Part:      values[0],
+		Vendor:    values[1],
+		Product:   values[2],
+		Version:   values[3],
+		Update:    values[4],
+		Edition:   values[5],
+		Language:  values[6],
+		SWEdition: values[7],
+		TargetSW:  values[8],
+		TargetHW:  values[9],
+		Other:     values[10],
+	}
+	switch c.Part {
+	case "a", "o", "h", Any:
+	default:
+		return CPE{}, fmt.Errorf("%w: %q has the invalid part %q", ErrInvalidCPE, cpe, c.Part)
+	}
+	return c, nil
+}
+
+// splitFormatted returns the unescaped attributes of a CPE in the 2.3
+// formatted string binding without the prefix.
+func splitFormatted(s string) ([]string, error) {
+	var values []string
+	var value strings.Builder
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			value.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ':':
+			values = append(values, value.String())
+			value.Reset()
+		default:
+			value.WriteRune(r)
+		}
+	}
+	if escaped {
+		return nil, errors.New("unterminated escape sequence")
+	}
+	values = append(values, value.String())
+	for i, v := range values {
+		if v == "" {
+			return nil, fmt.Errorf("attribute %d is empty", i+1)
+		}
+		values[i] = strings.ToLower(v)
+	}
+	return values, nil
+}
+
+// splitURI returns the decoded attributes of a CPE in the 2.2 URI binding
+// without the prefix. The empty attributes are returned as Any.
+func splitURI(s string) ([]string, error) {
+	values := strings.Split(s, ":")
+	for i, v := range values {
+		decoded, err := url.PathUnescape(v)
+		if err != nil {
+			return nil, err
+		}
+		if decoded == "" {
+			decoded = Any
+		}
+		values[i] = strings.ToLower(decoded)
+	}
+	return values, nil
+}
+
+// values returns the attributes of the CPE in the order they appear in a CPE
+// name.
+func (c CPE) values() []string {
+	return []string{
+		c.Part, c.Vendor, c.Product, c.Version, c.Update, c.Edition,
+		c.Language, c.SWEdition, c.TargetSW, c.TargetHW, c.Other,
+	}
+}
+
+// String returns the CPE in the 2.3 formatted string binding.
+func (c CPE) String() string {
+	values := c.values()
+	for i, v := range values {
+		if v == "" {
+			v = Any
+		}
+		values[i] = escape(v)
+	}
+	return formattedPrefix + strings.Join(values, ":")
+}
+
+// escape escapes the characters of a value that must be escaped in the 2.3
+// formatted string binding. The characters "*" and "?" are not escaped as
+// they are interpreted as wildcards.
+func escape(v string) string {
+	var b strings.Builder
+	for _, r := range v {
+		isAlnum := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+		if !isAlnum && !strings.ContainsRune("_.-*?", r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Match returns true if the given CPE matches the pattern, that is, if each
+// of its attributes matches the one of the pattern. An attribute of the
+// pattern with the value Any matches any value, the value NA only matches NA
+// and the rest of values match the equal values, where a "*" matches any
+// sequence of characters and a "?" matches any single character. For instance
+// the pattern "cpe:2.3:a:openbsd:openssh:7.*" matches the CPE
+// "cpe:/a:openbsd:openssh:7.4".
+func Match(pattern, c CPE) bool {
+	values := c.values()
+	for i, p := range pattern.values() {
+		if !matchValue(p, values[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchValue(pattern, value string) bool {
+	if pattern == "" || pattern == Any {
+		return true
+	}
+	if value == "" {
+		value = Any
+	}
+	if pattern == NA || value == NA || value == Any {
+		return pattern == value
+	}
+	return glob(strings.ToLower(pattern), strings.ToLower(value))
+}
+
+// glob returns true if the value matches the pattern, where a "*" matches any
+// sequence of characters and a "?" matches any single character.
+func glob(pattern, value string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(value); i >= 0; i-- {
+				if glob(pattern[1:], value[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(value) == 0 {
+				return false
+			}
+		default:
+			if len(value) == 0 || value[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, value = pattern[1:], value[1:]
+	}
+	return len(value) == 0
+}
@@ -0,0 +1,177 @@
+package cpe
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		cpe     string
+		want    CPE
+		wantErr bool
+	}{
+		{
+			name: "FormattedString",
+			cpe:  "cpe:2.3:a:microsoft:internet_explorer:8.0.6001:beta:*:*:*:*:*:*",
+			want: CPE{
+				Part: "a", Vendor: "microsoft", Product: "internet_explorer", Version: "8.0.6001",
+				Update: "beta", Edition: Any, Language: Any, SWEdition: Any, TargetSW: Any,
+				TargetHW: Any, Other: Any,
+			},
+		},
+		{
+			name: "FormattedStringEscapedAndShort",
+			cpe:  `cpe:2.3:a:Hp:insight_diagnostics:7.4.0.1570:-:*:*:online:win2003\:x64`,
+			want: CPE{
+				Part: "a", Vendor: "hp", Product: "insight_diagnostics", Version: "7.4.0.1570",
+				Update: NA, Edition: Any, Language: Any, SWEdition: "online", TargetSW: "win2003:x64",
+				TargetHW: Any, Other: Any,
+			},
+		},
+		{
+			name: "URI",
+			cpe:  "cpe:/a:openbsd:openssh:7.4%7e1",
+			want: CPE{
+				Part: "a", Vendor: "openbsd", Product: "openssh", Version: "7.4~1",
+				Update: Any, Edition: Any, Language: Any, SWEdition: Any, TargetSW: Any,
+				TargetHW: Any, Other: Any,
+			},
+		},
+		{
+			name: "URIEmptyAttributes",
+			cpe:  "cpe:/o:linux::2.6",
+			want: CPE{
+				Part: "o", Vendor: "linux", Product: Any, Version: "2.6",
+				Update: Any, Edition: Any, Language: Any, SWEdition: Any, TargetSW: Any,
+				TargetHW: Any, Other: Any,
+			},
+		},
+		{
+			name:    "NoPrefix",
+			cpe:     "a:openbsd:openssh:7.4",
+			wantErr: true,
+		},
+		{
+			name:    "InvalidPart",
+			cpe:     "cpe:2.3:x:openbsd:openssh:7.4",
+			wantErr: true,
+		},
+		{
+			name:    "TooManyAttributes",
+			cpe:     "cpe:2.3:a:b:c:d:e:f:g:h:i:j:k:l",
+			wantErr: true,
+		},
+		{
+			name:    "EmptyAttribute",
+			cpe:     "cpe:2.3:a::openssh",
+			wantErr: true,
+		},
+		{
+			name:    "UnterminatedEscape",
+			cpe:     `cpe:2.3:a:openbsd:openssh\`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.cpe)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidCPE) {
+					t.Errorf("want error %v, got %v", ErrInvalidCPE, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("parsed CPE differs, diff %s", diff)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	c, err := Parse(`cpe:/a:hp:insight_diagnostics:7.4.0.1570::~~online~win2003%3ax64~`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `cpe:2.3:a:hp:insight_diagnostics:7.4.0.1570:*:\~\~online\~win2003\:x64\~:*:*:*:*:*`
+	if got := c.String(); got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+	parsed, err := Parse(c.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(c, parsed); diff != "" {
+		t.Errorf("CPE differs after round-tripping it, diff %s", diff)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		cpe     string
+		want    bool
+	}{
+		{
+			name:    "WildcardVersion",
+			pattern: "cpe:2.3:a:openbsd:openssh:7.*",
+			cpe:     "cpe:/a:openbsd:openssh:7.4",
+			want:    true,
+		},
+		{
+			name:    "WildcardVersionNoMatch",
+			pattern: "cpe:2.3:a:openbsd:openssh:7.*",
+			cpe:     "cpe:/a:openbsd:openssh:8.0",
+		},
+		{
+			name:    "SingleCharWildcard",
+			pattern: "cpe:2.3:a:apache:http_server:2.4.?",
+			cpe:     "cpe:2.3:a:apache:http_server:2.4.7:*:*:*:*:*:*:*",
+			want:    true,
+		},
+		{
+			name:    "AnyMatchesNA",
+			pattern: "cpe:2.3:a:openbsd:openssh:*:*",
+			cpe:     "cpe:2.3:a:openbsd:openssh:7.4:-",
+			want:    true,
+		},
+		{
+			name:    "NAOnlyMatchesNA",
+			pattern: "cpe:2.3:a:openbsd:openssh:7.4:-",
+			cpe:     "cpe:2.3:a:openbsd:openssh:7.4:p1",
+		},
+		{
+			name:    "ValueDoesNotMatchAny",
+			pattern: "cpe:2.3:a:openbsd:openssh:7.4",
+			cpe:     "cpe:2.3:a:openbsd:openssh",
+		},
+		{
+			name:    "DifferentProduct",
+			pattern: "cpe:2.3:a:openbsd:*",
+			cpe:     "cpe:2.3:o:openbsd:openbsd:6.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern, err := Parse(tt.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := Parse(tt.cpe)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Match(pattern, c); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,367 @@
+// Package defaultcreds provides helpers to detect services that accept
+// default credentials. Take into account that trying credentials is an active
+// operation: it performs login attempts that can lock accounts, fill logs or
+// create sessions in the targets, so the checks using this package must be
+// declared as active.
+package defaultcreds
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adevinta/vulcan-check-sdk/helpers"
+	"github.com/adevinta/vulcan-check-sdk/helpers/redact"
+)
+
+const (
+	// ProtocolHTTPBasic is the protocol of the web servers requiring HTTP
+	// basic authentication to access their root path using http.
+	ProtocolHTTPBasic = "http-basic"
+	// ProtocolHTTPSBasic is the same as ProtocolHTTPBasic using https.
+	ProtocolHTTPSBasic = "https-basic"
+	// ProtocolHTTPForm is the protocol of the web servers with a login form
+	// posted to the /login path using http, with the fields "username" and
+	// "password".
+	ProtocolHTTPForm = "http-form"
+	// ProtocolHTTPSForm is the same as ProtocolHTTPForm using https.
+	ProtocolHTTPSForm = "https-form"
+
+	tryTimeout = 10 * time.Second
+	// maxBodyBytes is the maximum number of bytes of the body of the
+	// responses read by the handlers.
+	maxBodyBytes = 1 << 20
+)
+
+// ErrNoAuthRequired is returned when a service doesn't require authentication
+// at all.
+var ErrNoAuthRequired = errors.New("the service doesn't require authentication")
+
+// Credential contains a username and a password. The password is masked when
+// the credential is formatted, so it can be logged.
+type Credential struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// String returns the credential with the password masked.
+func (c Credential) String() string {
+	password := c.Password
+	if password != "" {
+		password = redact.Mask
+	}
+	return fmt.Sprintf("{Username:%s Password:%s}", c.Username, password)
+}
+
+// Handler tries credentials against the services of a protocol.
+type Handler interface {
+	// Try returns true if the service listening in the given host and port
+	// accepts the credential.
+	Try(ctx context.Context, host, port string, c Credential) (bool, error)
+}
+
+// Starter is implemented by the handlers that need to inspect a service once
+// before trying the credentials against it, e.g.: to know if it requires
+// authentication at all. TryCredentials starts the handler, if it implements
+// Starter, and tries the credentials with the handler returned by Start.
+type Starter interface {
+	Start(ctx context.Context, host, port string) (Handler, error)
+}
+
+// HandlerFunc is an adapter to use ordinary functions as handlers.
+type HandlerFunc func(ctx context.Context, host, port string, c Credential) (bool, error)
+
+// Try calls f(ctx, host, port, c).
+func (f HandlerFunc) Try(ctx context.Context, host, port string, c Credential) (bool, error) {
+	return f(ctx, host, port, c)
+}
+
+var (
+	handlersMu sync.RWMutex
+	handlers   = map[string]Handler{
+		ProtocolHTTPBasic:  BasicAuth("http", "/"),
+		ProtocolHTTPSBasic: BasicAuth("https", "/"),
+		ProtocolHTTPForm:   FormLogin(Form{Scheme: "http", Path: "/login", UsernameField: "username", PasswordField: "password"}),
+		ProtocolHTTPSForm:  FormLogin(Form{Scheme: "https", Path: "/login", UsernameField: "username", PasswordField: "password"}),
+	}
+)
+
+// Register sets the handler used to try the credentials of the given
+// protocol, replacing the previous one, if any. It allows to support other
+// protocols, e.g.: ssh, or login forms with other paths or fields.
+func Register(protocol string, h Handler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers[protocol] = h
+}
+
+// TryCredentials tries, one after the other, each one of the given
+// credentials against the service of the given protocol listening in the host
+// and port, and returns the ones the service accepts. This is an active
+// operation, see the documentation of the package. When a credential can not
+// be tried the credentials accepted so far are returned with the error.
+func TryCredentials(ctx context.Context, protocol, host, port string, creds []Credential) ([]Credential, error) {
+	handlersMu.RLock()
+	h, ok := handlers[protocol]
+	handlersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported protocol %q", protocol)
+	}
+	if s, ok := h.(Starter); ok {
+		var err error
+		h, err = s.Start(ctx, host, port)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var accepted []Credential
+	for _, c := range creds {
+		if err := ctx.Err(); err != nil {
+			return accepted, err
+		}
+		ok, err := h.Try(ctx, host, port, c)
+		if err != nil {
+			return accepted, err
+		}
+		if ok {
+			accepted = append(accepted, c)
+		}
+	}
+	return accepted, nil
+}
+
+// BasicAuth returns a handler that sends a GET request to the given path
+// using the HTTP basic authentication scheme. A credential is accepted when
+// the path responds with a 401 or a 403 to a request without credentials and
+// with a success or a redirect to the request with the credential. When the
+// handler is started, it returns ErrNoAuthRequired if the path responds with a
+// success to a request without credentials.
+func BasicAuth(scheme, path string) Handler {
+	return basicAuth{scheme: scheme, path: path}
+}
+
+type basicAuth struct {
+	scheme string
+	path   string
+	// started is true when the response of the path to a request without
+	// credentials has been checked.
+	started bool
+	// requiresAuth is true when the path responds with a 401 or a 403 to a
+	// request without credentials.
+	requiresAuth bool
+}
+
+// Start sends a request without credentials to the path of the service.
+func (b basicAuth) Start(ctx context.Context, host, port string) (Handler, error) {
+	req, err := http.NewRequest(http.MethodGet, serviceURL(b.scheme, host, port, b.path), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if isSuccess(resp.StatusCode) {
+		return nil, ErrNoAuthRequired
+	}
+	b.started = true
+	b.requiresAuth = isDenied(resp.StatusCode)
+	return b, nil
+}
+
+// Try sends a request with the credential to the path of the service. When
+// the handler has not been started, it's started before.
+func (b basicAuth) Try(ctx context.Context, host, port string, c Credential) (bool, error) {
+	if !b.started {
+		h, err := b.Start(ctx, host, port)
+		if err != nil {
+			return false, err
+		}
+		return h.Try(ctx, host, port, c)
+	}
+	if !b.requiresAuth {
+		return false, nil
+	}
+	req, err := http.NewRequest(http.MethodGet, serviceURL(b.scheme, host, port, b.path), nil)
+	if err != nil {
+		return false, err
+	}
+	req.SetBasicAuth(c.Username, c.Password)
+	resp, err := send(ctx, req)
+	if err != nil {
+		return false, err
+	}
+	return isSuccess(resp.StatusCode) || isRedirect(resp.StatusCode), nil
+}
+
+// Form defines a login form.
+type Form struct {
+	// Scheme is the scheme, http or https, used to post the form.
+	Scheme string
+	// Path is the path the form is posted to.
+	Path string
+	// UsernameField and PasswordField are the names of the fields of the
+	// form containing the credential.
+	UsernameField string
+	PasswordField string
+}
+
+// FormLogin returns a handler that posts the credentials in the given form.
+// When the handler is started, it posts a random credential, that is assumed
+// to be invalid, to know how the form responds to a failed login. A
+// credential is accepted when the response is not an error nor a redirect to
+// the path of the form and, compared to the response to the invalid
+// credential, it has a different status code, it redirects to a different
+// location or it sets a new cookie, e.g.: a session cookie.
+func FormLogin(f Form) Handler {
+	return formLogin{form: f}
+}
+
+type formLogin struct {
+	form Form
+	// failed is the response to the invalid credential, nil when the
+	// handler has not been started.
+	failed *loginResponse
+}
+
+// loginResponse contains the parts of the response to a login used to know
+// if it succeeded.
+type loginResponse struct {
+	status   int
+	location *url.URL
+	cookies  map[string]bool
+}
+
+// Start posts a random credential to the form of the service.
+func (f formLogin) Start(ctx context.Context, host, port string) (Handler, error) {
+	c, err := randomCredential()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.post(ctx, host, port, c)
+	if err != nil {
+		return nil, err
+	}
+	f.failed = &resp
+	return f, nil
+}
+
+// Try posts the credential to the form of the service. When the handler has
+// not been started, it's started before.
+func (f formLogin) Try(ctx context.Context, host, port string, c Credential) (bool, error) {
+	if f.failed == nil {
+		h, err := f.Start(ctx, host, port)
+		if err != nil {
+			return false, err
+		}
+		return h.Try(ctx, host, port, c)
+	}
+	resp, err := f.post(ctx, host, port, c)
+	if err != nil {
+		return false, err
+	}
+	return f.succeeded(resp), nil
+}
+
+func (f formLogin) succeeded(resp loginResponse) bool {
+	if !isSuccess(resp.status) && !isRedirect(resp.status) {
+		return false
+	}
+	if resp.location != nil && resp.location.Path == f.form.Path {
+		return false
+	}
+	if resp.status != f.failed.status {
+		return true
+	}
+	if resp.location != nil && (f.failed.location == nil || resp.location.String() != f.failed.location.String()) {
+		return true
+	}
+	for name := range resp.cookies {
+		if !f.failed.cookies[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func (f formLogin) post(ctx context.Context, host, port string, c Credential) (loginResponse, error) {
+	values := url.Values{}
+	values.Set(f.form.UsernameField, c.Username)
+	values.Set(f.form.PasswordField, c.Password)
+	u := serviceURL(f.form.Scheme, host, port, f.form.Path)
+	req, err := http.NewRequest(http.MethodPost, u, strings.NewReader(values.Encode()))
+	if err != nil {
+		return loginResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := send(ctx, req)
+	if err != nil {
+		return loginResponse{}, err
+	}
+	lresp := loginResponse{status: resp.StatusCode, cookies: map[string]bool{}}
+	if isRedirect(resp.StatusCode) {
+		// A redirect without a valid location is handled as a redirect to
+		// the form.
+		lresp.location = &url.URL{Path: f.form.Path}
+		if location, err := resp.Location(); err == nil {
+			lresp.location = location
+		}
+	}
+	for _, cookie := range resp.Cookies() {
+		// Ignore the cookies being deleted.
+		if cookie.Value == "" || cookie.MaxAge < 0 {
+			continue
+		}
+		lresp.cookies[cookie.Name] = true
+	}
+	return lresp, nil
+}
+
+// randomCredential returns a credential with a random username and password.
+func randomCredential() (Credential, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return Credential{}, err
+	}
+	return Credential{Username: hex.EncodeToString(b[:8]), Password: hex.EncodeToString(b[8:])}, nil
+}
+
+func serviceURL(scheme, host, port, path string) string {
+	u := url.URL{Scheme: scheme, Host: net.JoinHostPort(host, port), Path: path}
+	return u.String()
+}
+
+// send sends the request and returns the response with its body already
+// read and closed.
+func send(ctx context.Context, req *http.Request) (*http.Response, error) {
+	resp, err := helpers.ProbeClient(tryTimeout).Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() // nolint
+	// Drain the body so the request is completed.
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxBodyBytes)) // nolint
+	return resp, nil
+}
+
+func isSuccess(status int) bool {
+	return status >= 200 && status < 300
+}
+
+func isRedirect(status int) bool {
+	return status >= 300 && status < 400
+}
+
+func isDenied(status int) bool {
+	return status == http.StatusUnauthorized || status == http.StatusForbidden
+}
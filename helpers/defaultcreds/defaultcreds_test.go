@@ -0,0 +1,131 @@
+package defaultcreds
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/adevinta/vulcan-check-sdk/helpers"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTryCredentials(t *testing.T) {
+	helpers.SetGuardPolicy(helpers.GuardPolicy{AllowPrivateIPs: true})
+	defer helpers.SetGuardPolicy(helpers.GuardPolicy{})
+	valid := Credential{Username: "admin", Password: "admin"}
+	isValid := func(r *http.Request) bool {
+		return r.PostFormValue("username") == valid.Username && r.PostFormValue("password") == valid.Password
+	}
+	var basicRequests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			atomic.AddInt32(&basicRequests, 1)
+			user, password, ok := r.BasicAuth()
+			if !ok || user != valid.Username || password != valid.Password {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		case "/login":
+			if isValid(r) {
+				http.Redirect(w, r, "/dashboard", http.StatusFound)
+				return
+			}
+			w.Write([]byte(`<form method="post"><input name="username"><input name="password" type="password"></form>`)) // nolint
+		case "/session":
+			// The form always responds with the same page, but it only sets
+			// the session cookie for the valid credential.
+			http.SetCookie(w, &http.Cookie{Name: "tracking", Value: "1"})
+			if isValid(r) {
+				http.SetCookie(w, &http.Cookie{Name: "session", Value: "1"})
+			}
+			w.Write([]byte("Welcome")) // nolint
+		case "/error":
+			// The form responds with a page without the password field to
+			// any credential.
+			w.Write([]byte("Invalid credentials")) // nolint
+		case "/public":
+		}
+	}))
+	defer srv.Close()
+	host, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds := []Credential{
+		{Username: "root", Password: "root"},
+		valid,
+		{Username: "admin", Password: "password"},
+	}
+	testHandlers := map[string]Handler{
+		"http-public":  BasicAuth("http", "/public"),
+		"http-session": FormLogin(Form{Scheme: "http", Path: "/session", UsernameField: "username", PasswordField: "password"}),
+		"http-error":   FormLogin(Form{Scheme: "http", Path: "/error", UsernameField: "username", PasswordField: "password"}),
+	}
+	for protocol, h := range testHandlers {
+		Register(protocol, h)
+	}
+	defer func() {
+		handlersMu.Lock()
+		defer handlersMu.Unlock()
+		for protocol := range testHandlers {
+			delete(handlers, protocol)
+		}
+	}()
+
+	tests := []struct {
+		name     string
+		protocol string
+		want     []Credential
+		wantErr  error
+	}{
+		{
+			name:     "BasicAuth",
+			protocol: ProtocolHTTPBasic,
+			want:     []Credential{valid},
+		},
+		{
+			name:     "FormLogin",
+			protocol: ProtocolHTTPForm,
+			want:     []Credential{valid},
+		},
+		{
+			name:     "FormLoginSessionCookie",
+			protocol: "http-session",
+			want:     []Credential{valid},
+		},
+		{
+			name:     "FormLoginNoSuccessSignal",
+			protocol: "http-error",
+		},
+		{
+			name:     "NoAuthRequired",
+			protocol: "http-public",
+			wantErr:  ErrNoAuthRequired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TryCredentials(context.Background(), tt.protocol, host, port, creds)
+			if err != tt.wantErr {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("accepted credentials differ, diff %s", diff)
+			}
+		})
+	}
+
+	// The service is only requested once without credentials.
+	if got, want := atomic.LoadInt32(&basicRequests), int32(len(creds)+1); got != want {
+		t.Errorf("want %d basic auth requests, got %d", want, got)
+	}
+	if _, err := TryCredentials(context.Background(), "unknown", host, port, creds); err == nil {
+		t.Errorf("want error for an unsupported protocol")
+	}
+	if s := valid.String(); s != "{Username:admin Password:********}" {
+		t.Errorf("want password masked, got %s", s)
+	}
+}
@@ -0,0 +1,126 @@
+package helpers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ipResolver defines the methods of a net.Resolver used by the helpers to
+// resolve names, this allows to replace the resolver in tests.
+type ipResolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+var resolver ipResolver = &retryResolver{next: net.DefaultResolver}
+
+const (
+	// DefaultDNSAttempts is the default number of times the helpers try to
+	// resolve a name when the lookups fail with a temporary error.
+	DefaultDNSAttempts = 3
+	// DefaultDNSBackoff is the default time the helpers wait before the
+	// first retry of a lookup, the time is doubled after each retry.
+	DefaultDNSBackoff = 200 * time.Millisecond
+)
+
+var (
+	dnsRetryMu  sync.RWMutex
+	dnsAttempts = DefaultDNSAttempts
+	dnsBackoff  = DefaultDNSBackoff
+)
+
+// SetDNSRetries sets the number of times the helpers try to resolve a name
+// when the lookups fail with a temporary error, e.g.: a timeout, and the time
+// they wait before the first retry, which is doubled after each retry. A
+// number of attempts less than 1 restores the DefaultDNSAttempts and a
+// negative backoff restores the DefaultDNSBackoff.
+func SetDNSRetries(attempts int, backoff time.Duration) {
+	if attempts < 1 {
+		attempts = DefaultDNSAttempts
+	}
+	if backoff < 0 {
+		backoff = DefaultDNSBackoff
+	}
+	dnsRetryMu.Lock()
+	defer dnsRetryMu.Unlock()
+	dnsAttempts = attempts
+	dnsBackoff = backoff
+}
+
+func dnsRetries() (int, time.Duration) {
+	dnsRetryMu.RLock()
+	defer dnsRetryMu.RUnlock()
+	return dnsAttempts, dnsBackoff
+}
+
+// retryResolver wraps a resolver retrying the lookups that fail with a
+// temporary error according to the settings defined with SetDNSRetries.
+type retryResolver struct {
+	next ipResolver
+}
+
+func (r *retryResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	attempts, backoff := dnsRetries()
+	for attempt := 1; ; attempt++ {
+		addrs, err := r.next.LookupIPAddr(ctx, host)
+		if err == nil || attempt >= attempts || !isTemporary(err) || ctx.Err() != nil {
+			return addrs, err
+		}
+		t := time.NewTimer(backoff)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, err
+		}
+		backoff *= 2
+	}
+}
+
+// isTemporary returns true if the given error is a net.Error reporting a
+// temporary failure.
+func isTemporary(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Temporary() // nolint
+}
+
+// IsWildcardDNS returns true if the given domain has a wildcard DNS record,
+// that is: any subdomain of it resolves. In that case it also returns the IPs
+// the wildcard record resolves to. The detection is performed by resolving a
+// random subdomain that is very unlikely to exist.
+func IsWildcardDNS(ctx context.Context, domain string) (bool, []net.IP, error) {
+	label, err := randomLabel()
+	if err != nil {
+		return false, nil, err
+	}
+	name := label + "." + strings.TrimSuffix(domain, ".")
+	addrs, err := resolver.LookupIPAddr(ctx, name)
+	if err != nil {
+		// See the comment in the IsHostname method of the Target.
+		if strings.Contains(err.Error(), noSuchHostErrorToken) {
+			return false, nil, nil
+		}
+		return false, nil, err
+	}
+	if len(addrs) == 0 {
+		return false, nil, nil
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, a := range addrs {
+		ips = append(ips, a.IP)
+	}
+	return true, ips, nil
+}
+
+func randomLabel() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
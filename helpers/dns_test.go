@@ -0,0 +1,138 @@
+package helpers
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubResolver answers the queries using the given function.
+type stubResolver func(host string) ([]net.IPAddr, error)
+
+func (s stubResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	return s(host)
+}
+
+func withResolver(r ipResolver) func() {
+	prev := resolver
+	resolver = r
+	return func() {
+		resolver = prev
+	}
+}
+
+func TestIsWildcardDNS(t *testing.T) {
+	wildcardIP := net.ParseIP("203.0.113.10")
+	tests := []struct {
+		name     string
+		resolver stubResolver
+		want     bool
+		wantIPs  []net.IP
+		wantErr  bool
+	}{
+		{
+			name: "DetectsWildcard",
+			resolver: func(host string) ([]net.IPAddr, error) {
+				if !strings.HasSuffix(host, ".example.com") {
+					return nil, &net.DNSError{Err: "no such host", Name: host}
+				}
+				return []net.IPAddr{{IP: wildcardIP}}, nil
+			},
+			want:    true,
+			wantIPs: []net.IP{wildcardIP},
+		},
+		{
+			name: "DetectsNoWildcard",
+			resolver: func(host string) ([]net.IPAddr, error) {
+				return nil, &net.DNSError{Err: "no such host", Name: host}
+			},
+			want: false,
+		},
+		{
+			name: "ReturnsResolutionErrors",
+			resolver: func(host string) ([]net.IPAddr, error) {
+				return nil, &net.DNSError{Err: "server misbehaving", Name: host}
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := withResolver(tt.resolver)
+			defer restore()
+			got, gotIPs, err := IsWildcardDNS(context.Background(), "example.com")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("IsWildcardDNS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("IsWildcardDNS() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(gotIPs, tt.wantIPs) {
+				t.Errorf("IsWildcardDNS() ips = %v, want %v", gotIPs, tt.wantIPs)
+			}
+		})
+	}
+}
+
+func TestRetryResolver(t *testing.T) {
+	SetDNSRetries(3, time.Millisecond)
+	defer SetDNSRetries(0, -1)
+	ip := net.ParseIP("203.0.113.10")
+	tests := []struct {
+		name         string
+		failures     int
+		err          error
+		wantLookups  int
+		wantHostname bool
+		wantErr      bool
+	}{
+		{
+			name:         "RetriesTemporaryErrors",
+			failures:     2,
+			err:          &net.DNSError{Err: "i/o timeout", Name: "www.example.com", IsTimeout: true, IsTemporary: true},
+			wantLookups:  3,
+			wantHostname: true,
+		},
+		{
+			name:        "GivesUpAfterTheAttempts",
+			failures:    3,
+			err:         &net.DNSError{Err: "i/o timeout", Name: "www.example.com", IsTimeout: true, IsTemporary: true},
+			wantLookups: 3,
+			wantErr:     true,
+		},
+		{
+			name:        "DoesNotRetryPermanentErrors",
+			failures:    1,
+			err:         &net.DNSError{Err: "server misbehaving", Name: "www.example.com"},
+			wantLookups: 1,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lookups int
+			stub := stubResolver(func(host string) ([]net.IPAddr, error) {
+				lookups++
+				if lookups <= tt.failures {
+					return nil, tt.err
+				}
+				return []net.IPAddr{{IP: ip}}, nil
+			})
+			restore := withResolver(&retryResolver{next: stub})
+			defer restore()
+			got, err := Target{Value: "www.example.com"}.IsHostname()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("IsHostname() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.wantHostname {
+				t.Errorf("IsHostname() = %v, want %v", got, tt.wantHostname)
+			}
+			if lookups != tt.wantLookups {
+				t.Errorf("want %d lookups, got %d", tt.wantLookups, lookups)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package helpers
+
+import (
+	"context"
+	"net"
+	"strings"
+)
+
+// addrResolver defines the methods of a net.Resolver used by the helpers to
+// perform reverse lookups, this allows to replace the resolver in tests.
+type addrResolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+}
+
+var ptrResolver addrResolver = net.DefaultResolver
+
+// TargetsEqual returns true if the given targets refer to the same logical
+// asset, so they can be deduplicated or share cached results. The rules
+// applied are:
+// * The targets are equal if their normalized forms, as returned by
+// NormalizeTarget, are equal, e.g.: "http://example.com/" and "example.com".
+// * URLs with different schemes are not equal, even if their normalized forms
+// are, because the normalization strips the schemes, e.g.:
+// "http://example.com" and "https://example.com".
+// * IPs are compared by value, so different representations of the same IPv6
+// address are equal. CIDRs are compared using their network form, as returned
+// by NormalizeCIDR, and a CIDR containing a single address is equal to that IP.
+// * An IP and a hostname are equal if the hostname is one of the names
+// returned by the reverse lookup of the IP and, in turn, the hostname resolves
+// to the IP, that is, the PTR record is forward-confirmed.
+// * Any other pair of targets, including URLs with different paths or ports,
+// are not equal.
+// An error is returned if any of the targets can not be normalized or the
+// lookups of the names fail for reasons other than the names not existing.
+func TargetsEqual(a, b string) (bool, error) {
+	if sa, sb := targetScheme(a), targetScheme(b); sa != "" && sb != "" && sa != sb {
+		return false, nil
+	}
+	na, err := NormalizeTarget(a)
+	if err != nil {
+		return false, err
+	}
+	nb, err := NormalizeTarget(b)
+	if err != nil {
+		return false, err
+	}
+	if na == nb {
+		return true, nil
+	}
+	ipA, ipB := targetIP(na), targetIP(nb)
+	switch {
+	case ipA != nil && ipB != nil:
+		return ipA.Equal(ipB), nil
+	case ipA != nil:
+		return isPTRName(ipA, nb)
+	case ipB != nil:
+		return isPTRName(ipB, na)
+	}
+	ca, _, errA := NormalizeCIDR(na)
+	cb, _, errB := NormalizeCIDR(nb)
+	return errA == nil && errB == nil && ca == cb, nil
+}
+
+// targetScheme returns the lowercased scheme of a target that is a URL.
+// Otherwise it returns an empty string.
+func targetScheme(target string) string {
+	i := strings.Index(target, "://")
+	if i < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(target[:i]))
+}
+
+// targetIP returns the IP corresponding to a normalized target that is an IP
+// or a CIDR containing a single address. Otherwise it returns nil.
+func targetIP(target string) net.IP {
+	if ip := net.ParseIP(target); ip != nil {
+		return ip
+	}
+	ip, ipnet, err := net.ParseCIDR(target)
+	if err != nil {
+		return nil
+	}
+	if ones, bits := ipnet.Mask.Size(); ones != bits {
+		return nil
+	}
+	return ip
+}
+
+// isPTRName returns true if the given normalized target is a hostname that is
+// a forward-confirmed PTR name of the IP.
+func isPTRName(ip net.IP, target string) (bool, error) {
+	t := Target{Value: target}
+	if t.IsCIDR() || t.IsURL() || t.IsAWSAccount() || strings.Contains(target, ":") {
+		return false, nil
+	}
+	ctx := context.Background()
+	names, err := ptrResolver.LookupAddr(ctx, ip.String())
+	if err != nil {
+		// See the comment in the IsHostname method of the Target.
+		if strings.Contains(err.Error(), noSuchHostErrorToken) {
+			return false, nil
+		}
+		return false, err
+	}
+	var found bool
+	for _, name := range names {
+		if strings.EqualFold(strings.TrimSuffix(name, "."), target) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return false, nil
+	}
+	addrs, err := resolver.LookupIPAddr(ctx, toASCIIHostname(target))
+	if err != nil {
+		if strings.Contains(err.Error(), noSuchHostErrorToken) {
+			return false, nil
+		}
+		return false, err
+	}
+	for _, addr := range addrs {
+		if addr.IP.Equal(ip) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,142 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+// stubAddrResolver answers the reverse queries using the given function.
+type stubAddrResolver func(addr string) ([]string, error)
+
+func (s stubAddrResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	return s(addr)
+}
+
+func TestTargetsEqual(t *testing.T) {
+	ptrs := map[string][]string{
+		"203.0.113.10": {"www.example.com."},
+		"203.0.113.20": {"spoofed.example.com."},
+	}
+	ips := map[string][]net.IPAddr{
+		"www.example.com":     {{IP: net.ParseIP("203.0.113.10")}},
+		"spoofed.example.com": {{IP: net.ParseIP("198.51.100.1")}},
+	}
+	defer withResolver(stubResolver(func(host string) ([]net.IPAddr, error) {
+		if addrs, ok := ips[host]; ok {
+			return addrs, nil
+		}
+		return nil, errors.New("lookup " + host + ": no such host")
+	}))()
+	prev := ptrResolver
+	ptrResolver = stubAddrResolver(func(addr string) ([]string, error) {
+		if names, ok := ptrs[addr]; ok {
+			return names, nil
+		}
+		return nil, errors.New("lookup " + addr + ": no such host")
+	})
+	defer func() { ptrResolver = prev }()
+
+	tests := []struct {
+		name    string
+		a       string
+		b       string
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "URLAndHostname",
+			a:    "http://example.com/",
+			b:    "example.com",
+			want: true,
+		},
+		{
+			name: "URLsWithDifferentSchemes",
+			a:    "http://example.com/",
+			b:    "https://example.com/",
+		},
+		{
+			name: "URLsWithSchemesInDifferentCase",
+			a:    "HTTPS://example.com/",
+			b:    "https://example.com",
+			want: true,
+		},
+		{
+			name: "HostnamesWithDifferentCase",
+			a:    "WWW.Example.com.",
+			b:    "www.example.com",
+			want: true,
+		},
+		{
+			name: "IPv6Representations",
+			a:    "2001:DB8::1",
+			b:    "2001:db8:0::1",
+			want: true,
+		},
+		{
+			name: "CIDRWithHostBits",
+			a:    "10.0.0.5/24",
+			b:    "10.0.0.0/24",
+			want: true,
+		},
+		{
+			name: "SingleHostCIDRAndIP",
+			a:    "10.0.0.5/32",
+			b:    "10.0.0.5",
+			want: true,
+		},
+		{
+			name: "IPAndPTRName",
+			a:    "203.0.113.10",
+			b:    "www.example.com",
+			want: true,
+		},
+		{
+			name: "PTRNameNotForwardConfirmed",
+			a:    "203.0.113.20",
+			b:    "spoofed.example.com",
+		},
+		{
+			name: "IPWithoutPTR",
+			a:    "198.51.100.1",
+			b:    "spoofed.example.com",
+		},
+		{
+			name: "DifferentHostnames",
+			a:    "example.com",
+			b:    "www.example.com",
+		},
+		{
+			name: "URLsWithDifferentPaths",
+			a:    "https://example.com/a",
+			b:    "https://example.com/b",
+		},
+		{
+			name: "DifferentCIDRs",
+			a:    "10.0.0.0/24",
+			b:    "10.0.0.0/16",
+		},
+		{
+			name:    "EmptyTarget",
+			a:       "",
+			b:       "example.com",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TargetsEqual(tt.a, tt.b)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TargetsEqual() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("TargetsEqual() = %v, want %v", got, tt.want)
+			}
+			// The relation must be symmetric.
+			if got, _ := TargetsEqual(tt.b, tt.a); got != tt.want { // nolint
+				t.Errorf("TargetsEqual() with swapped targets = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
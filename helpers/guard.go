@@ -0,0 +1,104 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// ErrAddressNotAllowed is returned by the guarded http clients of the helpers
+// when they refuse to connect to an address according to the GuardPolicy.
+var ErrAddressNotAllowed = errors.New("address not allowed")
+
+// GuardPolicy defines the addresses the guarded http clients of the helpers,
+// like the one returned by ProbeClient, are allowed to connect to.
+type GuardPolicy struct {
+	// AllowPrivateIPs allows connecting to the private and reserved IPs
+	// defined in NotScannableNetsIPV4 and NotScannableNetsIPV6.
+	AllowPrivateIPs bool
+	// AllowList contains the hosts and IPs that are always allowed, even if
+	// they are, or resolve to, private IPs.
+	AllowList TargetMatcher
+	// DenyList contains the hosts and IPs that are never allowed.
+	DenyList TargetMatcher
+}
+
+var (
+	guardPolicyMu sync.RWMutex
+	guardPolicy   GuardPolicy
+)
+
+// SetGuardPolicy sets the policy enforced by the guarded http clients of the
+// helpers. By default the clients refuse to connect to private IPs.
+func SetGuardPolicy(p GuardPolicy) {
+	guardPolicyMu.Lock()
+	defer guardPolicyMu.Unlock()
+	guardPolicy = p
+}
+
+func currentGuardPolicy() GuardPolicy {
+	guardPolicyMu.RLock()
+	defer guardPolicyMu.RUnlock()
+	return guardPolicy
+}
+
+// guardedDial connects to the given address, through the dialer of the network
+// helpers, only if its host, and every IP the host resolves to, are allowed by
+// the guard policy. As the check is performed for each connection it also
+// applies to the connections to the hosts the requests are redirected to. When
+// the host is the host of the given pin, if not nil, the policy is checked
+// against the host and the pinned IP, and the connection is established with
+// the pinned IP. Otherwise the host is resolved locally, even when a SOCKS5
+// proxy is set, and the connection is established with the IPs checked, trying
+// them in turn until one succeeds, so the resolution of the host can not change
+// between the check and the connection.
+func guardedDial(ctx context.Context, network, address string, pin *Pin) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	var ips []net.IP
+	if ip, ok := pin.pinnedIP(host); ok {
+		ips = []net.IP{ip}
+	} else {
+		ips, err = hostIPs(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := checkGuardPolicy(currentGuardPolicy(), host, ips); err != nil {
+		return nil, err
+	}
+	dialer := currentDialer()
+	for _, ip := range ips {
+		var conn net.Conn
+		conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// checkGuardPolicy returns an error if the given host, or any of the given IPs
+// it resolves to, is not allowed by the policy.
+func checkGuardPolicy(p GuardPolicy, host string, ips []net.IP) error {
+	if p.DenyList.Matches(host) {
+		return fmt.Errorf("%w: %s is in the deny list", ErrAddressNotAllowed, host)
+	}
+	allowed := p.AllowPrivateIPs || p.AllowList.Matches(host)
+	for _, ip := range ips {
+		if p.DenyList.Matches(ip.String()) {
+			return fmt.Errorf("%w: %s resolves to %s, that is in the deny list", ErrAddressNotAllowed, host, ip)
+		}
+		if allowed || p.AllowList.Matches(ip.String()) {
+			continue
+		}
+		if ok, err := isAllowed(ip.String()); err != nil || !ok {
+			return fmt.Errorf("%w: %s resolves to the private IP %s", ErrAddressNotAllowed, host, ip)
+		}
+	}
+	return nil
+}
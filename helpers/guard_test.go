@@ -0,0 +1,124 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// withGuardPolicy sets the given guard policy and returns a function that
+// restores the previous one.
+func withGuardPolicy(p GuardPolicy) func() {
+	prev := currentGuardPolicy()
+	SetGuardPolicy(p)
+	return func() {
+		SetGuardPolicy(prev)
+	}
+}
+
+func TestGuardedHTTPClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer withResolver(stubResolver(func(host string) ([]net.IPAddr, error) {
+		if host == "multi.example.com" {
+			// Nothing listens in the first IP.
+			return []net.IPAddr{{IP: net.ParseIP("127.0.0.3")}, {IP: net.ParseIP("127.0.0.1")}}, nil
+		}
+		return []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}}, nil
+	}))()
+	denyList, err := NewTargetMatcher([]string{"denied.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowList, err := NewTargetMatcher([]string{"127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostAllowList, err := NewTargetMatcher([]string{"pinned.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		policy  GuardPolicy
+		host    string
+		pin     *Pin
+		wantErr bool
+	}{
+		{
+			name:    "PrivateIP",
+			host:    "127.0.0.1",
+			wantErr: true,
+		},
+		{
+			name:    "ResolvesToPrivateIP",
+			host:    "internal.example.com",
+			wantErr: true,
+		},
+		{
+			name:   "PrivateIPsAllowed",
+			policy: GuardPolicy{AllowPrivateIPs: true},
+			host:   "internal.example.com",
+		},
+		{
+			name:   "AllowListed",
+			policy: GuardPolicy{AllowList: allowList},
+			host:   "internal.example.com",
+		},
+		{
+			name:    "DenyListed",
+			policy:  GuardPolicy{AllowPrivateIPs: true, DenyList: denyList},
+			host:    "denied.example.com",
+			wantErr: true,
+		},
+		{
+			name:    "PinnedDenyListed",
+			policy:  GuardPolicy{AllowPrivateIPs: true, DenyList: denyList},
+			host:    "denied.example.com",
+			pin:     &Pin{Host: "denied.example.com", IP: "127.0.0.1"},
+			wantErr: true,
+		},
+		{
+			name:   "PinnedAllowListed",
+			policy: GuardPolicy{AllowList: hostAllowList},
+			host:   "pinned.example.com",
+			pin:    &Pin{Host: "pinned.example.com", IP: "127.0.0.1"},
+		},
+		{
+			name:   "SeveralIPs",
+			policy: GuardPolicy{AllowPrivateIPs: true},
+			host:   "multi.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer withGuardPolicy(tt.policy)()
+			req, err := http.NewRequest(http.MethodGet, "http://"+net.JoinHostPort(tt.host, port)+"/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client := guardedHTTPClient(2 * time.Second)
+			if tt.pin != nil {
+				client = tt.pin.HTTPClient(2 * time.Second)
+			}
+			resp, err := client.Do(req.WithContext(context.Background()))
+			if err == nil {
+				resp.Body.Close() // nolint
+			}
+			if tt.wantErr && !errors.Is(err, ErrAddressNotAllowed) {
+				t.Errorf("want error %v, got %v", ErrAddressNotAllowed, err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("want no error, got %v", err)
+			}
+		})
+	}
+}
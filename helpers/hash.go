@@ -0,0 +1,50 @@
+package helpers
+
+import (
+	"crypto/md5"  // nolint
+	"crypto/sha1" // nolint
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+)
+
+// Supported hash algorithms.
+const (
+	HashSHA256 = "sha256"
+	HashSHA1   = "sha1"
+	HashMD5    = "md5"
+)
+
+var hashes = map[string]func() hash.Hash{
+	HashSHA256: sha256.New,
+	HashSHA1:   sha1.New,
+	HashMD5:    md5.New,
+}
+
+// HashReader returns the lowercase hex encoded hash of the contents read from
+// the reader using the given algorithm: sha256, sha1 or md5.
+func HashReader(r io.Reader, algo string) (string, error) {
+	newHash, ok := hashes[algo]
+	if !ok {
+		return "", fmt.Errorf("unsupported hash algorithm %q", algo)
+	}
+	h := newHash()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// HashFile returns the lowercase hex encoded hash of the contents of the file
+// in the given path using the given algorithm: sha256, sha1 or md5.
+func HashFile(path, algo string) (string, error) {
+	f, err := os.Open(path) // nolint
+	if err != nil {
+		return "", err
+	}
+	defer f.Close() // nolint
+	return HashReader(f, algo)
+}
@@ -0,0 +1,74 @@
+package helpers
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHashReader(t *testing.T) {
+	content := []byte("vulcan")
+	tests := []struct {
+		name    string
+		algo    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "SHA256",
+			algo: HashSHA256,
+			want: "4e8ff5bd7ff87fe64023989bffa734211cbe7e84488092fd621d5750e89b68c8",
+		},
+		{
+			name: "SHA1",
+			algo: HashSHA1,
+			want: "966032eab6276624119a49080934e3936d2976f7",
+		},
+		{
+			name: "MD5",
+			algo: HashMD5,
+			want: "0c94ea3ecdd57ac44984589682e4be05",
+		},
+		{
+			name:    "Unsupported",
+			algo:    "crc32",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HashReader(bytes.NewReader(content), tt.algo)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("HashReader() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("HashReader() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint
+	path := filepath.Join(dir, "artifact")
+	if err := ioutil.WriteFile(path, []byte("vulcan"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := HashFile(path, HashSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "4e8ff5bd7ff87fe64023989bffa734211cbe7e84488092fd621d5750e89b68c8"
+	if got != want {
+		t.Errorf("HashFile() = %v, want %v", got, want)
+	}
+	if _, err := HashFile(filepath.Join(dir, "notexisting"), HashSHA256); err == nil {
+		t.Errorf("want error hashing a not existing file")
+	}
+}
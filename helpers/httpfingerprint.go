@@ -0,0 +1,120 @@
+package helpers
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	fingerprintTimeout = 10 * time.Second
+	// maxFingerprintBodyBytes is the maximum number of bytes of the body of
+	// the response read by FingerprintHTTP.
+	maxFingerprintBodyBytes = 1 << 20
+)
+
+// SecurityHeaders contains the names of the security headers reported by
+// FingerprintHTTP.
+var SecurityHeaders = []string{
+	"Strict-Transport-Security",
+	"Content-Security-Policy",
+	"X-Frame-Options",
+	"X-Content-Type-Options",
+	"Referrer-Policy",
+	"Permissions-Policy",
+}
+
+// sessionCookieFrameworks maps the names of the session cookies set by some
+// frameworks to the names of the frameworks.
+var sessionCookieFrameworks = map[string]string{
+	"PHPSESSID":         "PHP",
+	"JSESSIONID":        "Java",
+	"ASP.NET_SessionId": "ASP.NET",
+	"laravel_session":   "Laravel",
+	"connect.sid":       "Express",
+}
+
+// Fingerprint contains the technologies and the security headers detected in
+// the response of a web server.
+type Fingerprint struct {
+	// Server is the product reported in the Server header, e.g.: "nginx".
+	Server string
+	// ServerVersion is the version of the product reported in the Server
+	// header, if disclosed, e.g.: "1.18.0".
+	ServerVersion string
+	// Framework is the framework or language detected from the X-Powered-By
+	// and X-AspNet-Version headers or from the name of the session cookies,
+	// e.g.: "PHP".
+	Framework string
+	// FrameworkVersion is the version of the framework, if disclosed.
+	FrameworkVersion string
+	// SecurityHeaders contains the values of the headers in the
+	// SecurityHeaders list present in the response, by their names.
+	SecurityHeaders map[string]string
+	// MissingSecurityHeaders contains the names of the headers in the
+	// SecurityHeaders list not present in the response.
+	MissingSecurityHeaders []string
+}
+
+// FingerprintHTTP sends a GET request to the given URL, using a client that
+// connects through the dialer of the network helpers and doesn't follow
+// redirects, and returns the technologies and the security headers detected
+// in the response.
+func FingerprintHTTP(ctx context.Context, url string) (Fingerprint, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return Fingerprint{}, err
+	}
+	resp, err := guardedHTTPClient(fingerprintTimeout).Do(req.WithContext(ctx))
+	if err != nil {
+		return Fingerprint{}, err
+	}
+	defer resp.Body.Close() // nolint
+	// Drain the body so the request is completed.
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxFingerprintBodyBytes)) // nolint
+	return fingerprintResponse(resp), nil
+}
+
+func fingerprintResponse(resp *http.Response) Fingerprint {
+	var fp Fingerprint
+	fp.Server, fp.ServerVersion = parseProduct(resp.Header.Get("Server"))
+	if powered := resp.Header.Get("X-Powered-By"); powered != "" {
+		fp.Framework, fp.FrameworkVersion = parseProduct(powered)
+	} else if aspnet := resp.Header.Get("X-AspNet-Version"); aspnet != "" {
+		fp.Framework, fp.FrameworkVersion = "ASP.NET", aspnet
+	} else {
+		for _, c := range resp.Cookies() {
+			if framework, ok := sessionCookieFrameworks[c.Name]; ok {
+				fp.Framework = framework
+				break
+			}
+		}
+	}
+	fp.SecurityHeaders = map[string]string{}
+	for _, h := range SecurityHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			fp.SecurityHeaders[h] = v
+		} else {
+			fp.MissingSecurityHeaders = append(fp.MissingSecurityHeaders, h)
+		}
+	}
+	return fp
+}
+
+// parseProduct returns the name and the version of the first product of a
+// header value in the form used by the Server header, e.g.: "nginx/1.18.0
+// (Ubuntu)" is parsed as "nginx" and "1.18.0".
+func parseProduct(value string) (name, version string) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return "", ""
+	}
+	parts := strings.SplitN(fields[0], "/", 2)
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return parts[0], ""
+}
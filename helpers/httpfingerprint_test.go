@@ -0,0 +1,105 @@
+package helpers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFingerprintHTTP(t *testing.T) {
+	defer withGuardPolicy(GuardPolicy{AllowPrivateIPs: true})()
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    Fingerprint
+	}{
+		{
+			name: "ServerAndPoweredBy",
+			headers: map[string]string{
+				"Server":                    "nginx/1.18.0 (Ubuntu)",
+				"X-Powered-By":              "PHP/7.4.3",
+				"Strict-Transport-Security": "max-age=31536000",
+				"X-Frame-Options":           "DENY",
+			},
+			want: Fingerprint{
+				Server:           "nginx",
+				ServerVersion:    "1.18.0",
+				Framework:        "PHP",
+				FrameworkVersion: "7.4.3",
+				SecurityHeaders: map[string]string{
+					"Strict-Transport-Security": "max-age=31536000",
+					"X-Frame-Options":           "DENY",
+				},
+				MissingSecurityHeaders: []string{
+					"Content-Security-Policy",
+					"X-Content-Type-Options",
+					"Referrer-Policy",
+					"Permissions-Policy",
+				},
+			},
+		},
+		{
+			name: "AspNetAndSessionCookie",
+			headers: map[string]string{
+				"Server":           "Microsoft-IIS/10.0",
+				"X-AspNet-Version": "4.0.30319",
+				"Set-Cookie":       "JSESSIONID=1234; Path=/",
+			},
+			want: Fingerprint{
+				Server:           "Microsoft-IIS",
+				ServerVersion:    "10.0",
+				Framework:        "ASP.NET",
+				FrameworkVersion: "4.0.30319",
+				SecurityHeaders:  map[string]string{},
+				MissingSecurityHeaders: []string{
+					"Strict-Transport-Security",
+					"Content-Security-Policy",
+					"X-Frame-Options",
+					"X-Content-Type-Options",
+					"Referrer-Policy",
+					"Permissions-Policy",
+				},
+			},
+		},
+		{
+			name: "SessionCookie",
+			headers: map[string]string{
+				"Server":     "Apache",
+				"Set-Cookie": "laravel_session=abcd; Path=/",
+			},
+			want: Fingerprint{
+				Server:          "Apache",
+				Framework:       "Laravel",
+				SecurityHeaders: map[string]string{},
+				MissingSecurityHeaders: []string{
+					"Strict-Transport-Security",
+					"Content-Security-Policy",
+					"X-Frame-Options",
+					"X-Content-Type-Options",
+					"Referrer-Policy",
+					"Permissions-Policy",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range tt.headers {
+					w.Header().Set(k, v)
+				}
+			}))
+			defer srv.Close()
+			got, err := FingerprintHTTP(context.Background(), srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("fingerprint differs, diff %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package helpers
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const methodsTimeout = 10 * time.Second
+
+// DangerousMethods contains the HTTP methods probed by ProbeDangerousMethods.
+var DangerousMethods = []string{http.MethodPut, http.MethodDelete, http.MethodTrace}
+
+// AllowedMethods sends an OPTIONS request to the given URL, using a client
+// that connects through the dialer of the network helpers and doesn't follow
+// redirects, and returns the methods listed in the Allow header of the
+// response, uppercased and without duplicates, in the order they are listed.
+// It returns no methods if the response doesn't contain the header.
+func AllowedMethods(ctx context.Context, url string) ([]string, error) {
+	resp, err := doMethod(ctx, http.MethodOptions, url)
+	if err != nil {
+		return nil, err
+	}
+	var methods []string
+	seen := map[string]bool{}
+	for _, value := range resp.Header.Values("Allow") {
+		for _, m := range strings.Split(value, ",") {
+			m = strings.ToUpper(strings.TrimSpace(m))
+			if m == "" || seen[m] {
+				continue
+			}
+			seen[m] = true
+			methods = append(methods, m)
+		}
+	}
+	return methods, nil
+}
+
+// ProbeDangerousMethods sends a request with each of the DangerousMethods and
+// returns the ones the web server accepts, that is, the ones whose response
+// has a 2xx status code, regardless of what the Allow header reports. In order
+// to not modify existing resources, the PUT and DELETE requests are sent to a
+// random, non existent, resource in the same path of the given URL, while the
+// TRACE request is sent to the URL. Take into account that this is an active
+// probe: if the PUT method is accepted, an empty resource is created in the
+// web server.
+func ProbeDangerousMethods(ctx context.Context, rawURL string) ([]string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	label, err := randomLabel()
+	if err != nil {
+		return nil, err
+	}
+	// The random resource is resolved relative to the URL, so it replaces
+	// the last segment of its path.
+	random := u.ResolveReference(&url.URL{Path: "vulcan-" + label}).String()
+	var accepted []string
+	for _, m := range DangerousMethods {
+		target := random
+		if m == http.MethodTrace {
+			target = rawURL
+		}
+		resp, err := doMethod(ctx, m, target)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			accepted = append(accepted, m)
+		}
+	}
+	return accepted, nil
+}
+
+// doMethod sends a request with the given method and no body to the URL and
+// returns the response, whose body is already drained and closed.
+func doMethod(ctx context.Context, method, url string) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := guardedHTTPClient(methodsTimeout).Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxFingerprintBodyBytes)) // nolint
+	resp.Body.Close()                                                           // nolint
+	return resp, nil
+}
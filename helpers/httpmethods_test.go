@@ -0,0 +1,86 @@
+package helpers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAllowedMethods(t *testing.T) {
+	defer withGuardPolicy(GuardPolicy{AllowPrivateIPs: true})()
+	tests := []struct {
+		name  string
+		allow []string
+		want  []string
+	}{
+		{
+			name:  "ParsesAllowHeader",
+			allow: []string{"GET, head,OPTIONS", "POST, GET"},
+			want:  []string{"GET", "HEAD", "OPTIONS", "POST"},
+		},
+		{
+			name: "NoAllowHeader",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodOptions {
+					t.Errorf("want method %s, got %s", http.MethodOptions, r.Method)
+				}
+				for _, v := range tt.allow {
+					w.Header().Add("Allow", v)
+				}
+			}))
+			defer srv.Close()
+			got, err := AllowedMethods(context.Background(), srv.URL+"/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("allowed methods differ, diff %s", diff)
+			}
+		})
+	}
+}
+
+func TestProbeDangerousMethods(t *testing.T) {
+	defer withGuardPolicy(GuardPolicy{AllowPrivateIPs: true})()
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		switch r.Method {
+		case http.MethodPut:
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodTrace:
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer srv.Close()
+	got, err := ProbeDangerousMethods(context.Background(), srv.URL+"/app/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{http.MethodPut, http.MethodTrace}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("accepted methods differ, diff %s", diff)
+	}
+	if len(paths) != 3 {
+		t.Fatalf("want 3 requests, got %v", paths)
+	}
+	// The PUT and DELETE requests must not target the existing resource.
+	for _, p := range paths[:2] {
+		if !strings.Contains(p, " /app/vulcan-") {
+			t.Errorf("want a random resource in the path of the URL requested, got %s", p)
+		}
+	}
+	if paths[2] != "TRACE /app/index.html" {
+		t.Errorf("want the URL requested with TRACE, got %s", paths[2])
+	}
+}
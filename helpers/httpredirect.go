@@ -1,7 +1,6 @@
 package helpers
 
 import (
-	"net/http"
 	"strings"
 )
 
@@ -13,7 +12,7 @@ const (
 // walkHTTPRedirects sends a request to the given url, follows up to 10 redirects
 // and returns the hostname of the last one.
 func walkHTTPRedirects(url string) (string, error) {
-	client := http.DefaultClient
+	client := httpClient()
 	resp, err := client.Get(url)
 	if err != nil {
 		return "", err
@@ -55,6 +55,12 @@ func buildRedirector(m redirectMap) *httptest.Server {
 }
 
 func TestIsRedirectingTo(t *testing.T) {
+	// The helpers resolve the hosts by their own, so they are resolved to
+	// the IP of the redirector.
+	defer withResolver(stubResolver(func(host string) ([]net.IPAddr, error) {
+		return []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}}, nil
+	}))()
+	defer withGuardPolicy(GuardPolicy{AllowPrivateIPs: true})()
 	type args struct {
 		addr   string
 		domain string
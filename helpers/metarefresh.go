@@ -0,0 +1,118 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxRedirectBodyBytes defines the maximum number of bytes of the body of a
+	// response read when looking for redirects.
+	maxRedirectBodyBytes = 1 << 20
+	// maxHTTPRedirects defines the maximum number of HTTP redirects followed
+	// by FollowMetaRefresh in each request.
+	maxHTTPRedirects = 10
+	// MaxMetaRefreshHops defines the maximum number of meta-refresh or
+	// JavaScript redirects followed by FollowMetaRefresh, whatever the maxHops
+	// requested.
+	MaxMetaRefreshHops = 20
+	// MaxMetaRefreshDelay defines the maximum delay, in seconds, of the
+	// meta-refresh redirects followed by FollowMetaRefresh. The pages
+	// refreshed after a longer delay are considered final pages.
+	MaxMetaRefreshDelay = 10
+)
+
+// ErrTooManyHops is returned by FollowMetaRefresh when the number of
+// meta-refresh or JavaScript redirects exceeds the maximum allowed.
+var ErrTooManyHops = errors.New("too many meta-refresh or javascript redirects")
+
+var (
+	metaRefreshRegex = regexp.MustCompile(`(?is)<meta[^>]+http-equiv\s*=\s*["']?refresh["']?[^>]*>`)
+	metaContentRegex = regexp.MustCompile(`(?is)content\s*=\s*["']?\s*(\d*(?:\.\d*)?)\s*;\s*url\s*=\s*['"]?([^"'>\s]+)`)
+	jsRedirectRegex  = regexp.MustCompile(`(?is)(?:window\.|document\.)?location(?:\.href)?\s*=\s*["']([^"']+)["']|location\.(?:replace|assign)\(\s*["']([^"']+)["']\s*\)`)
+)
+
+// FollowMetaRefresh sends a request to the given url and follows the HTTP
+// redirects, and the HTML meta-refresh and common JavaScript redirects found in
+// the body of the responses, up to maxHops meta-refresh or JavaScript
+// redirects. It returns the last url loaded and the urls reached by following
+// meta-refresh or JavaScript redirects, in order. If the number of those
+// redirects exceeds maxHops, or MaxMetaRefreshHops if lower, it returns the
+// last url loaded and ErrTooManyHops. The meta-refresh redirects with a delay
+// greater than MaxMetaRefreshDelay are not followed, and at most
+// maxHTTPRedirects HTTP redirects are followed in each request. Each request,
+// including its HTTP redirects, times out after the timeout of the http
+// helpers.
+func FollowMetaRefresh(rawurl string, maxHops int) (finalURL string, hops []string, err error) {
+	if maxHops < 0 || maxHops > MaxMetaRefreshHops {
+		maxHops = MaxMetaRefreshHops
+	}
+	client := httpClient()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxHTTPRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxHTTPRedirects)
+		}
+		return nil
+	}
+	current := rawurl
+	for {
+		resp, err := client.Get(current)
+		if err != nil {
+			return current, hops, err
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxRedirectBodyBytes))
+		resp.Body.Close() // nolint
+		if err != nil {
+			return current, hops, err
+		}
+		current = resp.Request.URL.String()
+		next := findBodyRedirect(string(body))
+		if next == "" {
+			return current, hops, nil
+		}
+		nextURL, err := resp.Request.URL.Parse(next)
+		if err != nil {
+			return current, hops, err
+		}
+		if len(hops) >= maxHops {
+			return current, hops, ErrTooManyHops
+		}
+		current = nextURL.String()
+		hops = append(hops, current)
+	}
+}
+
+// findBodyRedirect returns the target of the first meta-refresh, with a delay
+// not greater than MaxMetaRefreshDelay, or, if there isn't any, JavaScript
+// redirect found in the given HTML.
+func findBodyRedirect(body string) string {
+	if meta := metaRefreshRegex.FindString(body); meta != "" {
+		if m := metaContentRegex.FindStringSubmatch(meta); len(m) > 2 && metaRefreshDelayAllowed(m[1]) {
+			return strings.TrimSpace(m[2])
+		}
+	}
+	m := jsRedirectRegex.FindStringSubmatch(body)
+	if len(m) < 3 {
+		return ""
+	}
+	if m[1] != "" {
+		return m[1]
+	}
+	return m[2]
+}
+
+// metaRefreshDelayAllowed returns true if the given delay of a meta-refresh,
+// which is 0 when empty, is not greater than MaxMetaRefreshDelay.
+func metaRefreshDelayAllowed(delay string) bool {
+	if delay == "" || delay == "." {
+		return true
+	}
+	d, err := strconv.ParseFloat(delay, 64)
+	return err == nil && d <= MaxMetaRefreshDelay
+}
@@ -0,0 +1,86 @@
+package helpers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func buildMetaRefreshServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><meta http-equiv="refresh" content="0; url=/next"></head></html>`)
+	})
+	mux.HandleFunc("/next", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/js", http.StatusFound)
+	})
+	mux.HandleFunc("/js", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><script>window.location.href = "/final";</script></html>`)
+	})
+	mux.HandleFunc("/final", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body>final</body></html>`)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<meta http-equiv="refresh" content="3600; url=/final">`)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<meta http-equiv='Refresh' content='1;URL=/loop'>`)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestFollowMetaRefresh(t *testing.T) {
+	defer withGuardPolicy(GuardPolicy{AllowPrivateIPs: true})()
+	srv := buildMetaRefreshServer()
+	defer srv.Close()
+
+	final, hops, err := FollowMetaRefresh(srv.URL+"/", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := srv.URL + "/final"; final != want {
+		t.Errorf("want final url %s, got %s", want, final)
+	}
+	wantHops := []string{srv.URL + "/next", srv.URL + "/final"}
+	if !reflect.DeepEqual(hops, wantHops) {
+		t.Errorf("want hops %v, got %v", wantHops, hops)
+	}
+}
+
+func TestFollowMetaRefreshTooManyHops(t *testing.T) {
+	defer withGuardPolicy(GuardPolicy{AllowPrivateIPs: true})()
+	srv := buildMetaRefreshServer()
+	defer srv.Close()
+
+	_, hops, err := FollowMetaRefresh(srv.URL+"/loop", 3)
+	if err != ErrTooManyHops {
+		t.Errorf("want error %v, got %v", ErrTooManyHops, err)
+	}
+	if len(hops) != 3 {
+		t.Errorf("want 3 hops, got %v", hops)
+	}
+}
+
+func TestFollowMetaRefreshLimits(t *testing.T) {
+	defer withGuardPolicy(GuardPolicy{AllowPrivateIPs: true})()
+	srv := buildMetaRefreshServer()
+	defer srv.Close()
+
+	_, hops, err := FollowMetaRefresh(srv.URL+"/loop", 1000)
+	if err != ErrTooManyHops {
+		t.Errorf("want error %v, got %v", ErrTooManyHops, err)
+	}
+	if len(hops) != MaxMetaRefreshHops {
+		t.Errorf("want %d hops, got %d", MaxMetaRefreshHops, len(hops))
+	}
+
+	final, hops, err := FollowMetaRefresh(srv.URL+"/slow", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := srv.URL + "/slow"; final != want || len(hops) != 0 {
+		t.Errorf("want the meta-refresh with a long delay not followed, got final url %s and hops %v", final, hops)
+	}
+}
@@ -0,0 +1,273 @@
+package nmap
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/adevinta/vulcan-check-sdk/state"
+	gonmap "github.com/lair-framework/go-nmap"
+)
+
+type concurrentRunner struct {
+	runners []NmapRunner
+	state   state.State
+
+	mu       sync.Mutex
+	progress []float32
+}
+
+// NewNmapConcurrentCheck creates a new nmap check that scans the given TCP
+// port range, e.g.: "1-1024" or "22,80,8000-8100", by splitting it in as many
+// chunks as the concurrency level and running one nmap process per chunk
+// concurrently. The progress reported is the average of the progress of the
+// chunks. The Run method of the returned runner merges the hosts and ports of
+// the reports of all the chunks in one report and returns a nil raw output, as
+// the outputs of the chunks can not be merged. The option WithRawOutputFile
+// should not be used with this runner because the output of all the chunks
+// would be written to the same file.
+func NewNmapConcurrentCheck(target string, s state.State, timing int, portRange string, concurrency int, opts ...Option) (NmapRunner, error) {
+	ports, err := parsePortRange(portRange)
+	if err != nil {
+		return nil, err
+	}
+	if concurrency < 1 {
+		return nil, fmt.Errorf("invalid concurrency level %d", concurrency)
+	}
+	chunks := splitPorts(ports, concurrency)
+	r := &concurrentRunner{
+		state:    s,
+		progress: make([]float32, len(chunks)),
+	}
+	for i, chunk := range chunks {
+		chunkState := state.State{
+			ResultData:       s.ResultData,
+			ProgressReporter: r.chunkProgress(i),
+		}
+		r.runners = append(r.runners, NewNmapTCPCheck(target, chunkState, timing, []string{chunk}, opts...))
+	}
+	return r, nil
+}
+
+func (r *concurrentRunner) chunkProgress(chunk int) state.ProgressReporterHandler {
+	return func(progress float32) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.progress[chunk] = progress
+		var total float32
+		for _, p := range r.progress {
+			total += p
+		}
+		if r.state.ProgressReporter != nil {
+			r.state.SetProgress(total / float32(len(r.progress)))
+		}
+	}
+}
+
+func (r *concurrentRunner) Run(ctx context.Context) (report *gonmap.NmapRun, rawOutput *[]byte, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	reports := make([]*gonmap.NmapRun, len(r.runners))
+	errs := make([]error, len(r.runners))
+	var wg sync.WaitGroup
+	for i, runner := range r.runners {
+		wg.Add(1)
+		go func(i int, runner NmapRunner) {
+			defer wg.Done()
+			reports[i], _, errs[i] = runner.Run(ctx)
+			if errs[i] != nil {
+				// There is no point in continuing with the other chunks.
+				cancel()
+			}
+		}(i, runner)
+	}
+	wg.Wait()
+	// Return the error that caused the cancellation of the other chunks, if
+	// any, instead of the cancellation itself.
+	var firstErr error
+	for _, err := range errs {
+		if err != nil && (firstErr == nil || firstErr == context.Canceled) {
+			firstErr = err
+		}
+	}
+	// The partial results of the chunks are returned even if some of them
+	// failed.
+	return MergeRuns(reports...), nil, firstErr
+}
+
+// MergeRuns merges the given nmap reports in one. The hosts with the same
+// address are merged in one host containing the ports found in all the
+// reports. The rest of the information of the merged report is taken from the
+// first report.
+func MergeRuns(runs ...*gonmap.NmapRun) *gonmap.NmapRun {
+	var merged *gonmap.NmapRun
+	hosts := map[string]int{}
+	for _, run := range runs {
+		if run == nil {
+			continue
+		}
+		if merged == nil {
+			m := *run
+			m.Hosts = nil
+			merged = &m
+		}
+		if run.RunStats.Finished.Elapsed > merged.RunStats.Finished.Elapsed {
+			merged.RunStats.Finished.Elapsed = run.RunStats.Finished.Elapsed
+		}
+		for _, h := range run.Hosts {
+			key := hostKey(h)
+			i, ok := hosts[key]
+			if !ok {
+				h.Ports = append([]gonmap.Port{}, h.Ports...)
+				hosts[key] = len(merged.Hosts)
+				merged.Hosts = append(merged.Hosts, h)
+				continue
+			}
+			mergeHost(&merged.Hosts[i], h)
+		}
+	}
+	if merged == nil {
+		return nil
+	}
+	up := 0
+	for _, h := range merged.Hosts {
+		if h.Status.State == "up" {
+			up++
+		}
+	}
+	merged.RunStats.Hosts.Up = up
+	merged.RunStats.Hosts.Down = len(merged.Hosts) - up
+	merged.RunStats.Hosts.Total = len(merged.Hosts)
+	return merged
+}
+
+func mergeHost(dst *gonmap.Host, src gonmap.Host) {
+	if src.Status.State == "up" {
+		dst.Status = src.Status
+	}
+	for _, p := range src.Ports {
+		found := false
+		for _, existing := range dst.Ports {
+			if existing.Protocol == p.Protocol && existing.PortId == p.PortId {
+				found = true
+				break
+			}
+		}
+		if !found {
+			dst.Ports = append(dst.Ports, p)
+		}
+	}
+}
+
+func hostKey(h gonmap.Host) string {
+	addrs := make([]string, 0, len(h.Addresses))
+	for _, a := range h.Addresses {
+		addrs = append(addrs, a.Addr)
+	}
+	return strings.Join(addrs, ",")
+}
+
+// parsePortRange returns the ports contained in a port range in the format
+// accepted by the -p flag of nmap without protocol specifiers, e.g.:
+// "22,80,8000-8100".
+func parsePortRange(portRange string) ([]int, error) {
+	var ports []int
+	seen := map[int]bool{}
+	for _, part := range strings.Split(portRange, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		bounds := strings.SplitN(part, "-", 2)
+		first, err := parsePort(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+		last := first
+		if len(bounds) == 2 {
+			last, err = parsePort(bounds[1])
+			if err != nil {
+				return nil, err
+			}
+		}
+		if last < first {
+			return nil, fmt.Errorf("invalid port range %q", part)
+		}
+		for p := first; p <= last; p++ {
+			if !seen[p] {
+				seen[p] = true
+				ports = append(ports, p)
+			}
+		}
+	}
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("empty port range %q", portRange)
+	}
+	return ports, nil
+}
+
+// ParsePortSpec returns the ports contained in a port spec in the format
+// accepted by the -p flag of nmap without protocol specifiers, e.g.:
+// "1-1024,3389". The ranges are expanded and the duplicated ports removed, so
+// the returned ports can be passed to the nmap check constructors.
+func ParsePortSpec(spec string) ([]string, error) {
+	ports, err := parsePortRange(spec)
+	if err != nil {
+		return nil, err
+	}
+	specPorts := make([]string, 0, len(ports))
+	for _, p := range ports {
+		specPorts = append(specPorts, strconv.Itoa(p))
+	}
+	return specPorts, nil
+}
+
+func parsePort(port string) (int, error) {
+	p, err := strconv.Atoi(strings.TrimSpace(port))
+	if err != nil || p < 1 || p > 65535 {
+		return 0, fmt.Errorf("invalid port %q", port)
+	}
+	return p, nil
+}
+
+// splitPorts splits the ports in, at most, n chunks of similar size and
+// returns each chunk in the format accepted by the -p flag of nmap.
+func splitPorts(ports []int, n int) []string {
+	if n > len(ports) {
+		n = len(ports)
+	}
+	var chunks []string
+	size := len(ports) / n
+	extra := len(ports) % n
+	start := 0
+	for i := 0; i < n; i++ {
+		end := start + size
+		if i < extra {
+			end++
+		}
+		chunks = append(chunks, formatPorts(ports[start:end]))
+		start = end
+	}
+	return chunks
+}
+
+// formatPorts formats the ports compressing the consecutive ones in ranges.
+func formatPorts(ports []int) string {
+	var parts []string
+	for i := 0; i < len(ports); {
+		j := i
+		for j+1 < len(ports) && ports[j+1] == ports[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.Itoa(ports[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", ports[i], ports[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
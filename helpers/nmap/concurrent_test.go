@@ -0,0 +1,181 @@
+package nmap
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adevinta/vulcan-check-sdk/state"
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeNmapEnv is the env var that makes the test binary behave as the fake
+// nmap implemented by TestFakeNmap.
+const fakeNmapEnv = "VULCAN_CHECK_SDK_FAKE_NMAP"
+
+// fakePortsNmap replaces the nmap binary with a script that runs the test
+// binary as the fake nmap implemented by TestFakeNmap.
+func fakePortsNmap(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "fakenmap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(dir, "nmap")
+	content := fmt.Sprintf("#!/bin/sh\n%s=1 exec %q -test.run='^TestFakeNmap$' -- \"$@\"\n", fakeNmapEnv, os.Args[0])
+	if err := ioutil.WriteFile(script, []byte(content), 0700); err != nil {
+		t.Fatal(err)
+	}
+	prev := nmapFile
+	nmapFile = script
+	return func() {
+		nmapFile = prev
+		os.RemoveAll(dir) // nolint
+	}
+}
+
+// TestFakeNmap is not a test but a fake nmap, run through the script written
+// by fakePortsNmap, that writes an XML report with the ports in the -p flag it
+// receives that accept TCP connections in the target, the argument that is an
+// IP, as open, and the rest as closed.
+func TestFakeNmap(t *testing.T) {
+	if os.Getenv(fakeNmapEnv) != "1" {
+		t.Skip("only run as a fake nmap")
+	}
+	var args []string
+	for i, arg := range os.Args {
+		if arg == "--" {
+			args = os.Args[i+1:]
+			break
+		}
+	}
+	var portRange, target string
+	for i, arg := range args {
+		if arg == "-p" && i+1 < len(args) {
+			portRange = args[i+1]
+		}
+		if net.ParseIP(arg) != nil {
+			target = arg
+		}
+	}
+	ports, err := parsePortRange(portRange)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println(`<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Println(`<nmaprun scanner="nmap" args="nmap" version="7.01" xmloutputversion="1.04">`)
+	fmt.Println(`<taskprogress task="Connect Scan" time="1" percent="50.00" remaining="1" etc="1"/>`)
+	fmt.Println(`<host><status state="up" reason="conn-refused" reason_ttl="0"/>`)
+	fmt.Printf("<address addr=%q addrtype=\"ipv4\"/><ports>\n", target)
+	for _, p := range ports {
+		state := "closed"
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(target, strconv.Itoa(p)), time.Second)
+		if err == nil {
+			conn.Close() // nolint
+			state = "open"
+		}
+		fmt.Printf("<port protocol=\"tcp\" portid=\"%d\"><state state=%q reason=\"syn-ack\" reason_ttl=\"0\"/></port>\n", p, state)
+	}
+	fmt.Println(`</ports></host>`)
+	fmt.Println(`<runstats><finished time="1" elapsed="0.03" exit="success"/><hosts up="1" down="0" total="1"/></runstats>`)
+	fmt.Println(`</nmaprun>`)
+	os.Exit(0)
+}
+
+type progressRecorder struct {
+	mu       sync.Mutex
+	progress []float32
+}
+
+func (p *progressRecorder) SetProgress(progress float32) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.progress = append(p.progress, progress)
+}
+
+func TestNmapConcurrentCheck(t *testing.T) {
+	restore := fakePortsNmap(t)
+	defer restore()
+
+	// Listen in two ports so the port range contains ports in use, and add to
+	// the range a port not in use.
+	var wantPorts []int
+	for i := 0; i < 2; i++ {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close() // nolint
+		wantPorts = append(wantPorts, ln.Addr().(*net.TCPAddr).Port)
+	}
+	sort.Ints(wantPorts)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := ln.Addr().(*net.TCPAddr).Port
+	ln.Close() // nolint
+	portRange := strconv.Itoa(wantPorts[0]) + "," + strconv.Itoa(wantPorts[1]) + "," + strconv.Itoa(closedPort)
+
+	recorder := &progressRecorder{}
+	s := state.State{ProgressReporter: recorder}
+	runner, err := NewNmapConcurrentCheck("127.0.0.1", s, 0, portRange, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(runner.(*concurrentRunner).runners); got != 2 {
+		t.Fatalf("want 2 chunks, got %d", got)
+	}
+	report, _, err := runner.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Hosts) != 1 {
+		t.Fatalf("want 1 host in the merged report, got %d", len(report.Hosts))
+	}
+	var gotPorts []int
+	for _, p := range report.Hosts[0].Ports {
+		if p.State.State == "open" {
+			gotPorts = append(gotPorts, p.PortId)
+		}
+	}
+	sort.Ints(gotPorts)
+	if diff := cmp.Diff(wantPorts, gotPorts); diff != "" {
+		t.Errorf("ports in the merged report differ, diff %s", diff)
+	}
+	if report.RunStats.Hosts.Total != 1 || report.RunStats.Hosts.Up != 1 {
+		t.Errorf("want 1 host up in the merged stats, got %+v", report.RunStats.Hosts)
+	}
+	// Each chunk reports a progress of 50%, so the aggregated progress must
+	// be 25% after the first chunk reports it and 50% after both have done.
+	want := []float32{25, 50}
+	if diff := cmp.Diff(want, recorder.progress); diff != "" {
+		t.Errorf("aggregated progress differs, diff %s", diff)
+	}
+}
+
+func TestSplitPorts(t *testing.T) {
+	ports, err := parsePortRange("1-5,10,12-13,3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := splitPorts(ports, 3)
+	want := []string{"1-3", "4-5,10", "12-13"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("chunks differ, diff %s", diff)
+	}
+	if _, err := parsePortRange("10-1"); err == nil {
+		t.Errorf("want error parsing an inverted port range")
+	}
+	if _, err := parsePortRange("0,70000"); err == nil {
+		t.Errorf("want error parsing invalid ports")
+	}
+}
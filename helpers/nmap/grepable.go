@@ -0,0 +1,125 @@
+package nmap
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"strconv"
+	"strings"
+
+	gonmap "github.com/lair-framework/go-nmap"
+)
+
+// ParseGrepable parses the grepable output of nmap, the one written with the
+// flag -oG, and returns whatever it can extract from it: the status, the
+// addresses and the hostnames of the hosts, their ports and the number of
+// ports aggregated in the "Ignored State" fields. The lines that can not be
+// parsed are skipped, so truncated outputs can be parsed.
+func ParseGrepable(content []byte) *gonmap.NmapRun {
+	run := &gonmap.NmapRun{}
+	hosts := map[string]int{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "Host: ") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		addr, name := parseGrepableHost(strings.TrimPrefix(fields[0], "Host: "))
+		if addr == "" {
+			continue
+		}
+		i, ok := hosts[addr]
+		if !ok {
+			h := gonmap.Host{Addresses: []gonmap.Address{{Addr: addr, AddrType: addrType(addr)}}}
+			if name != "" {
+				h.Hostnames = []gonmap.Hostname{{Name: name, Type: "PTR"}}
+			}
+			run.Hosts = append(run.Hosts, h)
+			i = len(run.Hosts) - 1
+			hosts[addr] = i
+		}
+		h := &run.Hosts[i]
+		for _, f := range fields[1:] {
+			switch {
+			case strings.HasPrefix(f, "Status: "):
+				h.Status.State = strings.ToLower(strings.TrimPrefix(f, "Status: "))
+			case strings.HasPrefix(f, "Ports: "):
+				for _, p := range strings.Split(strings.TrimPrefix(f, "Ports: "), ", ") {
+					if port, ok := parseGrepablePort(p); ok {
+						h.Ports = append(h.Ports, port)
+					}
+				}
+			case strings.HasPrefix(f, "Ignored State: "):
+				if extra, ok := parseGrepableIgnored(strings.TrimPrefix(f, "Ignored State: ")); ok {
+					h.ExtraPorts = append(h.ExtraPorts, extra)
+				}
+			}
+		}
+	}
+	return run
+}
+
+// parseGrepableHost parses the value of the "Host" field, e.g.:
+// "10.0.0.1 (www.example.com)".
+func parseGrepableHost(value string) (addr, name string) {
+	parts := strings.SplitN(value, " ", 2)
+	addr = parts[0]
+	if net.ParseIP(addr) == nil {
+		return "", ""
+	}
+	if len(parts) == 2 {
+		name = strings.TrimSuffix(strings.TrimPrefix(parts[1], "("), ")")
+	}
+	return addr, name
+}
+
+func addrType(addr string) string {
+	if strings.Contains(addr, ":") {
+		return "ipv6"
+	}
+	return "ipv4"
+}
+
+// parseGrepablePort parses a port of the "Ports" field, in the form
+// port/state/protocol/owner/service/rpc info/version/, e.g.:
+// "22/open/tcp//ssh//OpenSSH 7.4/".
+func parseGrepablePort(value string) (gonmap.Port, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "/")
+	if len(parts) < 3 {
+		return gonmap.Port{}, false
+	}
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return gonmap.Port{}, false
+	}
+	port := gonmap.Port{
+		PortId:   id,
+		State:    gonmap.State{State: parts[1]},
+		Protocol: parts[2],
+	}
+	if len(parts) > 3 {
+		port.Owner = gonmap.Owner{Name: parts[3]}
+	}
+	if len(parts) > 4 {
+		port.Service.Name = parts[4]
+	}
+	if len(parts) > 6 {
+		port.Service.Product = parts[6]
+	}
+	return port, true
+}
+
+// parseGrepableIgnored parses the value of the "Ignored State" field, e.g.:
+// "filtered (997)".
+func parseGrepableIgnored(value string) (gonmap.ExtraPorts, bool) {
+	parts := strings.SplitN(value, " ", 2)
+	if len(parts) != 2 {
+		return gonmap.ExtraPorts{}, false
+	}
+	count, err := strconv.Atoi(strings.Trim(parts[1], "()"))
+	if err != nil {
+		return gonmap.ExtraPorts{}, false
+	}
+	return gonmap.ExtraPorts{State: parts[0], Count: count}, true
+}
@@ -0,0 +1,96 @@
+package nmap
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/adevinta/vulcan-check-sdk/state"
+	"github.com/google/go-cmp/cmp"
+	gonmap "github.com/lair-framework/go-nmap"
+)
+
+func TestParseGrepable(t *testing.T) {
+	contents, err := ioutil.ReadFile("testdata/NmapGrepableOutput.gnmap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := ParseGrepable(contents)
+	want := []gonmap.Host{
+		{
+			Status:    gonmap.Status{State: "up"},
+			Addresses: []gonmap.Address{{Addr: "10.0.0.1", AddrType: "ipv4"}},
+			Hostnames: []gonmap.Hostname{{Name: "www.example.com", Type: "PTR"}},
+			Ports: []gonmap.Port{
+				{Protocol: "tcp", PortId: 22, State: gonmap.State{State: "open"}, Service: gonmap.Service{Name: "ssh", Product: "OpenSSH 7.4"}},
+				{Protocol: "tcp", PortId: 80, State: gonmap.State{State: "open"}, Service: gonmap.Service{Name: "http"}},
+				{Protocol: "tcp", PortId: 443, State: gonmap.State{State: "closed"}, Service: gonmap.Service{Name: "https"}},
+			},
+			ExtraPorts: []gonmap.ExtraPorts{{State: "filtered", Count: 997}},
+		},
+		{
+			Status:    gonmap.Status{State: "up"},
+			Addresses: []gonmap.Address{{Addr: "10.0.0.2", AddrType: "ipv4"}},
+			Ports: []gonmap.Port{
+				{Protocol: "udp", PortId: 53, State: gonmap.State{State: "open|filtered"}, Service: gonmap.Service{Name: "domain"}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(want, run.Hosts) {
+		t.Errorf("ParseGrepable() hosts != want\nwant: %+v\ngot:  %+v", want, run.Hosts)
+	}
+}
+
+func TestRunnerWithGrepableFallback(t *testing.T) {
+	grepable, err := filepath.Abs("testdata/NmapGrepableOutput.gnmap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "fakenmap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint
+	// The script writes the grepable output to the file given in the -oG flag
+	// and a truncated XML output to the standard output.
+	script := filepath.Join(dir, "nmap")
+	contents := fmt.Sprintf(`#!/bin/sh
+while [ $# -gt 0 ]; do
+	if [ "$1" = "-oG" ]; then
+		cp %s "$2"
+	fi
+	shift
+done
+echo '<?xml version="1.0" encoding="UTF-8"?><nmaprun scanner="nmap"><host>'
+`, grepable)
+	if err := ioutil.WriteFile(script, []byte(contents), 0700); err != nil {
+		t.Fatal(err)
+	}
+	prev := nmapFile
+	nmapFile = script
+	defer func() { nmapFile = prev }()
+
+	s := state.State{
+		ProgressReporter: stateMock{},
+	}
+	r := NewNmapTCPCheck("10.0.0.1", s, 0, []string{"1-1000"})
+	if _, _, err := r.Run(context.Background()); err == nil {
+		t.Fatal("want error parsing the truncated XML output without fallback")
+	}
+	r = NewNmapTCPCheck("10.0.0.1", s, 0, []string{"1-1000"}, WithGrepableFallback())
+	report, _, err := r.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []HostPortSummary{
+		{Host: "10.0.0.1", Open: 2, Closed: 1, Filtered: 997},
+		{Host: "10.0.0.2", Filtered: 1},
+	}
+	if diff := cmp.Diff(want, PortSummary(report)); diff != "" {
+		t.Errorf("PortSummary() != want, diff %s", diff)
+	}
+}
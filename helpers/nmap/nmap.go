@@ -4,10 +4,14 @@ package nmap
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	check "github.com/adevinta/vulcan-check-sdk"
 	"github.com/adevinta/vulcan-check-sdk/state"
@@ -25,28 +29,162 @@ var (
 	defaultTiming = 3
 )
 
-// NmapRunner executes an Nmap.
+// ErrNoPortsToScan is returned by the Run method of the runners created with
+// NewNmapTCPUDPCheck when none of the given ports can be scanned.
+var ErrNoPortsToScan = errors.New("no ports to scan")
+
+// NmapRunner executes an Nmap. When the execution of nmap fails, Run returns
+// the report parsed from the output written by nmap before failing, if any,
+// together with the error.
 type NmapRunner interface {
 	Run(ctx context.Context) (report *gonmap.NmapRun, rawOutput *[]byte, err error)
 }
 
+// Option defines a function that sets an optional behavior of a NmapRunner.
+type Option func(r *runner)
+
+// WithRawOutputFile makes the runner write the raw XML output of nmap to the
+// given file path after the execution of the process, even if the output can
+// not be parsed.
+func WithRawOutputFile(path string) Option {
+	return func(r *runner) {
+		r.rawOutputFile = path
+	}
+}
+
+// WithHostTimeout makes nmap give up on a target after the given time by
+// setting the flag --host-timeout. The flag takes precedence over the same flag
+// specified in the options passed to the constructors. A negative duration
+// makes Run return an error without executing nmap.
+func WithHostTimeout(d time.Duration) Option {
+	return func(r *runner) {
+		if d < 0 {
+			r.optsErr = fmt.Errorf("invalid nmap host timeout: %v", d)
+			return
+		}
+		timeout := fmt.Sprintf("%dms", d/time.Millisecond)
+		if d%time.Second == 0 {
+			timeout = fmt.Sprintf("%ds", d/time.Second)
+		}
+		r.flags["--host-timeout"] = timeout
+	}
+}
+
+// WithMaxRetries limits the number of port scan probe retransmissions nmap
+// performs by setting the flag --max-retries. The flag takes precedence over
+// the same flag specified in the options passed to the constructors. A
+// negative number makes Run return an error without executing nmap.
+func WithMaxRetries(n int) Option {
+	return func(r *runner) {
+		if n < 0 {
+			r.optsErr = fmt.Errorf("invalid nmap max retries: %d", n)
+			return
+		}
+		r.flags["--max-retries"] = strconv.Itoa(n)
+	}
+}
+
+// ProgressEvent contains the progress information of a task of nmap, as
+// reported in the taskprogress elements of its output.
+type ProgressEvent struct {
+	// Task is the name of the task, e.g.: "Connect Scan".
+	Task string
+	// Percent is the percentage of the task completed.
+	Percent float32
+	// Remaining is the estimated number of seconds remaining to complete the
+	// task.
+	Remaining int
+	// ETC is the estimated time of completion of the task. It's zero if nmap
+	// doesn't report it.
+	ETC time.Time
+}
+
+// WithProgressHandler makes the runner call the given function with
+// the progress events parsed from the output of nmap, in addition to setting
+// the progress in the state of the check. The concurrent runner calls the
+// function from each of the nmap processes it runs, so the function must be
+// safe to be called concurrently.
+func WithProgressHandler(f func(ProgressEvent)) Option {
+	return func(r *runner) {
+		r.progressHandler = f
+	}
+}
+
+// WithGrepableFallback makes nmap also write its grepable output, setting the
+// flag -oG, to a temporary file, so when the XML output can not be parsed,
+// e.g.: because the scan was interrupted, the runner returns the report
+// parsed from the grepable output with ParseGrepable, which contains the
+// hosts and the ports nmap was able to report, instead of the parsing error.
+func WithGrepableFallback() Option {
+	return func(r *runner) {
+		r.grepableFallback = true
+	}
+}
+
+var (
+	taskProgressRegex = regexp.MustCompile(`<taskprogress .*? percent="(.*?)" .*?\/>`)
+	xmlAttrRegex      = regexp.MustCompile(`(\w+)="([^"]*)"`)
+)
+
 type runner struct {
-	params []string
-	timing int
-	state  state.State
-	output []byte
+	params          []string
+	timing          int
+	state           state.State
+	output          []byte
+	rawOutputFile   string
+	progressHandler func(ProgressEvent)
+	// grepableFallback is true when the grepable output is used when the XML
+	// output can not be parsed.
+	grepableFallback bool
+	// flags contains the nmap flags set through options.
+	flags map[string]string
+	// optsErr is the error found applying the options, if any.
+	optsErr error
 }
 
 func (r *runner) Run(ctx context.Context) (report *gonmap.NmapRun, rawOutput *[]byte, err error) {
-	processRunner := check.NewProcessChecker(nmapFile, r.params, bufio.ScanLines, r)
+	if r.optsErr != nil {
+		return nil, nil, r.optsErr
+	}
+	params := r.params
+	var grepableFile string
+	if r.grepableFallback {
+		f, err := ioutil.TempFile("", "nmap-grepable")
+		if err != nil {
+			return nil, nil, err
+		}
+		f.Close() // nolint
+		grepableFile = f.Name()
+		defer os.Remove(grepableFile) // nolint
+		params = append(append([]string{}, params...), "-oG", grepableFile)
+	}
+	processRunner := check.NewProcessChecker(nmapFile, params, bufio.ScanLines, r)
 
-	_, err = processRunner.Run(ctx)
-	if err != nil {
-		return nil, nil, err
+	// Even if nmap fails, e.g.: because it's killed, the output it wrote
+	// before failing is parsed to return the partial results.
+	_, runErr := processRunner.Run(ctx)
+
+	if r.rawOutputFile != "" {
+		err = ioutil.WriteFile(r.rawOutputFile, r.output, 0600)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
 	rawOutput = &r.output
 	report, err = gonmap.Parse(r.output)
+	if err != nil && grepableFile != "" {
+		grepable, rerr := ioutil.ReadFile(grepableFile)
+		if rerr == nil && len(grepable) > 0 {
+			report, err = ParseGrepable(grepable), nil
+		}
+	}
+	if runErr != nil {
+		if err != nil {
+			report = nil
+		}
+		return report, rawOutput, runErr
+	}
 	return report, rawOutput, err
 }
 
@@ -54,9 +192,7 @@ func (r *runner) ProcessOutputChunk(chunk []byte) bool {
 	// Extract progress data from Nmap XML entry.
 	r.output = append(r.output, chunk...)
 
-	re := regexp.MustCompile(`<taskprogress .*? percent="(.*?)" .*?\/>`)
-
-	match := re.FindStringSubmatch(string(chunk))
+	match := taskProgressRegex.FindStringSubmatch(string(chunk))
 	// If the line contains progress data.
 	if len(match) >= 2 {
 		progress, err := strconv.ParseFloat(match[1], 32)
@@ -64,6 +200,9 @@ func (r *runner) ProcessOutputChunk(chunk []byte) bool {
 			return false
 		}
 		r.state.SetProgress(float32(progress))
+		if r.progressHandler != nil {
+			r.progressHandler(parseProgressEvent(match[0], float32(progress)))
+		}
 		return true
 	}
 
@@ -71,10 +210,30 @@ func (r *runner) ProcessOutputChunk(chunk []byte) bool {
 	return true
 }
 
+// parseProgressEvent returns the progress event corresponding to the given
+// taskprogress element. The attributes that can not be parsed are ignored.
+func parseProgressEvent(element string, percent float32) ProgressEvent {
+	ev := ProgressEvent{Percent: percent}
+	for _, attr := range xmlAttrRegex.FindAllStringSubmatch(element, -1) {
+		switch attr[1] {
+		case "task":
+			ev.Task = attr[2]
+		case "remaining":
+			ev.Remaining, _ = strconv.Atoi(attr[2]) // nolint
+		case "etc":
+			if etc, err := strconv.ParseInt(attr[2], 10, 64); err == nil && etc > 0 {
+				ev.ETC = time.Unix(etc, 0)
+			}
+		}
+	}
+	return ev
+}
+
 /* NewNmapCheck Creates a new base nmap check with some default options that are needed to parse
- * the results.
+ * the results. The flags set by the given Option's, e.g.: WithHostTimeout,
+ * take precedence over the same flags in options, which are discarded.
  */
-func NewNmapCheck(target string, s state.State, timing int, options map[string]string) NmapRunner {
+func NewNmapCheck(target string, s state.State, timing int, options map[string]string, opts ...Option) NmapRunner {
 	if timing == 0 {
 		timing = defaultTiming
 	}
@@ -84,13 +243,25 @@ func NewNmapCheck(target string, s state.State, timing int, options map[string]s
 	// regex for -T[0-9]
 	var regexT = regexp.MustCompile(`^-T[0-9]$`)
 
+	r := &runner{
+		timing: timing,
+		state:  s,
+		flags:  map[string]string{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
 	var paramsStart = []string{"-oX", "-", t}
 	var paramsEnd = []string{target, "--stats-every", statsPeriod}
 
-	params := make([]string, 0, len(paramsStart)+len(paramsEnd)+len(options))
+	params := make([]string, 0, len(paramsStart)+len(paramsEnd)+len(options)+len(r.flags))
 
 	params = append(params, paramsStart...)
 	for k, v := range options {
+		if _, ok := r.flags[k]; ok {
+			continue
+		}
 		if k == "-oX" || k == "--stats-every" || regexT.MatchString(k) {
 			continue
 		}
@@ -99,25 +270,77 @@ func NewNmapCheck(target string, s state.State, timing int, options map[string]s
 			params = append(params, v)
 		}
 	}
+	for k, v := range r.flags {
+		params = append(params, k, v)
+	}
 
 	params = append(params, paramsEnd...)
-
-	r := &runner{
-		params: params,
-		timing: timing,
-		state:  s,
-	}
+	r.params = params
 	return r
 }
 
 // NewNmapTCPCheck Creates a new nmap check. TCP Connect()
-func NewNmapTCPCheck(target string, s state.State, timing int, tcpPorts []string) NmapRunner {
+func NewNmapTCPCheck(target string, s state.State, timing int, tcpPorts []string, opts ...Option) NmapRunner {
 	tcp := strings.Join(tcpPorts, ",")
-	return NewNmapCheck(target, s, timing, map[string]string{"-p": tcp})
+	return NewNmapCheck(target, s, timing, map[string]string{"-p": tcp}, opts...)
 }
 
 // NewNmapUDPCheck Creates a new nmap check.
-func NewNmapUDPCheck(target string, s state.State, timing int, udpPorts []string) NmapRunner {
+func NewNmapUDPCheck(target string, s state.State, timing int, udpPorts []string, opts ...Option) NmapRunner {
 	udp := strings.Join(udpPorts, ",")
-	return NewNmapCheck(target, s, timing, map[string]string{"-p": udp, "-sU": ""})
+	return NewNmapCheck(target, s, timing, map[string]string{"-p": udp, "-sU": ""}, opts...)
+}
+
+// NewNmapTCPSpecCheck creates a new TCP Connect() nmap check that scans the
+// ports of the given port spec, e.g.: "1-1024,3389". See ParsePortSpec.
+func NewNmapTCPSpecCheck(target string, s state.State, timing int, tcpSpec string, opts ...Option) (NmapRunner, error) {
+	ports, err := parsePortRange(tcpSpec)
+	if err != nil {
+		return nil, err
+	}
+	// The ports are passed compressed in ranges to keep the command line short.
+	return NewNmapTCPCheck(target, s, timing, []string{formatPorts(ports)}, opts...), nil
+}
+
+// NewNmapUDPSpecCheck creates a new UDP nmap check that scans the ports of the
+// given port spec, e.g.: "53,161-162". See ParsePortSpec.
+func NewNmapUDPSpecCheck(target string, s state.State, timing int, udpSpec string, opts ...Option) (NmapRunner, error) {
+	ports, err := parsePortRange(udpSpec)
+	if err != nil {
+		return nil, err
+	}
+	// The ports are passed compressed in ranges to keep the command line short.
+	return NewNmapUDPCheck(target, s, timing, []string{formatPorts(ports)}, opts...), nil
+}
+
+// NewNmapTCPUDPCheck Creates a new nmap check that scans the given TCP and UDP
+// ports in a single execution of nmap. The TCP ports are scanned using a SYN
+// scan when running as root and a Connect() scan otherwise. As the UDP scan
+// requires root privileges, the UDP ports are only scanned when running as root.
+// When no ports are left to scan, e.g.: only UDP ports are given and the check
+// is not running as root, the Run method of the returned runner returns
+// ErrNoPortsToScan without executing nmap.
+func NewNmapTCPUDPCheck(target string, s state.State, timing int, tcpPorts []string, udpPorts []string, opts ...Option) NmapRunner {
+	options := map[string]string{}
+	var ports []string
+	if len(tcpPorts) > 0 {
+		if os.Geteuid() == 0 {
+			options["-sS"] = ""
+		} else {
+			options["-sT"] = ""
+		}
+		ports = append(ports, "T:"+strings.Join(tcpPorts, ","))
+	}
+	if len(udpPorts) > 0 && os.Geteuid() == 0 {
+		options["-sU"] = ""
+		ports = append(ports, "U:"+strings.Join(udpPorts, ","))
+	}
+	// Without the flag -p nmap would scan its default ports instead.
+	if len(ports) == 0 {
+		r := NewNmapCheck(target, s, timing, options, opts...).(*runner)
+		r.optsErr = ErrNoPortsToScan
+		return r
+	}
+	options["-p"] = strings.Join(ports, ",")
+	return NewNmapCheck(target, s, timing, options, opts...)
 }
@@ -4,9 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"flag"
+	"fmt"
 	"io/ioutil"
 	"net"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -228,6 +230,35 @@ func TestProcessOutputChunk(t *testing.T) {
 	}
 }
 
+func TestProcessOutputChunkProgressHandler(t *testing.T) {
+	s := state.State{
+		ProgressReporter: stateMock{},
+	}
+	var got []ProgressEvent
+	handler := func(ev ProgressEvent) {
+		got = append(got, ev)
+	}
+	r := NewNmapTCPCheck("localhost", s, 0, []string{"29070"}, WithProgressHandler(handler))
+
+	chunks := []string{
+		`<taskprogress task="Ping Scan" time="1535366558" percent="5.00" remaining="20" etc="1535366578"/>`,
+		`<host starttime="1535366558" endtime="1535366558">`,
+		`<taskprogress task="Connect Scan" time="1535366560" percent="42.50" remaining="3" etc="1535366563"/>`,
+	}
+	for _, chunk := range chunks {
+		if !r.(check.ProcessChecker).ProcessOutputChunk([]byte(chunk)) {
+			t.Fatalf("error processing chunk %s", chunk)
+		}
+	}
+	want := []ProgressEvent{
+		{Task: "Ping Scan", Percent: 5, Remaining: 20, ETC: time.Unix(1535366578, 0)},
+		{Task: "Connect Scan", Percent: 42.5, Remaining: 3, ETC: time.Unix(1535366563, 0)},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("progress events differ, diff %s", diff)
+	}
+}
+
 func root() bool {
 	return (os.Getegid() == 0)
 }
@@ -240,3 +271,349 @@ func writeGoldenFile(v interface{}, filePath string) error {
 	err = ioutil.WriteFile(filePath, bytes, 0644)
 	return err
 }
+
+// fakeNmap replaces the nmap executable used by the runners with a script that
+// writes the contents of the given file to the standard output. The returned
+// function restores the original executable.
+func fakeNmap(t *testing.T, outputPath string) func() {
+	path, err := filepath.Abs(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "fakenmap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(dir, "nmap")
+	contents := fmt.Sprintf("#!/bin/sh\ncat %s\n", path)
+	if err := ioutil.WriteFile(script, []byte(contents), 0700); err != nil {
+		t.Fatal(err)
+	}
+	prev := nmapFile
+	nmapFile = script
+	return func() {
+		nmapFile = prev
+		os.RemoveAll(dir) // nolint
+	}
+}
+
+func TestRunnerWithRawOutputFile(t *testing.T) {
+	restore := fakeNmap(t, "testdata/NmapFakeOutput.xml")
+	defer restore()
+	dir, err := ioutil.TempDir("", "rawoutput")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint
+	path := filepath.Join(dir, "nmap.xml")
+	s := state.State{
+		ProgressReporter: stateMock{},
+	}
+	r := NewNmapTCPCheck("localhost", s, 0, []string{"22"}, WithRawOutputFile(path))
+	_, rawOutput, err := r.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) == 0 {
+		t.Fatal("raw output file is empty")
+	}
+	if diff := cmp.Diff(string(*rawOutput), string(got)); diff != "" {
+		t.Errorf("raw output file != raw output, diff %s", diff)
+	}
+}
+
+func TestRunnerProcessError(t *testing.T) {
+	xmlOutput, err := filepath.Abs("testdata/NmapFakeOutput.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grepable, err := filepath.Abs("testdata/NmapGrepableOutput.gnmap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		script     string
+		opts       []Option
+		wantReport bool
+	}{
+		{
+			name:       "CompleteXMLOutput",
+			script:     fmt.Sprintf("#!/bin/sh\ncat %s\nexit 1\n", xmlOutput),
+			wantReport: true,
+		},
+		{
+			name: "GrepableFallback",
+			script: fmt.Sprintf(`#!/bin/sh
+while [ $# -gt 0 ]; do
+	if [ "$1" = "-oG" ]; then
+		cp %s "$2"
+	fi
+	shift
+done
+echo '<?xml version="1.0" encoding="UTF-8"?><nmaprun scanner="nmap"><host>'
+exit 1
+`, grepable),
+			opts:       []Option{WithGrepableFallback()},
+			wantReport: true,
+		},
+		{
+			name:   "NoOutput",
+			script: "#!/bin/sh\nexit 1\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "fakenmap")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir) // nolint
+			script := filepath.Join(dir, "nmap")
+			if err := ioutil.WriteFile(script, []byte(tt.script), 0700); err != nil {
+				t.Fatal(err)
+			}
+			prev := nmapFile
+			nmapFile = script
+			defer func() { nmapFile = prev }()
+
+			s := state.State{
+				ProgressReporter: stateMock{},
+			}
+			r := NewNmapTCPCheck("10.0.0.1", s, 0, []string{"1-1000"}, tt.opts...)
+			report, _, err := r.Run(context.Background())
+			if err == nil {
+				t.Error("want error running nmap")
+			}
+			if gotReport := report != nil && len(report.Hosts) > 0; gotReport != tt.wantReport {
+				t.Errorf("want partial report %v, got %+v", tt.wantReport, report)
+			}
+		})
+	}
+}
+
+func TestNewNmapTCPUDPCheckParams(t *testing.T) {
+	s := state.State{
+		ProgressReporter: stateMock{},
+	}
+	r := NewNmapTCPUDPCheck("localhost", s, 0, []string{"22", "80"}, []string{"53"}).(*runner)
+	params := map[string]string{}
+	for i, p := range r.params {
+		if p == "-p" && i+1 < len(r.params) {
+			params["-p"] = r.params[i+1]
+		} else {
+			params[p] = ""
+		}
+	}
+	wantPorts := "T:22,80"
+	if os.Geteuid() == 0 {
+		wantPorts = "T:22,80,U:53"
+		if _, ok := params["-sU"]; !ok {
+			t.Errorf("want -sU in params %v", r.params)
+		}
+	}
+	if params["-p"] != wantPorts {
+		t.Errorf("want ports %s, got %s", wantPorts, params["-p"])
+	}
+}
+
+func TestNewNmapTCPUDPCheckParamsUDPOnly(t *testing.T) {
+	s := state.State{
+		ProgressReporter: stateMock{},
+	}
+	r := NewNmapTCPUDPCheck("localhost", s, 0, nil, []string{"53"}).(*runner)
+	if os.Geteuid() == 0 {
+		if !containsParams(r.params, "-p", "U:53") {
+			t.Errorf("want -p U:53 in params %v", r.params)
+		}
+		return
+	}
+	if _, _, err := r.Run(context.Background()); err != ErrNoPortsToScan {
+		t.Errorf("want error %v, got %v", ErrNoPortsToScan, err)
+	}
+}
+
+func TestParsePortSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "PortsAndRange",
+			spec: "22,80,8000-8002",
+			want: []string{"22", "80", "8000", "8001", "8002"},
+		},
+		{
+			name: "DuplicatedPorts",
+			spec: "80, 79-81,80",
+			want: []string{"80", "79", "81"},
+		},
+		{
+			name:    "NotAPort",
+			spec:    "abc",
+			wantErr: true,
+		},
+		{
+			name:    "InvertedRange",
+			spec:    "1024-1",
+			wantErr: true,
+		},
+		{
+			name:    "OutOfRange",
+			spec:    "65536",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePortSpec(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePortSpec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("ports differ, diff %s", diff)
+			}
+		})
+	}
+}
+
+func TestNewNmapTCPSpecCheckParams(t *testing.T) {
+	s := state.State{
+		ProgressReporter: stateMock{},
+	}
+	r, err := NewNmapTCPSpecCheck("localhost", s, 0, "22,80,8000-8002,8001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := r.(*runner).params
+	for i, p := range params {
+		if p == "-p" && i+1 < len(params) {
+			if want := "22,80,8000-8002"; params[i+1] != want {
+				t.Errorf("want ports %s, got %s", want, params[i+1])
+			}
+			return
+		}
+	}
+	t.Errorf("want -p in params %v", params)
+}
+
+func TestNmapTCPUDPCheckIntegration(t *testing.T) {
+	if !root() {
+		t.Skip("UDP scans require root privileges")
+	}
+	initNmapPath()
+	port := "29071"
+	tcpLn, err := listenOnTCPPort(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcpLn.Close() // nolint
+	udpLn, err := listenOnUDPPort(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udpLn.Close() // nolint
+	s := state.State{
+		ProgressReporter: stateMock{},
+	}
+	r := NewNmapTCPUDPCheck("localhost", s, 0, []string{port}, []string{port})
+	report, _, err := r.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	protocols := map[string]bool{}
+	for _, h := range report.Hosts {
+		for _, p := range h.Ports {
+			protocols[p.Protocol] = true
+		}
+	}
+	if !protocols["tcp"] || !protocols["udp"] {
+		t.Errorf("want tcp and udp ports in the report, got %v", protocols)
+	}
+}
+
+func TestNewNmapCheckWithTimeoutAndRetries(t *testing.T) {
+	s := state.State{
+		ProgressReporter: stateMock{},
+	}
+	options := map[string]string{
+		"-p":             "22",
+		"--host-timeout": "1h",
+	}
+	r := NewNmapCheck("localhost", s, 0, options, WithHostTimeout(30*time.Second), WithMaxRetries(2)).(*runner)
+	want := map[string]string{
+		"--host-timeout": "30s",
+		"--max-retries":  "2",
+		"-p":             "22",
+	}
+	for flag, value := range want {
+		found := 0
+		for i, p := range r.params {
+			if p != flag {
+				continue
+			}
+			found++
+			if i+1 >= len(r.params) || r.params[i+1] != value {
+				t.Errorf("want flag %s with value %s in params %v", flag, value, r.params)
+			}
+		}
+		if found != 1 {
+			t.Errorf("want flag %s exactly once in params %v, found %d", flag, r.params, found)
+		}
+	}
+
+	r = NewNmapCheck("localhost", s, 0, nil, WithHostTimeout(1500*time.Millisecond)).(*runner)
+	if !containsParams(r.params, "--host-timeout", "1500ms") {
+		t.Errorf("want --host-timeout 1500ms in params %v", r.params)
+	}
+}
+
+func TestNewNmapCheckWithInvalidTimeoutAndRetries(t *testing.T) {
+	s := state.State{
+		ProgressReporter: stateMock{},
+	}
+	tests := []struct {
+		name string
+		opt  Option
+		flag string
+	}{
+		{
+			name: "NegativeHostTimeout",
+			opt:  WithHostTimeout(-time.Second),
+			flag: "--host-timeout",
+		},
+		{
+			name: "NegativeMaxRetries",
+			opt:  WithMaxRetries(-1),
+			flag: "--max-retries",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewNmapCheck("localhost", s, 0, nil, tt.opt).(*runner)
+			for _, p := range r.params {
+				if p == tt.flag {
+					t.Errorf("want no flag %s in params %v", tt.flag, r.params)
+				}
+			}
+			if _, _, err := r.Run(context.Background()); err == nil {
+				t.Error("want error running nmap with an invalid option")
+			}
+		})
+	}
+}
+
+func containsParams(params []string, flag, value string) bool {
+	for i, p := range params {
+		if p == flag && i+1 < len(params) && params[i+1] == value {
+			return true
+		}
+	}
+	return false
+}
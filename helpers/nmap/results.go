@@ -0,0 +1,299 @@
+package nmap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adevinta/vulcan-check-sdk/state"
+	gonmap "github.com/lair-framework/go-nmap"
+)
+
+// ScanStats contains the statistics of an nmap scan.
+type ScanStats struct {
+	HostsUp    int
+	HostsDown  int
+	HostsTotal int
+	// Elapsed is the time nmap took to perform the scan with a precision of
+	// milliseconds.
+	Elapsed time.Duration
+	// Exit contains the exit status reported by nmap, e.g.: "success".
+	Exit string
+}
+
+// Stats returns the statistics of a scan given its parsed report.
+func Stats(run *gonmap.NmapRun) ScanStats {
+	if run == nil {
+		return ScanStats{}
+	}
+	stats := run.RunStats
+	elapsed := time.Duration(float64(stats.Finished.Elapsed) * float64(time.Second))
+	return ScanStats{
+		HostsUp:    stats.Hosts.Up,
+		HostsDown:  stats.Hosts.Down,
+		HostsTotal: stats.Hosts.Total,
+		Elapsed:    elapsed.Round(time.Millisecond),
+		Exit:       stats.Finished.Exit,
+	}
+}
+
+// CPEs returns the CPE identifiers, e.g.: "cpe:/a:openbsd:openssh:7.4", of the
+// services detected in the given scan, which requires running nmap with the -sV
+// flag. The CPEs are returned in the same order than they appear in the report
+// without duplicates.
+func CPEs(run *gonmap.NmapRun) []string {
+	if run == nil {
+		return nil
+	}
+	var cpes []string
+	seen := map[string]bool{}
+	for _, h := range run.Hosts {
+		for _, p := range h.Ports {
+			for _, cpe := range p.Service.CPEs {
+				c := string(cpe)
+				if c == "" || seen[c] {
+					continue
+				}
+				seen[c] = true
+				cpes = append(cpes, c)
+			}
+		}
+	}
+	return cpes
+}
+
+// HostPortSummary contains the number of ports of a host in each state,
+// including the ports that nmap aggregates in the extraports elements of the
+// report, e.g.: the 997 filtered ports of a host.
+type HostPortSummary struct {
+	// Host is the first address of the host in the report.
+	Host   string
+	Open   int
+	Closed int
+	// Filtered also counts the ports nmap can not determine whether are
+	// filtered or not, that is, the ports in the "open|filtered" and
+	// "closed|filtered" states.
+	Filtered int
+	// Unfiltered counts the ports in the "unfiltered" state, that is, ports
+	// that are reachable but nmap can not determine whether are open or
+	// closed.
+	Unfiltered int
+}
+
+// String returns the non zero counts of the summary, e.g.: "2 open, 1 closed,
+// 997 filtered".
+func (s HostPortSummary) String() string {
+	var counts []string
+	for _, c := range []struct {
+		state string
+		n     int
+	}{
+		{"open", s.Open},
+		{"closed", s.Closed},
+		{"filtered", s.Filtered},
+		{"unfiltered", s.Unfiltered},
+	} {
+		if c.n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", c.n, c.state))
+		}
+	}
+	return strings.Join(counts, ", ")
+}
+
+// PortSummary returns, for each host of the given scan, in the same order than
+// they appear in the report, the number of ports in each state.
+func PortSummary(run *gonmap.NmapRun) []HostPortSummary {
+	if run == nil {
+		return nil
+	}
+	var summaries []HostPortSummary
+	for _, h := range run.Hosts {
+		var s HostPortSummary
+		if len(h.Addresses) > 0 {
+			s.Host = h.Addresses[0].Addr
+		}
+		for _, p := range h.Ports {
+			s.add(p.State.State, 1)
+		}
+		for _, e := range h.ExtraPorts {
+			s.add(e.State, e.Count)
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries
+}
+
+func (s *HostPortSummary) add(state string, n int) {
+	switch state {
+	case "open":
+		s.Open += n
+	case "closed":
+		s.Closed += n
+	case "filtered", "open|filtered", "closed|filtered":
+		s.Filtered += n
+	case "unfiltered":
+		s.Unfiltered += n
+	}
+}
+
+// Coverage returns the coverage of a scan given the hosts, IPs or hostnames,
+// and the ports, numbers or ranges like "1-1024", intended to be scanned and
+// its parsed report. A host responded if it's reported as up and a port
+// responded if it's reported as open, closed or unfiltered, that is, if a
+// response was received from it. The ports of a host reported in its extra
+// ports are counted as responded up to the number of extra ports in those
+// states. An error is returned if a port is not valid. The coverage can be
+// recorded in the report with the SetCoverage method of the state.
+func Coverage(hosts, ports []string, run *gonmap.NmapRun) (state.Coverage, error) {
+	intended, err := expandPorts(ports)
+	if err != nil {
+		return state.Coverage{}, err
+	}
+	var respondedHosts, respondedPorts int
+	for _, host := range hosts {
+		h, ok := findHost(run, host)
+		if !ok || h.Status.State != "up" {
+			continue
+		}
+		respondedHosts++
+		listed := map[int]bool{}
+		for _, p := range h.Ports {
+			if !intended[p.PortId] || listed[p.PortId] {
+				continue
+			}
+			listed[p.PortId] = true
+			if responded(p.State.State) {
+				respondedPorts++
+			}
+		}
+		var extra int
+		for _, e := range h.ExtraPorts {
+			if responded(e.State) {
+				extra += e.Count
+			}
+		}
+		if unlisted := len(intended) - len(listed); extra > unlisted {
+			extra = unlisted
+		}
+		respondedPorts += extra
+	}
+	return state.NewCoverage(len(hosts), respondedHosts, len(hosts)*len(intended), respondedPorts), nil
+}
+
+func responded(portState string) bool {
+	return portState == "open" || portState == "closed" || portState == "unfiltered"
+}
+
+// findHost returns the host of the report with the given address or hostname.
+func findHost(run *gonmap.NmapRun, host string) (gonmap.Host, bool) {
+	if run == nil {
+		return gonmap.Host{}, false
+	}
+	for _, h := range run.Hosts {
+		for _, a := range h.Addresses {
+			if a.Addr == host {
+				return h, true
+			}
+		}
+		for _, n := range h.Hostnames {
+			if strings.EqualFold(n.Name, host) {
+				return h, true
+			}
+		}
+	}
+	return gonmap.Host{}, false
+}
+
+// expandPorts returns the set of ports defined by the given port numbers and
+// ranges.
+func expandPorts(ports []string) (map[int]bool, error) {
+	set := map[int]bool{}
+	for _, p := range ports {
+		parts := strings.SplitN(p, "-", 2)
+		from, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q: %v", p, err)
+		}
+		to := from
+		if len(parts) == 2 {
+			to, err = strconv.Atoi(strings.TrimSpace(parts[1]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid port range %q: %v", p, err)
+			}
+		}
+		if from < 0 || to > 65535 || from > to {
+			return nil, fmt.Errorf("invalid port range %q", p)
+		}
+		for port := from; port <= to; port++ {
+			set[port] = true
+		}
+	}
+	return set, nil
+}
+
+// HostScriptResults contains the results of the NSE scripts run against a
+// host, as reported in the hostscript element of the report, e.g.:
+// smb-os-discovery.
+type HostScriptResults struct {
+	// Host is the first address of the host in the report.
+	Host    string
+	Scripts []ScriptResult
+}
+
+// ScriptResult contains the result of an NSE script.
+type ScriptResult struct {
+	ID     string
+	Output string
+	// Elements contains the structured output of the script. The keys of the
+	// elements inside tables are prefixed by the keys of the tables separated
+	// by ".", e.g.: "os.name". The tables and elements without a key are
+	// identified by their position in their parent, starting at 1.
+	Elements map[string]string
+}
+
+// HostScripts returns, for each host of the given scan with host script
+// results, in the same order than they appear in the report, the results of
+// the scripts run against the host.
+func HostScripts(run *gonmap.NmapRun) []HostScriptResults {
+	if run == nil {
+		return nil
+	}
+	var results []HostScriptResults
+	for _, h := range run.Hosts {
+		if len(h.HostScripts) == 0 {
+			continue
+		}
+		hs := HostScriptResults{}
+		if len(h.Addresses) > 0 {
+			hs.Host = h.Addresses[0].Addr
+		}
+		for _, sc := range h.HostScripts {
+			elements := map[string]string{}
+			flattenScriptElements(elements, "", sc.Elements, sc.Tables)
+			hs.Scripts = append(hs.Scripts, ScriptResult{
+				ID:       sc.Id,
+				Output:   sc.Output,
+				Elements: elements,
+			})
+		}
+		results = append(results, hs)
+	}
+	return results
+}
+
+func flattenScriptElements(dst map[string]string, prefix string, elements []gonmap.Element, tables []gonmap.Table) {
+	for i, e := range elements {
+		dst[prefix+scriptKey(e.Key, i)] = e.Value
+	}
+	for i, t := range tables {
+		flattenScriptElements(dst, prefix+scriptKey(t.Key, i)+".", t.Elements, t.Table)
+	}
+}
+
+func scriptKey(key string, i int) string {
+	if key == "" {
+		return strconv.Itoa(i + 1)
+	}
+	return key
+}
@@ -0,0 +1,178 @@
+package nmap
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/adevinta/vulcan-check-sdk/state"
+	report "github.com/adevinta/vulcan-report"
+	"github.com/google/go-cmp/cmp"
+	gonmap "github.com/lair-framework/go-nmap"
+)
+
+func readGoldenReport(t *testing.T, path string) *gonmap.NmapRun {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := &gonmap.NmapRun{}
+	if err := json.Unmarshal(contents, run); err != nil {
+		t.Fatal(err)
+	}
+	return run
+}
+
+func TestStats(t *testing.T) {
+	run := readGoldenReport(t, "testdata/NmapHappyPathGolden.json")
+	want := ScanStats{
+		HostsUp:    1,
+		HostsDown:  0,
+		HostsTotal: 1,
+		Elapsed:    30 * time.Millisecond,
+		Exit:       "success",
+	}
+	got := Stats(run)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Stats() != want, diff %s", diff)
+	}
+}
+
+func TestCPEs(t *testing.T) {
+	contents, err := ioutil.ReadFile("testdata/NmapServiceVersionOutput.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	run, err := gonmap.Parse(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"cpe:/a:openbsd:openssh:7.4",
+		"cpe:/a:apache:http_server:2.4.6",
+		"cpe:/o:redhat:enterprise_linux",
+		"cpe:/a:openbsd:openssh:8.0",
+	}
+	if diff := cmp.Diff(want, CPEs(run)); diff != "" {
+		t.Errorf("CPEs() != want, diff %s", diff)
+	}
+	if got := CPEs(nil); got != nil {
+		t.Errorf("want no CPEs for a nil report, got %v", got)
+	}
+}
+
+func TestPortSummary(t *testing.T) {
+	contents, err := ioutil.ReadFile("testdata/NmapExtraPortsOutput.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	run, err := gonmap.Parse(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []HostPortSummary{
+		{Host: "10.0.0.1", Open: 2, Closed: 1, Filtered: 997},
+		{Host: "10.0.0.2", Open: 1, Closed: 995, Filtered: 3, Unfiltered: 1},
+	}
+	got := PortSummary(run)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("PortSummary() != want, diff %s", diff)
+	}
+	if s := got[0].String(); s != "2 open, 1 closed, 997 filtered" {
+		t.Errorf("got summary %q", s)
+	}
+	if got := PortSummary(nil); got != nil {
+		t.Errorf("want no summaries for a nil report, got %v", got)
+	}
+}
+
+func TestHostScripts(t *testing.T) {
+	contents, err := ioutil.ReadFile("testdata/NmapHostScriptOutput.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	run, err := gonmap.Parse(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []HostScriptResults{
+		{
+			Host: "10.0.0.1",
+			Scripts: []ScriptResult{
+				{
+					ID:     "smb-os-discovery",
+					Output: "\n  OS: Windows Server 2016 Standard 14393 (Windows Server 2016 Standard 6.3)\n  Computer name: fileserver\n",
+					Elements: map[string]string{
+						"os":         "Windows Server 2016 Standard 14393",
+						"server":     `FILESERVER\x00`,
+						"date.year":  "2018",
+						"date.month": "8",
+					},
+				},
+				{
+					ID:     "smb-security-mode",
+					Output: "\n  account_used: guest\n  message_signing: disabled (dangerous, but default)\n",
+					Elements: map[string]string{
+						"account_used":    "guest",
+						"message_signing": "disabled",
+						"protocols.1":     "SMBv1",
+						"protocols.2":     "SMBv2",
+					},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, HostScripts(run)); diff != "" {
+		t.Errorf("HostScripts() != want, diff %s", diff)
+	}
+	if got := HostScripts(nil); got != nil {
+		t.Errorf("want no host scripts for a nil report, got %v", got)
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	contents, err := ioutil.ReadFile("testdata/NmapExtraPortsOutput.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	run, err := gonmap.Parse(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The host 10.0.0.3 isn't in the report. The ports 22, 80 and 8080 of the
+	// host 10.0.0.2 are counted as responded because they are in its closed
+	// extra ports.
+	hosts := []string{"www.example.com", "10.0.0.2", "10.0.0.3"}
+	got, err := Coverage(hosts, []string{"22", "80", "443", "8080"}, run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := state.Coverage{
+		IntendedHosts:  3,
+		RespondedHosts: 2,
+		HostsPercent:   200.0 / 3,
+		IntendedPorts:  12,
+		RespondedPorts: 7,
+		PortsPercent:   700.0 / 12,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Coverage() != want, diff %s", diff)
+	}
+
+	s := state.State{ResultData: &report.ResultData{}}
+	if err := s.SetCoverage(got); err != nil {
+		t.Fatal(err)
+	}
+	var data map[string]state.Coverage
+	if err := json.Unmarshal(s.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, data[state.CoverageKey]); diff != "" {
+		t.Errorf("recorded coverage != want, diff %s", diff)
+	}
+
+	if _, err := Coverage(hosts, []string{"80-22"}, run); err == nil {
+		t.Errorf("want error for an invalid port range")
+	}
+}
@@ -0,0 +1,27 @@
+package nmap
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/adevinta/vulcan-check-sdk/helpers"
+)
+
+var versionRegex = regexp.MustCompile(`Nmap version (\S+)`)
+
+// Version returns the version of the installed nmap, as reported by
+// "nmap --version", e.g.: "7.94" or "7.94SVN".
+func Version(ctx context.Context) (string, error) {
+	return helpers.CommandVersion(ctx, nmapFile, []string{"--version"}, versionRegex)
+}
+
+// RequireVersion returns an error if nmap is not installed or its version is
+// lower than the given one, e.g.: "7.80". A suffix in the installed version,
+// like in "7.94SVN", is ignored when comparing the versions.
+func RequireVersion(ctx context.Context, min string) error {
+	v, err := Version(ctx)
+	if err != nil {
+		return err
+	}
+	return helpers.RequireVersion("nmap", v, min)
+}
@@ -0,0 +1,86 @@
+package nmap
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeVersionNmap replaces the nmap binary with a script that prints the given
+// output.
+func fakeVersionNmap(t *testing.T, output string) func() {
+	dir, err := ioutil.TempDir("", "fakenmap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(dir, "nmap")
+	contents := "#!/bin/sh\ncat <<'EOF'\n" + output + "\nEOF\n"
+	if err := ioutil.WriteFile(script, []byte(contents), 0700); err != nil {
+		t.Fatal(err)
+	}
+	prev := nmapFile
+	nmapFile = script
+	return func() {
+		nmapFile = prev
+		os.RemoveAll(dir) // nolint
+	}
+}
+
+func TestVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		min     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "VersionSatisfied",
+			output: "Nmap version 7.94SVN ( https://nmap.org )\nPlatform: x86_64-pc-linux-gnu",
+			min:    "7.80",
+			want:   "7.94SVN",
+		},
+		{
+			name:    "VersionTooOld",
+			output:  "Nmap version 7.01 ( https://nmap.org )",
+			min:     "7.80",
+			want:    "7.01",
+			wantErr: true,
+		},
+		{
+			name:    "NoVersion",
+			output:  "command not found",
+			min:     "7",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := fakeVersionNmap(t, tt.output)
+			defer restore()
+			ctx := context.Background()
+			got, err := Version(ctx)
+			if tt.want != "" && err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("want version %q, got %q", tt.want, got)
+			}
+			err = RequireVersion(ctx, tt.min)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RequireVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVersionNotInstalled(t *testing.T) {
+	prev := nmapFile
+	nmapFile = filepath.Join(os.TempDir(), "nonexistent-nmap")
+	defer func() { nmapFile = prev }()
+	if _, err := Version(context.Background()); err == nil {
+		t.Errorf("want error when nmap is not installed")
+	}
+}
@@ -0,0 +1,66 @@
+package helpers
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// ErrEmptyTarget is returned when trying to normalize an empty target.
+var ErrEmptyTarget = errors.New("empty target")
+
+// NormalizeTarget returns the canonical form of a target so different
+// representations of the same asset can be processed only once and compared.
+// The normalization is idempotent, that is, normalizing an already normalized
+// target returns the same value. The rules applied are:
+// * Leading and trailing whitespaces are removed.
+// * IPs, CIDRs, AWS accounts and Docker images are preserved as they are.
+// * URLs that only contain a scheme and a host, optionally with a port and a
+// "/" path, are normalized to the lowercased host and port, that is, the
+// scheme is stripped: "HTTP://Example.com/" is normalized to "example.com".
+// * Any other URL has its scheme and host lowercased.
+// * Hostnames are lowercased and trailing dots and slashes are removed.
+func NormalizeTarget(target string) (string, error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return "", ErrEmptyTarget
+	}
+	t := Target{Value: target}
+	if t.IsIP() || t.IsCIDR() || t.IsAWSAccount() || t.IsDockerImage() {
+		return target, nil
+	}
+	if strings.Contains(target, "://") {
+		u, err := url.Parse(target)
+		if err != nil {
+			return "", err
+		}
+		if u.Host == "" {
+			return "", errors.New("url without host")
+		}
+		u.Scheme = strings.ToLower(u.Scheme)
+		u.Host = strings.ToLower(u.Host)
+		if u.User == nil && (u.Path == "" || u.Path == "/") && u.RawQuery == "" && u.Fragment == "" {
+			return strings.TrimSuffix(u.Host, "."), nil
+		}
+		return u.String(), nil
+	}
+	if strings.ContainsAny(target, " \t\n") {
+		return "", errors.New("invalid target " + target)
+	}
+	target = strings.TrimRight(target, "./")
+	return strings.ToLower(target), nil
+}
+
+// NormalizeCIDR returns the canonical form of a CIDR, that is, the network
+// address followed by the prefix length: "10.0.0.5/24" is normalized to
+// "10.0.0.0/24". The hostBits result is true when the given CIDR had any of
+// the host bits set, so the checks can warn about or correct targets that
+// could be misinterpreted by tools expecting a network address.
+func NormalizeCIDR(cidr string) (normalized string, hostBits bool, err error) {
+	ip, ipnet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+	if err != nil {
+		return "", false, err
+	}
+	return ipnet.String(), !ip.Equal(ipnet.IP), nil
+}
@@ -0,0 +1,137 @@
+package helpers
+
+import "testing"
+
+func TestNormalizeTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "URLWithOnlyHost",
+			target: "HTTP://Example.com/",
+			want:   "example.com",
+		},
+		{
+			name:   "Hostname",
+			target: "example.com",
+			want:   "example.com",
+		},
+		{
+			name:   "HostnameWithSpacesAndTrailingDot",
+			target: "  WWW.Example.com.  ",
+			want:   "www.example.com",
+		},
+		{
+			name:   "URLWithPort",
+			target: "https://Example.com:8443",
+			want:   "example.com:8443",
+		},
+		{
+			name:   "URLWithPath",
+			target: "HTTPS://Example.com/Admin?q=1",
+			want:   "https://example.com/Admin?q=1",
+		},
+		{
+			name:   "IP",
+			target: " 127.0.0.1 ",
+			want:   "127.0.0.1",
+		},
+		{
+			name:   "CIDR",
+			target: "10.0.0.0/24",
+			want:   "10.0.0.0/24",
+		},
+		{
+			name:   "AWSAccount",
+			target: "arn:aws:iam::123456789012:root",
+			want:   "arn:aws:iam::123456789012:root",
+		},
+		{
+			name:    "Empty",
+			target:  "  ",
+			wantErr: true,
+		},
+		{
+			name:    "URLWithoutHost",
+			target:  "http:///path",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeTarget(tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NormalizeTarget() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeTarget() = %s, want %s", got, tt.want)
+			}
+			if err != nil {
+				return
+			}
+			// The normalization must be idempotent.
+			again, err := NormalizeTarget(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again != got {
+				t.Errorf("NormalizeTarget() is not idempotent, %s != %s", again, got)
+			}
+		})
+	}
+}
+
+func TestNormalizeCIDR(t *testing.T) {
+	tests := []struct {
+		name         string
+		cidr         string
+		want         string
+		wantHostBits bool
+		wantErr      bool
+	}{
+		{
+			name:         "HostBitsSet",
+			cidr:         "10.0.0.5/24",
+			want:         "10.0.0.0/24",
+			wantHostBits: true,
+		},
+		{
+			name: "NetworkAddress",
+			cidr: "10.0.0.0/24",
+			want: "10.0.0.0/24",
+		},
+		{
+			name: "SingleHost",
+			cidr: "10.0.0.5/32",
+			want: "10.0.0.5/32",
+		},
+		{
+			name:         "IPv6HostBitsSet",
+			cidr:         "2001:DB8::1/64",
+			want:         "2001:db8::/64",
+			wantHostBits: true,
+		},
+		{
+			name:    "NotACIDR",
+			cidr:    "10.0.0.5",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, hostBits, err := NormalizeCIDR(tt.cidr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NormalizeCIDR() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeCIDR() = %v, want %v", got, tt.want)
+			}
+			if hostBits != tt.wantHostBits {
+				t.Errorf("NormalizeCIDR() hostBits = %v, want %v", hostBits, tt.wantHostBits)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package helpers
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sizeUnits contains the multipliers, in bytes, of the units accepted by
+// ParseSizeOption.
+var sizeUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+// ParseDurationOption parses the value of the option with the given name as a
+// Go duration, e.g.: "30s" or "1h30m". An empty value is parsed as 0 so options
+// that are not present keep the zero value. The name of the option is only used
+// to build the error returned when the value is not valid.
+func ParseDurationOption(name, value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q for option %s", value, name)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("negative duration %q for option %s", value, name)
+	}
+	return d, nil
+}
+
+// ParseSizeOption parses the value of the option with the given name as a size
+// in bytes. The value is a number, optionally with decimals, followed by an
+// optional unit: B, KB, MB, GB and TB for powers of 1000, and KiB, MiB, GiB and
+// TiB for powers of 1024, e.g.: "10MB" or "1.5 GiB". Units are case insensitive
+// and a value without unit is considered to be in bytes. An empty value is
+// parsed as 0 so options that are not present keep the zero value. The name of
+// the option is only used to build the error returned when the value is not
+// valid.
+func ParseSizeOption(name, value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	i := strings.IndexFunc(value, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	number, unit := value, ""
+	if i >= 0 {
+		number, unit = value[:i], strings.TrimSpace(value[i:])
+	}
+	multiplier, ok := sizeUnits[strings.ToLower(unit)]
+	if !ok {
+		return 0, fmt.Errorf("invalid size unit %q in %q for option %s", unit, value, name)
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q for option %s", value, name)
+	}
+	size := n * multiplier
+	if size >= math.MaxInt64 {
+		return 0, fmt.Errorf("size %q for option %s is too big", value, name)
+	}
+	return int64(size), nil
+}
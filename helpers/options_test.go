@@ -0,0 +1,65 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDurationOption(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "Seconds", value: "30s", want: 30 * time.Second},
+		{name: "Composed", value: " 1h30m ", want: 90 * time.Minute},
+		{name: "Empty", value: "", want: 0},
+		{name: "WithoutUnit", value: "30", wantErr: true},
+		{name: "Invalid", value: "thirty seconds", wantErr: true},
+		{name: "Negative", value: "-5s", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDurationOption("timeout", tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDurationOption() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseDurationOption() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSizeOption(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{name: "Bytes", value: "512", want: 512},
+		{name: "BytesWithUnit", value: "512B", want: 512},
+		{name: "Megabytes", value: "10MB", want: 10000000},
+		{name: "Mebibytes", value: "10MiB", want: 10 << 20},
+		{name: "DecimalsAndSpace", value: "1.5 gib", want: 3 << 29},
+		{name: "Empty", value: "", want: 0},
+		{name: "InvalidUnit", value: "10XB", wantErr: true},
+		{name: "InvalidNumber", value: "1.2.3MB", wantErr: true},
+		{name: "OnlyUnit", value: "MB", wantErr: true},
+		{name: "TooBig", value: "100000000TB", wantErr: true},
+		{name: "OverflowsInt64", value: "9223372036854775808", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSizeOption("max_size", tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSizeOption() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSizeOption() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// pinContextKey is the key of the Pin stored in the contexts returned by
+// Pin.Context.
+type pinContextKey struct{}
+
+// Pin fixes the IP a host, the host of a target, resolved to when it was
+// pinned with PinTarget.
+type Pin struct {
+	// Host is the host of the pinned target.
+	Host string
+	// IP is the IP the connections to the host are established with.
+	IP string
+}
+
+// PinTarget resolves the host of the given target once and returns a Pin that
+// makes the connections to the host go to the resolved IP for the rest of the
+// scan. That prevents a malicious server from changing the resolution of its
+// name, e.g.: to a private IP, after the target has been checked to be
+// scannable. The pin doesn't modify the network helpers globally, so targets
+// scanned at the same time can be pinned independently: only the connections
+// established through its DialContext method, through the http client returned
+// by its HTTPClient method, or by the network helpers, like IsPortOpen,
+// GrabBanner, VerifyTLSChain or DetectVHost, called with the context returned
+// by its Context method, connect to the pinned IP. Targets that are IPs are
+// pinned to themselves.
+func PinTarget(ctx context.Context, target string) (Pin, error) {
+	host := targetHost(target)
+	ip := net.ParseIP(host)
+	if ip == nil {
+		addrs, err := resolver.LookupIPAddr(ctx, toASCIIHostname(host))
+		if err != nil {
+			return Pin{}, err
+		}
+		if len(addrs) == 0 {
+			return Pin{}, fmt.Errorf("no IPs found for %s", host)
+		}
+		ip = addrs[0].IP
+	}
+	return Pin{Host: host, IP: ip.String()}, nil
+}
+
+// DialContext connects to the given address through the dialer of the network
+// helpers replacing the host of the pin, if it's the host of the address, with
+// the pinned IP.
+func (p Pin) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return currentDialer().DialContext(ctx, network, p.address(address))
+}
+
+// HTTPClient returns an http client, like the one returned by ProbeClient,
+// that connects to the pinned IP when sending requests to the host of the pin.
+func (p Pin) HTTPClient(timeout time.Duration) *http.Client {
+	return guardedHTTPClientWithPin(timeout, &p)
+}
+
+// address returns the given address with its host replaced by the pinned IP
+// if it's the host of the pin. A nil pin returns the address untouched.
+func (p *Pin) address(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	if ip, ok := p.pinnedIP(host); ok {
+		return net.JoinHostPort(ip.String(), port)
+	}
+	return address
+}
+
+// pinnedIP returns the pinned IP and true if the given host is the host of
+// the pin. A nil pin returns false.
+func (p *Pin) pinnedIP(host string) (net.IP, bool) {
+	if p == nil || !strings.EqualFold(strings.TrimSuffix(host, "."), p.Host) {
+		return nil, false
+	}
+	ip := net.ParseIP(p.IP)
+	return ip, ip != nil
+}
+
+// Context returns a copy of the given context that makes the network helpers
+// called with it connect to the pinned IP when connecting to the host of the
+// pin.
+func (p Pin) Context(ctx context.Context) context.Context {
+	return context.WithValue(ctx, pinContextKey{}, p)
+}
+
+// contextPin returns the Pin stored in the given context or nil if there is
+// none.
+func contextPin(ctx context.Context) *Pin {
+	if p, ok := ctx.Value(pinContextKey{}).(Pin); ok {
+		return &p
+	}
+	return nil
+}
+
+// dial connects to the given address through the dialer of the network
+// helpers honoring the Pin stored in the context, if any.
+func dial(ctx context.Context, network, address string) (net.Conn, error) {
+	return currentDialer().DialContext(ctx, network, contextPin(ctx).address(address))
+}
@@ -0,0 +1,92 @@
+package helpers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPinTarget(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close() // nolint
+	port := strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+
+	resolved := net.ParseIP("127.0.0.1")
+	defer withResolver(stubResolver(func(host string) ([]net.IPAddr, error) {
+		return []net.IPAddr{{IP: resolved}}, nil
+	}))()
+	prev := currentDialer()
+
+	ctx := context.Background()
+	pin, err := PinTarget(ctx, "https://rebinding.example.com:8443/path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin.IP != "127.0.0.1" {
+		t.Errorf("want pinned IP 127.0.0.1, got %s", pin.IP)
+	}
+	if currentDialer() != prev {
+		t.Errorf("want the dialer of the network helpers not modified")
+	}
+	// Change the resolution of the name, the connections must still go to the
+	// pinned IP.
+	resolved = net.ParseIP("192.0.2.1")
+	open, err := IsPortOpen(pin.Context(ctx), "rebinding.example.com", port, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !open {
+		t.Errorf("want the connection established with the pinned IP")
+	}
+
+	pin, err = PinTarget(ctx, "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin.IP != "10.0.0.1" {
+		t.Errorf("want IP targets pinned to themselves, got %s", pin.IP)
+	}
+}
+
+func TestPinHTTPClient(t *testing.T) {
+	defer withGuardPolicy(GuardPolicy{AllowPrivateIPs: true})()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer withResolver(stubResolver(func(host string) ([]net.IPAddr, error) {
+		return []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}}, nil
+	}))()
+	pinned, err := PinTarget(context.Background(), "target.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Another pin of the same host, e.g.: made while scanning another target
+	// at the same time, to an IP where the server is not listening.
+	other := Pin{Host: "target.example.com", IP: "127.0.0.2"}
+
+	u := "http://target.example.com:" + port + "/"
+	resp, err := pinned.HTTPClient(2 * time.Second).Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close() // nolint
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("want status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if _, err := other.HTTPClient(2 * time.Second).Get(u); err == nil {
+		t.Errorf("want the other pin not affected by the first one")
+	}
+	if _, err := pinned.HTTPClient(2 * time.Second).Get(u); err != nil {
+		t.Errorf("want the first pin not affected by the other one, got %v", err)
+	}
+}
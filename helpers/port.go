@@ -0,0 +1,26 @@
+package helpers
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// IsPortOpen returns true if a TCP connection can be established with the
+// given host and port before the timeout expires. Errors connecting to the
+// port, including timeouts, are considered as the port being closed, only the
+// cancellation of the context is returned as an error. The connection is
+// established through the SOCKS5 proxy set with SetSOCKS5Proxy, if any.
+func IsPortOpen(ctx context.Context, host, port string, timeout time.Duration) (bool, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := dial(dialCtx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		return false, nil
+	}
+	conn.Close() // nolint
+	return true, nil
+}
@@ -0,0 +1,59 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+
+	"golang.org/x/net/proxy"
+)
+
+// contextDialer defines the methods used by the network helpers to establish
+// connections, this allows to route them through a proxy.
+type contextDialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+var (
+	dialerMu sync.RWMutex
+	dialer   contextDialer = &net.Dialer{}
+)
+
+// SetSOCKS5Proxy makes the network helpers connect to the targets through the
+// SOCKS5 proxy listening in the given address, in the form host:port. An empty
+// address makes the helpers connect directly to the targets. When an error is
+// returned the dialer used by the helpers is not modified.
+func SetSOCKS5Proxy(addr string) error {
+	if addr == "" {
+		setDialer(&net.Dialer{})
+		return nil
+	}
+	d, err := proxy.SOCKS5("tcp", addr, nil, proxy.Direct)
+	if err != nil {
+		return err
+	}
+	cd, ok := d.(proxy.ContextDialer)
+	if !ok {
+		return errors.New("SOCKS5 dialer does not support contexts")
+	}
+	setDialer(cd)
+	return nil
+}
+
+// currentDialer returns the dialer used by the network helpers.
+func currentDialer() contextDialer {
+	dialerMu.RLock()
+	defer dialerMu.RUnlock()
+	return dialer
+}
+
+// setDialer sets the dialer used by the network helpers and returns the
+// previous one.
+func setDialer(d contextDialer) contextDialer {
+	dialerMu.Lock()
+	defer dialerMu.Unlock()
+	prev := dialer
+	dialer = d
+	return prev
+}
@@ -0,0 +1,164 @@
+package helpers
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// stubSOCKS5 implements a minimal SOCKS5 server, without authentication, that
+// sends to a channel the address of each CONNECT request it receives.
+type stubSOCKS5 struct {
+	l        net.Listener
+	requests chan string
+}
+
+func newStubSOCKS5(t *testing.T) *stubSOCKS5 {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &stubSOCKS5{l: l, requests: make(chan string, 10)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn)
+		}
+	}()
+	return s
+}
+
+func (s *stubSOCKS5) handle(conn net.Conn) {
+	defer conn.Close() // nolint
+	// Greeting: version, number of methods and methods.
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(conn, make([]byte, header[1])); err != nil {
+		return
+	}
+	if _, err := conn.Write([]byte{5, 0}); err != nil {
+		return
+	}
+	// Request: version, command, reserved and address type.
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(conn, req); err != nil {
+		return
+	}
+	var host string
+	switch req[3] {
+	case 1:
+		ip := make([]byte, net.IPv4len)
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return
+		}
+		host = net.IP(ip).String()
+	case 3:
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return
+		}
+		name := make([]byte, l[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return
+		}
+		host = string(name)
+	default:
+		return
+	}
+	p := make([]byte, 2)
+	if _, err := io.ReadFull(conn, p); err != nil {
+		return
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(p))))
+	s.requests <- addr
+	target, err := net.Dial("tcp", addr)
+	if err != nil {
+		// Connection refused.
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0}) // nolint
+		return
+	}
+	defer target.Close() // nolint
+	if _, err := conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+		return
+	}
+	go io.Copy(target, conn) // nolint
+	io.Copy(conn, target)    // nolint
+}
+
+func TestIsPortOpenSOCKS5Proxy(t *testing.T) {
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close() // nolint
+	go func() {
+		for {
+			conn, err := target.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close() // nolint
+		}
+	}()
+	s := newStubSOCKS5(t)
+	defer s.l.Close() // nolint
+	if err := SetSOCKS5Proxy(s.l.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+	defer SetSOCKS5Proxy("") // nolint
+
+	host, port, err := net.SplitHostPort(target.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	open, err := IsPortOpen(context.Background(), host, port, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !open {
+		t.Errorf("want port %s open", port)
+	}
+	select {
+	case got := <-s.requests:
+		if got != target.Addr().String() {
+			t.Errorf("want proxy request to %s, got %s", target.Addr().String(), got)
+		}
+	default:
+		t.Errorf("the connection was not routed through the proxy")
+	}
+}
+
+func TestSetSOCKS5ProxyConcurrent(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close() // nolint
+	host, port, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer SetSOCKS5Proxy("") // nolint
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			SetSOCKS5Proxy("") // nolint
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		if _, err := IsPortOpen(context.Background(), host, port, time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+}
@@ -0,0 +1,76 @@
+// Package ratelimit provides a token bucket limiter and a semaphore that can
+// be shared by the helpers that launch processes in order to throttle them.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrInvalidRate is returned when trying to create a limiter with a rate or a
+// burst less or equal to zero.
+var ErrInvalidRate = errors.New("rate and burst must be greater than zero")
+
+// Limiter implements a token bucket rate limiter. A nil *Limiter is valid and
+// never limits. It's safe to use it from multiple goroutines.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// New creates a limiter that allows rate events per second with bursts of at
+// most burst events.
+func New(rate float64, burst int) (*Limiter, error) {
+	if rate <= 0 || burst <= 0 {
+		return nil, ErrInvalidRate
+	}
+	return &Limiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}, nil
+}
+
+// Wait blocks until an event is allowed to happen or the context is done, in
+// which case the error of the context is returned.
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	// Reserve a token, if there are no tokens available the caller must wait
+	// the time needed for the reserved token to be generated.
+	l.tokens--
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+	if wait == 0 {
+		return nil
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		// Give back the reserved token.
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
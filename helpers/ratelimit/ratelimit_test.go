@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimiterWait(t *testing.T) {
+	l, err := New(20, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 5
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	elapsed := time.Since(start)
+	// The first event is allowed immediately, the rest must wait 50ms each.
+	min := time.Duration(n-1) * 50 * time.Millisecond
+	if elapsed < min-10*time.Millisecond {
+		t.Errorf("want at least %s elapsed, got %s", min, elapsed)
+	}
+}
+
+func TestLimiterWaitContextDone(t *testing.T) {
+	l, err := New(0.1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("want error %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestNilLimiter(t *testing.T) {
+	var l *Limiter
+	if err := l.Wait(context.Background()); err != nil {
+		t.Errorf("want no error from a nil limiter, got %v", err)
+	}
+}
+
+func TestNewInvalidRate(t *testing.T) {
+	if _, err := New(0, 1); err != ErrInvalidRate {
+		t.Errorf("want error %v, got %v", ErrInvalidRate, err)
+	}
+}
@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+)
+
+// Semaphore bounds the number of operations that run at the same time. A nil
+// *Semaphore is valid and never limits. It's safe to use it from multiple
+// goroutines.
+type Semaphore struct {
+	slots chan struct{}
+}
+
+// NewSemaphore creates a semaphore that allows at most n operations running at
+// the same time. A value of n less or equal to zero returns a nil semaphore,
+// that is, one that doesn't limit.
+func NewSemaphore(n int) *Semaphore {
+	if n <= 0 {
+		return nil
+	}
+	return &Semaphore{slots: make(chan struct{}, n)}
+}
+
+// Acquire blocks until an operation is allowed to start or the context is
+// done, in which case the error of the context is returned. When no error is
+// returned the caller must call Release after the operation finishes.
+func (s *Semaphore) Acquire(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release signals that an operation started after a successful call to
+// Acquire has finished.
+func (s *Semaphore) Release() {
+	if s == nil {
+		return
+	}
+	<-s.slots
+}
+
+var (
+	processesMu sync.RWMutex
+	processes   *Semaphore
+)
+
+// SetMaxProcesses sets the maximum number of processes launched by the
+// helpers of the sdk, e.g.: check.NewProcessChecker or the functions of the
+// command package, that run at the same time in the current process. A value
+// less or equal to zero removes the limit. The processes already running when
+// the limit is changed are not accounted in the new limit.
+func SetMaxProcesses(n int) {
+	processesMu.Lock()
+	defer processesMu.Unlock()
+	processes = NewSemaphore(n)
+}
+
+// AcquireProcess blocks until a new process is allowed to be launched
+// according to the limit set with SetMaxProcesses or the context is done, in
+// which case the error of the context is returned. When no error is returned,
+// the returned function must be called after the process finishes.
+func AcquireProcess(ctx context.Context) (release func(), err error) {
+	processesMu.RLock()
+	s := processes
+	processesMu.RUnlock()
+	if err := s.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	return s.Release, nil
+}
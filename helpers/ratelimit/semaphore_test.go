@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSemaphoreAcquire(t *testing.T) {
+	s := NewSemaphore(2)
+	for i := 0; i < 2; i++ {
+		if err := s.Acquire(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Acquire(ctx); err != context.DeadlineExceeded {
+		t.Errorf("want error %v, got %v", context.DeadlineExceeded, err)
+	}
+	s.Release()
+	if err := s.Acquire(context.Background()); err != nil {
+		t.Errorf("want no error after a release, got %v", err)
+	}
+}
+
+func TestNilSemaphore(t *testing.T) {
+	s := NewSemaphore(0)
+	if s != nil {
+		t.Fatalf("want a nil semaphore, got %v", s)
+	}
+	for i := 0; i < 10; i++ {
+		if err := s.Acquire(context.Background()); err != nil {
+			t.Errorf("want no error from a nil semaphore, got %v", err)
+		}
+	}
+	s.Release()
+}
+
+func TestAcquireProcess(t *testing.T) {
+	SetMaxProcesses(1)
+	defer SetMaxProcesses(0)
+	release, err := AcquireProcess(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := AcquireProcess(ctx); err != context.DeadlineExceeded {
+		t.Errorf("want error %v, got %v", context.DeadlineExceeded, err)
+	}
+	release()
+	release, err = AcquireProcess(context.Background())
+	if err != nil {
+		t.Errorf("want no error after a release, got %v", err)
+	}
+	release()
+}
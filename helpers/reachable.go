@@ -0,0 +1,83 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const reachableTimeout = 5 * time.Second
+
+// ErrUnreachable is returned, wrapped with the reason, by CheckReachable when
+// the target can not be reached.
+var ErrUnreachable = errors.New("target unreachable")
+
+// CheckReachable verifies that the given target can be reached before
+// scanning it:
+// * Hostnames and domain names must resolve.
+// * IPs must have at least one of the given ports open. When no ports are
+// given IPs are not verified, as a host that only exposes ports nobody asked
+// for, e.g.: behind a firewall, would be considered unreachable.
+// * The host of URLs must resolve and the port of the URL, or the default
+// port of its scheme, must be open.
+// Other targets, like CIDRs, AWS accounts or Docker images, are not verified.
+// It returns an error wrapping ErrUnreachable, with the reason, when the target
+// is not reachable.
+func CheckReachable(ctx context.Context, target string, ports ...string) error {
+	t := Target{Value: target}
+	switch {
+	case t.IsIP():
+		if len(ports) == 0 {
+			return nil
+		}
+		responsive, err := probeHosts(ctx, []string{target}, ports, reachableTimeout)
+		if responsive == "" {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("%w: none of the ports %s of %s is open", ErrUnreachable, strings.Join(ports, ","), target)
+		}
+		return err
+	case t.IsURL():
+		_, host, port, err := URLSchemeInfo(target)
+		if err != nil {
+			// URLs with other schemes are not verified.
+			return nil
+		}
+		if net.ParseIP(host) == nil {
+			if err := checkResolves(ctx, host); err != nil {
+				return err
+			}
+		}
+		open, err := IsPortOpen(ctx, host, port, reachableTimeout)
+		if err != nil {
+			return err
+		}
+		if !open {
+			return fmt.Errorf("%w: port %s of %s is not open", ErrUnreachable, port, host)
+		}
+		return nil
+	case t.IsCIDR(), t.IsAWSAccount(), t.IsDockerImage():
+		return nil
+	}
+	return checkResolves(ctx, target)
+}
+
+// checkResolves returns an error wrapping ErrUnreachable if the given host
+// doesn't resolve.
+func checkResolves(ctx context.Context, host string) error {
+	addrs, err := resolver.LookupIPAddr(ctx, toASCIIHostname(host))
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s does not resolve: %v", ErrUnreachable, host, err)
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("%w: no IPs found for %s", ErrUnreachable, host)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+)
+
+func TestCheckReachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close() // nolint
+	openPort := strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := strconv.Itoa(closed.Addr().(*net.TCPAddr).Port)
+	closed.Close() // nolint
+
+	defer withResolver(stubResolver(func(host string) ([]net.IPAddr, error) {
+		if host == "www.example.com" {
+			return []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}}, nil
+		}
+		return nil, errors.New("lookup " + host + ": no such host")
+	}))()
+
+	tests := []struct {
+		name            string
+		target          string
+		ports           []string
+		wantUnreachable bool
+	}{
+		{
+			name:   "HostnameResolves",
+			target: "www.example.com",
+		},
+		{
+			name:            "HostnameNotResolves",
+			target:          "notexists.example.com",
+			wantUnreachable: true,
+		},
+		{
+			name:   "URLPortOpen",
+			target: "http://127.0.0.1:" + openPort + "/path",
+		},
+		{
+			name:            "URLPortClosed",
+			target:          "http://127.0.0.1:" + closedPort + "/",
+			wantUnreachable: true,
+		},
+		{
+			name:            "URLHostNotResolves",
+			target:          "https://notexists.example.com/",
+			wantUnreachable: true,
+		},
+		{
+			name:   "IPPortOpen",
+			target: "127.0.0.1",
+			ports:  []string{closedPort, openPort},
+		},
+		{
+			name:            "IPPortsClosed",
+			target:          "127.0.0.1",
+			ports:           []string{closedPort},
+			wantUnreachable: true,
+		},
+		{
+			name:   "IPWithoutPorts",
+			target: "127.0.0.1",
+		},
+		{
+			name:   "AWSAccount",
+			target: "arn:aws:iam::111111111111:root",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckReachable(context.Background(), tt.target, tt.ports...)
+			if got := errors.Is(err, ErrUnreachable); got != tt.wantUnreachable {
+				t.Errorf("want unreachable %v, got error %v", tt.wantUnreachable, err)
+			}
+			if !tt.wantUnreachable && err != nil {
+				t.Errorf("want no error, got %v", err)
+			}
+		})
+	}
+}
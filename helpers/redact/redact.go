@@ -0,0 +1,195 @@
+// Package redact provides helpers to mask secrets, like passwords or tokens,
+// in logs and reports.
+package redact
+
+import (
+	"encoding/json"
+	"strings"
+
+	report "github.com/adevinta/vulcan-report"
+	log "github.com/sirupsen/logrus"
+)
+
+// Mask is the value secrets are replaced with.
+const Mask = "********"
+
+// Keys contains the words that, contained in the name of a key, make
+// its value to be considered a secret. The comparison is case insensitive and
+// ignores the characters "_" and "-", for instance: "API_KEY", "apiKey" and
+// "db-password" are all considered secret keys.
+var Keys = []string{"password", "passwd", "token", "secret", "apikey"}
+
+// IsSecretKey returns true if the value of the given key must be considered a
+// secret.
+func IsSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	key = strings.NewReplacer("_", "", "-", "").Replace(key)
+	for _, k := range Keys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// JSON returns a copy of the given JSON document with the values of the secret
+// keys masked, including the values of the JSON documents encoded as strings
+// inside it, e.g.: the options of a check. It also returns true if any value
+// was masked. If the data is not a valid JSON document it's returned as is.
+func JSON(data []byte) ([]byte, bool) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return data, false
+	}
+	v, masked := value(v)
+	if !masked {
+		return data, false
+	}
+	redacted, err := json.Marshal(v)
+	if err != nil {
+		return data, false
+	}
+	return redacted, true
+}
+
+func value(v interface{}) (interface{}, bool) {
+	masked := false
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			if IsSecretKey(k) {
+				t[k] = Mask
+				masked = true
+				continue
+			}
+			var m bool
+			t[k], m = value(e)
+			masked = masked || m
+		}
+	case []interface{}:
+		for i, e := range t {
+			var m bool
+			t[i], m = value(e)
+			masked = masked || m
+		}
+	case string:
+		trimmed := strings.TrimSpace(t)
+		if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+			if redacted, m := JSON([]byte(t)); m {
+				return string(redacted), true
+			}
+		}
+	}
+	return v, masked
+}
+
+// ResultData returns a copy of the given result with the secrets masked in the
+// data, in the rows of the resources of the vulnerabilities, under the columns
+// with a secret key name, and in the details of the vulnerabilities when they
+// contain JSON documents. The given result is not modified. The sdk doesn't
+// mask the reports sent to the agent, checkers that store secrets in them can
+// use this function to mask them explicitly.
+func ResultData(r report.ResultData) report.ResultData {
+	if data, masked := JSON(r.Data); masked {
+		r.Data = data
+	}
+	r.Vulnerabilities = vulnerabilities(r.Vulnerabilities)
+	return r
+}
+
+func vulnerabilities(vulns []report.Vulnerability) []report.Vulnerability {
+	if vulns == nil {
+		return nil
+	}
+	redacted := make([]report.Vulnerability, 0, len(vulns))
+	for _, v := range vulns {
+		if details, masked := JSON([]byte(v.Details)); masked {
+			v.Details = string(details)
+		}
+		v.Resources = resources(v.Resources)
+		v.Vulnerabilities = vulnerabilities(v.Vulnerabilities)
+		redacted = append(redacted, v)
+	}
+	return redacted
+}
+
+func resources(groups []report.ResourcesGroup) []report.ResourcesGroup {
+	if groups == nil {
+		return nil
+	}
+	redacted := make([]report.ResourcesGroup, 0, len(groups))
+	for _, g := range groups {
+		rows := make([]map[string]string, 0, len(g.Rows))
+		for _, row := range g.Rows {
+			r := make(map[string]string, len(row))
+			for k, v := range row {
+				if IsSecretKey(k) {
+					v = Mask
+				}
+				r[k] = v
+			}
+			rows = append(rows, r)
+		}
+		if g.Rows == nil {
+			rows = nil
+		}
+		g.Rows = rows
+		redacted = append(redacted, g)
+	}
+	return redacted
+}
+
+// Hook is a logrus hook that masks the secrets in the fields of the log
+// entries. The values of the fields with a secret key name are masked, and the
+// secrets in fields containing JSON documents or values that can be encoded as
+// JSON, like the config of the check, are masked too.
+type Hook struct{}
+
+// Levels returns the levels the hook applies to, that is: all of them.
+func (Hook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire masks the secrets in the fields of the entry.
+func (Hook) Fire(entry *log.Entry) error {
+	// The fields of the entry can be shared with other entries so a new map is
+	// created instead of modifying it.
+	var fields log.Fields
+	for k, v := range entry.Data {
+		redacted, masked := field(k, v)
+		if !masked {
+			continue
+		}
+		if fields == nil {
+			fields = make(log.Fields, len(entry.Data))
+			for k, v := range entry.Data {
+				fields[k] = v
+			}
+		}
+		fields[k] = redacted
+	}
+	if fields != nil {
+		entry.Data = fields
+	}
+	return nil
+}
+
+func field(k string, v interface{}) (interface{}, bool) {
+	if IsSecretKey(k) {
+		return Mask, true
+	}
+	switch t := v.(type) {
+	case nil, bool, int, int64, float32, float64, error:
+		return v, false
+	case string:
+		return value(t)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v, false
+	}
+	if redacted, masked := JSON(data); masked {
+		return string(redacted), true
+	}
+	return v, false
+}
@@ -0,0 +1,110 @@
+package redact
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	report "github.com/adevinta/vulcan-report"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestHook(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := log.New()
+	logger.Out = buf
+	logger.Formatter = &log.TextFormatter{DisableTimestamp: true}
+	logger.AddHook(Hook{})
+
+	opts := `{"user":"admin","db_password":"hunter2"}`
+	logger.WithFields(log.Fields{
+		"token":  "s3cr3t-t0k3n",
+		"target": "www.example.com",
+		"opts":   opts,
+	}).Info("Running check")
+
+	out := buf.String()
+	for _, secret := range []string{"s3cr3t-t0k3n", "hunter2"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("secret %q not masked in the log output %q", secret, out)
+		}
+	}
+	for _, want := range []string{"token=\"" + Mask + "\"", "www.example.com", "admin"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("want %q in the log output %q", want, out)
+		}
+	}
+}
+
+func TestHookStruct(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := log.New()
+	logger.Out = buf
+	logger.AddHook(Hook{})
+	conf := struct {
+		Target string
+		Opts   string
+	}{
+		Target: "www.example.com",
+		Opts:   `{"api_key":"k3y"}`,
+	}
+	logger.WithField("config", conf).Info("Building check")
+	if out := buf.String(); strings.Contains(out, "k3y") {
+		t.Errorf("secret not masked in the log output %q", out)
+	}
+}
+
+func TestIsSecretKey(t *testing.T) {
+	tests := map[string]bool{
+		"password":    true,
+		"DB_PASSWORD": true,
+		"apiKey":      true,
+		"x-api-key":   true,
+		"AuthToken":   true,
+		"secret":      true,
+		"target":      false,
+		"checkID":     false,
+	}
+	for key, want := range tests {
+		if got := IsSecretKey(key); got != want {
+			t.Errorf("IsSecretKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestResultData(t *testing.T) {
+	r := report.ResultData{
+		Data: []byte(`{"credentials":{"user":"admin","password":"hunter2"}}`),
+		Vulnerabilities: []report.Vulnerability{
+			{
+				Summary: "Exposed credentials",
+				Resources: []report.ResourcesGroup{
+					{
+						Name:   "Credentials",
+						Header: []string{"User", "Token"},
+						Rows:   []map[string]string{{"User": "admin", "Token": "t0k3n"}},
+					},
+				},
+			},
+		},
+	}
+	got := ResultData(r)
+	if strings.Contains(string(got.Data), "hunter2") {
+		t.Errorf("secret not masked in the data %s", got.Data)
+	}
+	if row := got.Vulnerabilities[0].Resources[0].Rows[0]; row["Token"] != Mask || row["User"] != "admin" {
+		t.Errorf("want the token column masked, got %v", row)
+	}
+	// The original result must not be modified.
+	if !strings.Contains(string(r.Data), "hunter2") || r.Vulnerabilities[0].Resources[0].Rows[0]["Token"] != "t0k3n" {
+		t.Errorf("the original result was modified")
+	}
+}
+
+func TestJSONNotJSON(t *testing.T) {
+	data := []byte("password=hunter2")
+	got, masked := JSON(data)
+	if masked || string(got) != string(data) {
+		t.Errorf("want data not modified, got %s", got)
+	}
+}
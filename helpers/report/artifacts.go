@@ -0,0 +1,114 @@
+package report
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+
+	vulcanreport "github.com/adevinta/vulcan-report"
+)
+
+const (
+	// ArtifactsGroupName is the name of the resources group of a
+	// vulnerability that contains its artifacts.
+	ArtifactsGroupName = "Artifacts"
+
+	// MaxArtifactBytes is the maximum size of the content of an artifact
+	// embedded in a vulnerability. Bigger artifacts must be uploaded and
+	// attached by reference.
+	MaxArtifactBytes = 256 * 1024
+)
+
+var artifactColumns = []string{"Name", "Content-Type", "Size", "Data", "Reference"}
+
+var (
+	// ErrArtifactTooLarge is returned when trying to attach an artifact with
+	// a content bigger than MaxArtifactBytes.
+	ErrArtifactTooLarge = errors.New("artifact content too large")
+
+	// ErrInvalidArtifact is returned when trying to attach an artifact
+	// without name or without exactly one of content or reference.
+	ErrInvalidArtifact = errors.New("artifact must have a name and either a content or a reference")
+)
+
+// Artifact is an evidence supporting a vulnerability, e.g.: a screenshot or a
+// pcap file. The artifact is embedded in the report, in which case it has a
+// Content, or is stored elsewhere and the report only contains a Reference to
+// it, e.g.: the URL where it was uploaded.
+type Artifact struct {
+	Name        string
+	ContentType string
+	Content     []byte
+	Reference   string
+}
+
+// AttachArtifacts adds the given artifacts to the resources group named
+// ArtifactsGroupName of a vulnerability, creating the group if it doesn't
+// exist. The content of the artifacts is encoded in base64. If any of the
+// artifacts is not valid or its content is bigger than MaxArtifactBytes an
+// error is returned and the vulnerability is not modified.
+func AttachArtifacts(v *vulcanreport.Vulnerability, artifacts ...Artifact) error {
+	rows := make([]map[string]string, 0, len(artifacts))
+	for _, a := range artifacts {
+		if a.Name == "" || (len(a.Content) == 0) == (a.Reference == "") {
+			return fmt.Errorf("%w: %s", ErrInvalidArtifact, a.Name)
+		}
+		if len(a.Content) > MaxArtifactBytes {
+			return fmt.Errorf("%w: %s has %d bytes, max %d", ErrArtifactTooLarge, a.Name, len(a.Content), MaxArtifactBytes)
+		}
+		row := map[string]string{
+			"Name":         a.Name,
+			"Content-Type": a.ContentType,
+			"Size":         strconv.Itoa(len(a.Content)),
+			"Data":         "",
+			"Reference":    a.Reference,
+		}
+		if len(a.Content) > 0 {
+			row["Data"] = base64.StdEncoding.EncodeToString(a.Content)
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	for i, g := range v.Resources {
+		if g.Name == ArtifactsGroupName {
+			v.Resources[i].Rows = append(g.Rows, rows...)
+			return nil
+		}
+	}
+	v.Resources = append(v.Resources, vulcanreport.ResourcesGroup{
+		Name:   ArtifactsGroupName,
+		Header: artifactColumns,
+		Rows:   rows,
+	})
+	return nil
+}
+
+// Artifacts returns the artifacts attached to a vulnerability with
+// AttachArtifacts.
+func Artifacts(v vulcanreport.Vulnerability) ([]Artifact, error) {
+	var artifacts []Artifact
+	for _, g := range v.Resources {
+		if g.Name != ArtifactsGroupName {
+			continue
+		}
+		for _, row := range g.Rows {
+			a := Artifact{
+				Name:        row["Name"],
+				ContentType: row["Content-Type"],
+				Reference:   row["Reference"],
+			}
+			if data := row["Data"]; data != "" {
+				content, err := base64.StdEncoding.DecodeString(data)
+				if err != nil {
+					return nil, fmt.Errorf("invalid content of the artifact %s: %v", a.Name, err)
+				}
+				a.Content = content
+			}
+			artifacts = append(artifacts, a)
+		}
+	}
+	return artifacts, nil
+}
@@ -0,0 +1,94 @@
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	vulcanreport "github.com/adevinta/vulcan-report"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAttachArtifacts(t *testing.T) {
+	artifacts := []Artifact{
+		{
+			Name:        "login.png",
+			ContentType: "image/png",
+			Content:     []byte{0x89, 'P', 'N', 'G', 0x0d, 0x0a, 0x1a, 0x0a},
+		},
+		{
+			Name:        "capture.pcap",
+			ContentType: "application/vnd.tcpdump.pcap",
+			Reference:   "https://artifacts.example.com/capture.pcap",
+		},
+	}
+	v := vulcanreport.Vulnerability{Summary: "Default credentials"}
+	if err := AttachArtifacts(&v, artifacts[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := AttachArtifacts(&v, artifacts[1]); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(v.Resources); n != 1 {
+		t.Fatalf("want the artifacts in 1 resources group, got %d", n)
+	}
+
+	var r vulcanreport.Report
+	r.AddVulnerabilities(v)
+	content, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got vulcanreport.Report
+	if err := json.Unmarshal(content, &got); err != nil {
+		t.Fatal(err)
+	}
+	gotArtifacts, err := Artifacts(got.Vulnerabilities[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(artifacts, gotArtifacts); diff != "" {
+		t.Errorf("artifacts differ after round-tripping the report, diff %s", diff)
+	}
+}
+
+func TestAttachArtifactsInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		artifact Artifact
+		wantErr  error
+	}{
+		{
+			name:     "TooLarge",
+			artifact: Artifact{Name: "big.bin", Content: make([]byte, MaxArtifactBytes+1)},
+			wantErr:  ErrArtifactTooLarge,
+		},
+		{
+			name:     "NoName",
+			artifact: Artifact{Content: []byte("data")},
+			wantErr:  ErrInvalidArtifact,
+		},
+		{
+			name:     "NoContentNorReference",
+			artifact: Artifact{Name: "empty.txt"},
+			wantErr:  ErrInvalidArtifact,
+		},
+		{
+			name:     "ContentAndReference",
+			artifact: Artifact{Name: "both.txt", Content: []byte("data"), Reference: "https://example.com/both.txt"},
+			wantErr:  ErrInvalidArtifact,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := vulcanreport.Vulnerability{}
+			err := AttachArtifacts(&v, Artifact{Name: "ok.txt", Content: []byte("ok")}, tt.artifact)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("want error %v, got %v", tt.wantErr, err)
+			}
+			if len(v.Resources) != 0 {
+				t.Errorf("want the vulnerability not modified, got resources %v", v.Resources)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package report
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	vulcanreport "github.com/adevinta/vulcan-report"
+)
+
+// FingerprintLabelPrefix is the prefix of the label that stores the
+// fingerprint of a vulnerability, as the report doesn't have a specific field
+// for it.
+const FingerprintLabelPrefix = "fingerprint:"
+
+// Fingerprint returns a deterministic identifier for the given vulnerability
+// affecting the given resource, e.g.: an URL or a "host:port". The fingerprint
+// only depends on the summary and the CWE of the vulnerability and on the
+// resource, so the same finding in different scans has the same fingerprint
+// even if, for instance, its score or its details change.
+func Fingerprint(v vulcanreport.Vulnerability, resource string) string {
+	h := sha256.New()
+	for _, field := range []string{
+		v.Summary,
+		strconv.FormatUint(uint64(v.CWEID), 10),
+		strings.TrimSpace(resource),
+	} {
+		// The fields are NUL terminated so different combinations of fields
+		// can't produce the same input.
+		h.Write([]byte(field)) // nolint
+		h.Write([]byte{0})     // nolint
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// SetFingerprint computes the fingerprint of the given vulnerability affecting
+// the given resource and stores it in the labels of the vulnerability
+// prefixed with FingerprintLabelPrefix, replacing the previous fingerprint, if
+// any.
+func SetFingerprint(v *vulcanreport.Vulnerability, resource string) {
+	label := FingerprintLabelPrefix + Fingerprint(*v, resource)
+	labels := make([]string, 0, len(v.Labels)+1)
+	for _, l := range v.Labels {
+		if !strings.HasPrefix(l, FingerprintLabelPrefix) {
+			labels = append(labels, l)
+		}
+	}
+	v.Labels = append(labels, label)
+}
+
+// GetFingerprint returns the fingerprint stored in the vulnerability with
+// SetFingerprint, or an empty string if the vulnerability doesn't have one.
+func GetFingerprint(v vulcanreport.Vulnerability) string {
+	for _, l := range v.Labels {
+		if strings.HasPrefix(l, FingerprintLabelPrefix) {
+			return strings.TrimPrefix(l, FingerprintLabelPrefix)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,43 @@
+package report
+
+import (
+	"testing"
+
+	vulcanreport "github.com/adevinta/vulcan-report"
+)
+
+func TestFingerprint(t *testing.T) {
+	v := vulcanreport.Vulnerability{Summary: "Exposed SSH", Score: 5, CWEID: 284}
+	// The same finding in another scan, with a different score.
+	again := vulcanreport.Vulnerability{Summary: "Exposed SSH", Score: 6.5, CWEID: 284}
+
+	fp := Fingerprint(v, "10.0.0.1:22")
+	if len(fp) != 64 {
+		t.Fatalf("want a sha256 hex fingerprint, got %q", fp)
+	}
+	if got := Fingerprint(again, "10.0.0.1:22"); got != fp {
+		t.Errorf("want the same fingerprint for the same finding, got %q and %q", fp, got)
+	}
+	if got := Fingerprint(v, "10.0.0.2:22"); got == fp {
+		t.Errorf("want a different fingerprint for a different resource, got %q", got)
+	}
+	other := vulcanreport.Vulnerability{Summary: "Exposed Telnet", CWEID: 284}
+	if got := Fingerprint(other, "10.0.0.1:22"); got == fp {
+		t.Errorf("want a different fingerprint for a different vulnerability, got %q", got)
+	}
+}
+
+func TestSetFingerprint(t *testing.T) {
+	v := vulcanreport.Vulnerability{Summary: "Exposed SSH", Labels: []string{"issue"}}
+	if got := GetFingerprint(v); got != "" {
+		t.Fatalf("want no fingerprint, got %q", got)
+	}
+	SetFingerprint(&v, "10.0.0.1:22")
+	SetFingerprint(&v, "10.0.0.2:22")
+	if len(v.Labels) != 2 || v.Labels[0] != "issue" {
+		t.Fatalf("want the fingerprint to be replaced, got labels %v", v.Labels)
+	}
+	if want, got := Fingerprint(v, "10.0.0.2:22"), GetFingerprint(v); got != want {
+		t.Errorf("want fingerprint %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,90 @@
+package report
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	vulcanreport "github.com/adevinta/vulcan-report"
+)
+
+var (
+	cveRegex = regexp.MustCompile(`^CVE-\d{4}-\d{4,}$`)
+	cweRegex = regexp.MustCompile(`^CWE-(\d{1,9})$`)
+)
+
+// ValidCVE returns true if the given identifier has the format of a CVE
+// identifier: CVE-YYYY-NNNN, where the sequence number has four or more digits.
+func ValidCVE(id string) bool {
+	return cveRegex.MatchString(id)
+}
+
+// ValidCWE returns true if the given identifier has the format of a CWE
+// identifier: CWE-NNN.
+func ValidCWE(id string) bool {
+	return cweRegex.MatchString(id)
+}
+
+// AddIdentifiers attaches the given CVE and CWE identifiers to a vulnerability.
+// The identifiers are case insensitive and are normalized to upper case. The
+// CVE identifiers are added to the labels of the vulnerability, skipping the
+// ones already present. The CWE identifier is stored in the CWEID field of the
+// vulnerability, as a vulnerability can only have one, an error is returned if
+// a different CWE is already set or more than one CWE is given. If any of the
+// identifiers is not valid an error is returned and the vulnerability is not
+// modified.
+func AddIdentifiers(v *vulcanreport.Vulnerability, ids ...string) error {
+	var cves []string
+	var cwe uint32
+	for _, id := range ids {
+		id = strings.ToUpper(strings.TrimSpace(id))
+		switch {
+		case ValidCVE(id):
+			cves = append(cves, id)
+		case ValidCWE(id):
+			n, err := strconv.ParseUint(cweRegex.FindStringSubmatch(id)[1], 10, 32)
+			if err != nil {
+				return fmt.Errorf("invalid CWE identifier %s: %v", id, err)
+			}
+			if cwe != 0 && cwe != uint32(n) {
+				return fmt.Errorf("more than one CWE identifier given: CWE-%d and %s", cwe, id)
+			}
+			cwe = uint32(n)
+		default:
+			return fmt.Errorf("invalid CVE or CWE identifier %q", id)
+		}
+	}
+	if cwe != 0 && v.CWEID != 0 && v.CWEID != cwe {
+		return fmt.Errorf("the vulnerability already has the identifier CWE-%d", v.CWEID)
+	}
+	if cwe != 0 {
+		v.CWEID = cwe
+	}
+	for _, cve := range cves {
+		if !contains(v.Labels, cve) {
+			v.Labels = append(v.Labels, cve)
+		}
+	}
+	return nil
+}
+
+// CVEs returns the CVE identifiers attached to the given vulnerability.
+func CVEs(v vulcanreport.Vulnerability) []string {
+	var cves []string
+	for _, l := range v.Labels {
+		if ValidCVE(l) {
+			cves = append(cves, l)
+		}
+	}
+	return cves
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
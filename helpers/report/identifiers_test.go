@@ -0,0 +1,94 @@
+package report
+
+import (
+	"reflect"
+	"testing"
+
+	vulcanreport "github.com/adevinta/vulcan-report"
+)
+
+func TestAddIdentifiers(t *testing.T) {
+	tests := []struct {
+		name       string
+		vuln       vulcanreport.Vulnerability
+		ids        []string
+		wantLabels []string
+		wantCWE    uint32
+		wantErr    bool
+	}{
+		{
+			name:       "ValidIdentifiers",
+			vuln:       vulcanreport.Vulnerability{Labels: []string{"issue"}},
+			ids:        []string{"CVE-2021-44228", "cwe-502", "cve-2021-44228", "CVE-2014-0160"},
+			wantLabels: []string{"issue", "CVE-2021-44228", "CVE-2014-0160"},
+			wantCWE:    502,
+		},
+		{
+			name:       "AlreadyPresent",
+			vuln:       vulcanreport.Vulnerability{Labels: []string{"CVE-2014-0160"}, CWEID: 119},
+			ids:        []string{"CVE-2014-0160", "CWE-119"},
+			wantLabels: []string{"CVE-2014-0160"},
+			wantCWE:    119,
+		},
+		{
+			name:    "InvalidCVE",
+			vuln:    vulcanreport.Vulnerability{},
+			ids:     []string{"CVE-2014-0160", "CVE-14-1"},
+			wantErr: true,
+		},
+		{
+			name:    "InvalidCWE",
+			vuln:    vulcanreport.Vulnerability{},
+			ids:     []string{"CWE-abc"},
+			wantErr: true,
+		},
+		{
+			name:    "UnknownIdentifier",
+			vuln:    vulcanreport.Vulnerability{},
+			ids:     []string{"GHSA-jfh8-c2jp-5v3q"},
+			wantErr: true,
+		},
+		{
+			name:    "TwoCWEs",
+			vuln:    vulcanreport.Vulnerability{},
+			ids:     []string{"CWE-79", "CWE-89"},
+			wantErr: true,
+		},
+		{
+			name:    "DifferentCWEAlreadySet",
+			vuln:    vulcanreport.Vulnerability{CWEID: 79},
+			ids:     []string{"CWE-89"},
+			wantCWE: 79,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := tt.vuln
+			err := AddIdentifiers(&v, tt.ids...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AddIdentifiers() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !reflect.DeepEqual(v, tt.vuln) {
+					t.Errorf("want the vulnerability unmodified, got %+v", v)
+				}
+				return
+			}
+			if !reflect.DeepEqual(v.Labels, tt.wantLabels) {
+				t.Errorf("want labels %v, got %v", tt.wantLabels, v.Labels)
+			}
+			if v.CWEID != tt.wantCWE {
+				t.Errorf("want CWE %d, got %d", tt.wantCWE, v.CWEID)
+			}
+		})
+	}
+}
+
+func TestCVEs(t *testing.T) {
+	v := vulcanreport.Vulnerability{Labels: []string{"issue", "CVE-2021-44228", "CVE-2014-0160"}}
+	want := []string{"CVE-2021-44228", "CVE-2014-0160"}
+	if got := CVEs(v); !reflect.DeepEqual(got, want) {
+		t.Errorf("want CVEs %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,67 @@
+// Package report provides helpers to build uniformly structured data to be
+// included in the vulnerabilities reported by a check.
+package report
+
+import (
+	"fmt"
+
+	vulcanreport "github.com/adevinta/vulcan-report"
+)
+
+// ResourceTable builds a resources group with named columns and rows, that
+// can be attached to a vulnerability.
+type ResourceTable struct {
+	name    string
+	columns []string
+	rows    []map[string]string
+}
+
+// NewResourceTable creates a resource table with the given name and columns.
+func NewResourceTable(name string, columns ...string) *ResourceTable {
+	return &ResourceTable{
+		name:    name,
+		columns: columns,
+		rows:    []map[string]string{},
+	}
+}
+
+// AddRow adds a row to the table. The values must be specified in the same
+// order than the columns of the table, an error is returned if the number of
+// values doesn't match the number of columns.
+func (t *ResourceTable) AddRow(values ...string) error {
+	if len(values) != len(t.columns) {
+		return fmt.Errorf("the table %s has %d columns but %d values were provided", t.name, len(t.columns), len(values))
+	}
+	row := make(map[string]string, len(values))
+	for i, c := range t.columns {
+		row[c] = values[i]
+	}
+	t.rows = append(t.rows, row)
+	return nil
+}
+
+// Len returns the number of rows in the table.
+func (t *ResourceTable) Len() int {
+	return len(t.rows)
+}
+
+// Group returns the table as a resources group suitable to be included in a
+// vulnerability.
+func (t *ResourceTable) Group() vulcanreport.ResourcesGroup {
+	return vulcanreport.ResourcesGroup{
+		Name:   t.name,
+		Header: t.columns,
+		Rows:   t.rows,
+	}
+}
+
+// AddResources adds the given tables to the resources of a vulnerability.
+// Tables without rows are not added.
+func AddResources(v *vulcanreport.Vulnerability, tables ...*ResourceTable) {
+	for _, t := range tables {
+		if t.Len() == 0 {
+			continue
+		}
+		v.Resources = append(v.Resources, t.Group())
+	}
+}
@@ -0,0 +1,35 @@
+package report
+
+import (
+	"encoding/json"
+	"testing"
+
+	vulcanreport "github.com/adevinta/vulcan-report"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestResourceTable(t *testing.T) {
+	table := NewResourceTable("Open Ports", "Port", "Protocol", "Service")
+	if err := table.AddRow("22", "tcp", "ssh"); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.AddRow("80", "tcp", "http"); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.AddRow("443", "tcp"); err == nil {
+		t.Error("want error adding a row with less values than columns")
+	}
+	empty := NewResourceTable("Empty", "Column")
+	v := vulcanreport.Vulnerability{Summary: "Open ports"}
+	AddResources(&v, table, empty)
+
+	got, err := json.Marshal(v.Resources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"name":"Open Ports","header":["Port","Protocol","Service"],` +
+		`"rows":[{"Port":"22","Protocol":"tcp","Service":"ssh"},{"Port":"80","Protocol":"tcp","Service":"http"}]}]`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("serialized resources != want, diff %s", diff)
+	}
+}
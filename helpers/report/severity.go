@@ -0,0 +1,169 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	vulcanreport "github.com/adevinta/vulcan-report"
+)
+
+// GateExitCode is the exit code returned by ExitCode for the reports that
+// contain vulnerabilities with a severity equal or higher than the threshold.
+// It's different from the exit codes returned when the check itself fails, so
+// the CI pipelines can tell both cases apart.
+const GateExitCode = 2
+
+var severities = map[string]vulcanreport.SeverityRank{
+	"none":     vulcanreport.SeverityNone,
+	"low":      vulcanreport.SeverityLow,
+	"medium":   vulcanreport.SeverityMedium,
+	"high":     vulcanreport.SeverityHigh,
+	"critical": vulcanreport.SeverityCritical,
+}
+
+// ParseSeverity returns the severity with the given name, one of: "none",
+// "low", "medium", "high" or "critical". The name is case insensitive.
+func ParseSeverity(name string) (vulcanreport.SeverityRank, error) {
+	s, ok := severities[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return 0, fmt.Errorf("invalid severity %q", name)
+	}
+	return s, nil
+}
+
+// SeverityBand maps the scores equal or greater than MinScore, and lower than
+// the MinScore of the next band, to a severity.
+type SeverityBand struct {
+	MinScore float32
+	Severity vulcanreport.SeverityRank
+}
+
+// SeverityMapping maps the scores of the vulnerabilities to severities using
+// bands, sorted by ascending MinScore. The scores lower than the MinScore of
+// the first band have the severity SeverityNone. An empty mapping uses the
+// thresholds defined by the vulcan-report package.
+type SeverityMapping []SeverityBand
+
+// Severity returns the severity of the given score according to the mapping.
+func (m SeverityMapping) Severity(score float32) vulcanreport.SeverityRank {
+	if len(m) == 0 {
+		return vulcanreport.RankSeverity(score)
+	}
+	severity := vulcanreport.SeverityNone
+	for _, b := range m {
+		if score < b.MinScore {
+			break
+		}
+		severity = b.Severity
+	}
+	return severity
+}
+
+// ParseSeverityBands returns the mapping defined by the given minimum scores
+// by severity name, e.g.: {"critical": 9.5, "high": 8, "medium": 5, "low":
+// 0.1}. The names are parsed with ParseSeverity and the minimum scores must
+// increase with the severities. An empty map returns an empty mapping.
+func ParseSeverityBands(bands map[string]float32) (SeverityMapping, error) {
+	var m SeverityMapping
+	for name, min := range bands {
+		s, err := ParseSeverity(name)
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, SeverityBand{MinScore: min, Severity: s})
+	}
+	sort.Slice(m, func(i, j int) bool {
+		return m[i].Severity < m[j].Severity
+	})
+	for i := 1; i < len(m); i++ {
+		if m[i].MinScore <= m[i-1].MinScore {
+			return nil, fmt.Errorf("the min score of the severity %s must be greater than the one of the severity %s",
+				severityName(m[i].Severity), severityName(m[i-1].Severity))
+		}
+	}
+	return m, nil
+}
+
+func severityName(s vulcanreport.SeverityRank) string {
+	for name, rank := range severities {
+		if rank == s {
+			return name
+		}
+	}
+	return fmt.Sprintf("%d", s)
+}
+
+var (
+	severityMappingMu sync.RWMutex
+	severityMapping   SeverityMapping
+)
+
+// SetSeverityMapping sets the mapping used by ScoreSeverity, and thus by the
+// other helpers of this package and by the formatters of the sdk, to compute
+// the severity of the vulnerabilities. An empty mapping restores the
+// thresholds defined by the vulcan-report package.
+func SetSeverityMapping(m SeverityMapping) {
+	severityMappingMu.Lock()
+	defer severityMappingMu.Unlock()
+	severityMapping = m
+}
+
+// SetSeverityBands sets, as SetSeverityMapping does, the mapping defined by the
+// given minimum scores by severity name as described by ParseSeverityBands.
+func SetSeverityBands(bands map[string]float32) error {
+	m, err := ParseSeverityBands(bands)
+	if err != nil {
+		return err
+	}
+	SetSeverityMapping(m)
+	return nil
+}
+
+// ScoreSeverity returns the severity of the given score according to the
+// mapping set with SetSeverityMapping.
+func ScoreSeverity(score float32) vulcanreport.SeverityRank {
+	severityMappingMu.RLock()
+	defer severityMappingMu.RUnlock()
+	return severityMapping.Severity(score)
+}
+
+// Severity returns the severity of the given vulnerability according to the
+// mapping set with SetSeverityMapping.
+func Severity(v vulcanreport.Vulnerability) vulcanreport.SeverityRank {
+	return ScoreSeverity(v.Score)
+}
+
+// MaxSeverity returns the highest severity of the vulnerabilities of the
+// result, including the ones nested in other vulnerabilities. The second
+// returned value is false if the result contains no vulnerabilities.
+func MaxSeverity(r *vulcanreport.ResultData) (vulcanreport.SeverityRank, bool) {
+	if r == nil {
+		return vulcanreport.SeverityNone, false
+	}
+	return maxSeverity(r.Vulnerabilities)
+}
+
+func maxSeverity(vulns []vulcanreport.Vulnerability) (vulcanreport.SeverityRank, bool) {
+	max, found := vulcanreport.SeverityNone, false
+	for i := range vulns {
+		if s := Severity(vulns[i]); !found || s > max {
+			max, found = s, true
+		}
+		if s, ok := maxSeverity(vulns[i].Vulnerabilities); ok && s > max {
+			max = s
+		}
+	}
+	return max, found
+}
+
+// ExitCode returns GateExitCode if the result contains any vulnerability with
+// a severity equal or higher than the given threshold and 0 otherwise. Take
+// into account that a threshold of SeverityNone gates on any vulnerability.
+func ExitCode(r *vulcanreport.ResultData, threshold vulcanreport.SeverityRank) int {
+	if max, ok := MaxSeverity(r); ok && max >= threshold {
+		return GateExitCode
+	}
+	return 0
+}
@@ -0,0 +1,131 @@
+package report
+
+import (
+	"testing"
+
+	vulcanreport "github.com/adevinta/vulcan-report"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name      string
+		result    *vulcanreport.ResultData
+		threshold string
+		want      int
+	}{
+		{
+			name: "HighFinding",
+			result: &vulcanreport.ResultData{
+				Vulnerabilities: []vulcanreport.Vulnerability{
+					{Summary: "low", Score: 2},
+					{Summary: "high", Score: 8},
+				},
+			},
+			threshold: "high",
+			want:      GateExitCode,
+		},
+		{
+			name: "NestedCriticalFinding",
+			result: &vulcanreport.ResultData{
+				Vulnerabilities: []vulcanreport.Vulnerability{
+					{
+						Summary:         "group",
+						Vulnerabilities: []vulcanreport.Vulnerability{{Summary: "critical", Score: 9.8}},
+					},
+				},
+			},
+			threshold: "High",
+			want:      GateExitCode,
+		},
+		{
+			name: "BelowThreshold",
+			result: &vulcanreport.ResultData{
+				Vulnerabilities: []vulcanreport.Vulnerability{{Summary: "medium", Score: 5}},
+			},
+			threshold: "high",
+			want:      0,
+		},
+		{
+			name:      "CleanReport",
+			result:    &vulcanreport.ResultData{},
+			threshold: "none",
+			want:      0,
+		},
+		{
+			name:      "NilReport",
+			threshold: "low",
+			want:      0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			threshold, err := ParseSeverity(tt.threshold)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ExitCode(tt.result, threshold); got != tt.want {
+				t.Errorf("ExitCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+	if _, err := ParseSeverity("urgent"); err == nil {
+		t.Errorf("want error parsing an invalid severity")
+	}
+}
+
+func TestSetSeverityBands(t *testing.T) {
+	tests := []struct {
+		name    string
+		bands   map[string]float32
+		score   float32
+		want    vulcanreport.SeverityRank
+		wantErr bool
+	}{
+		{
+			name:  "Defaults",
+			score: 8,
+			want:  vulcanreport.SeverityHigh,
+		},
+		{
+			name:  "OverriddenBand",
+			bands: map[string]float32{"critical": 9.5, "high": 8, "medium": 5, "low": 0.1},
+			score: 9.2,
+			want:  vulcanreport.SeverityHigh,
+		},
+		{
+			name:  "BelowLowestBand",
+			bands: map[string]float32{"High": 7, "medium": 3},
+			score: 2,
+			want:  vulcanreport.SeverityNone,
+		},
+		{
+			name:    "UnknownSeverity",
+			bands:   map[string]float32{"urgent": 9},
+			wantErr: true,
+		},
+		{
+			name:    "DecreasingScores",
+			bands:   map[string]float32{"critical": 5, "high": 7},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer SetSeverityMapping(nil)
+			err := SetSeverityBands(tt.bands)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := ScoreSeverity(tt.score); got != tt.want {
+				t.Errorf("ScoreSeverity(%v) = %v, want %v", tt.score, got, tt.want)
+			}
+			v := vulcanreport.Vulnerability{Summary: "vuln", Score: tt.score}
+			if got, _ := MaxSeverity(&vulcanreport.ResultData{Vulnerabilities: []vulcanreport.Vulnerability{v}}); got != tt.want {
+				t.Errorf("MaxSeverity() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
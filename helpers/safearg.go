@@ -0,0 +1,52 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ErrUnsafeArg is returned by SafeArg when a target can not be safely used as
+// an argument of a command.
+var ErrUnsafeArg = errors.New("unsafe command argument")
+
+// unsafeArgChars contains the characters that are not valid in any kind of
+// target and that have a special meaning for a shell.
+const unsafeArgChars = "`;|<>\\\"'{}$"
+
+// SafeArg validates that the given target can be passed as an argument to a
+// command executed with, for instance, the command helpers. It returns the
+// target without leading and trailing whitespaces, or ErrUnsafeArg if the
+// target is empty, starts with a "-", so it could be interpreted as a flag,
+// e.g.: "-oG/tmp/x", or contains whitespaces, control characters or shell
+// metacharacters that are not valid in hostnames, IPs, URLs or Docker images.
+// Take into account that the function does not make the target safe to be
+// used as part of a command line interpreted by a shell.
+func SafeArg(target string) (string, error) {
+	arg := strings.TrimSpace(target)
+	if arg == "" {
+		return "", fmt.Errorf("%w: empty argument", ErrUnsafeArg)
+	}
+	if strings.HasPrefix(arg, "-") {
+		return "", fmt.Errorf("%w: argument %q looks like a flag", ErrUnsafeArg, arg)
+	}
+	for _, r := range arg {
+		if unicode.IsSpace(r) || unicode.IsControl(r) || strings.ContainsRune(unsafeArgChars, r) {
+			return "", fmt.Errorf("%w: argument %q contains the character %q", ErrUnsafeArg, arg, r)
+		}
+	}
+	return arg, nil
+}
+
+// WithEndOfOptions returns the given flags followed by the "--" argument and
+// by the positional arguments, so the command does not interpret any of the
+// positional arguments as a flag even if it starts with a "-". The command
+// must support the "--" argument to signal the end of the options, as most of
+// the commands using getopt do.
+func WithEndOfOptions(flags []string, positional ...string) []string {
+	args := make([]string, 0, len(flags)+len(positional)+1)
+	args = append(args, flags...)
+	args = append(args, "--")
+	return append(args, positional...)
+}
@@ -0,0 +1,89 @@
+package helpers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSafeArg(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "Hostname",
+			target: " www.example.com ",
+			want:   "www.example.com",
+		},
+		{
+			name:   "CIDR",
+			target: "10.0.0.0/24",
+			want:   "10.0.0.0/24",
+		},
+		{
+			name:   "URL",
+			target: "https://www.example.com:8443/path?a=1&b=2#frag",
+			want:   "https://www.example.com:8443/path?a=1&b=2#frag",
+		},
+		{
+			name:   "DockerImage",
+			target: "registry.example.com/org/image:1.0",
+			want:   "registry.example.com/org/image:1.0",
+		},
+		{
+			name:    "Flag",
+			target:  "-oG/tmp/x",
+			wantErr: true,
+		},
+		{
+			name:    "CommandSubstitution",
+			target:  "example.com$(id)",
+			wantErr: true,
+		},
+		{
+			name:    "CommandSeparator",
+			target:  "example.com;id",
+			wantErr: true,
+		},
+		{
+			name:    "EmbeddedSpace",
+			target:  "example.com -oG /tmp/x",
+			wantErr: true,
+		},
+		{
+			name:    "NewLine",
+			target:  "example.com\nid",
+			wantErr: true,
+		},
+		{
+			name:    "Empty",
+			target:  "  ",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SafeArg(tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SafeArg() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrUnsafeArg) {
+				t.Errorf("want error %v, got %v", ErrUnsafeArg, err)
+			}
+			if got != tt.want {
+				t.Errorf("SafeArg() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithEndOfOptions(t *testing.T) {
+	got := WithEndOfOptions([]string{"-p", "22"}, "-oG/tmp/x")
+	want := []string{"-p", "22", "--", "-oG/tmp/x"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WithEndOfOptions() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,143 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultSweepPorts are the TCP ports probed by SweepCIDR when no ports are
+// specified.
+var DefaultSweepPorts = []string{"22", "80", "443"}
+
+// SweepResult contains the result of sweeping a CIDR with SweepCIDR.
+type SweepResult struct {
+	// Probed is the number of hosts probed.
+	Probed int
+	// Responsive is the address of the first host found with an open port, if
+	// any.
+	Responsive string
+	// LikelyEmpty is true when none of the probed hosts responded before the
+	// budget expired, so a full scan of the CIDR can be skipped. Take into
+	// account that the result is inconclusive, the CIDR could contain hosts
+	// not included in the sample or not listening in the ports probed.
+	LikelyEmpty bool
+}
+
+// SweepCIDR probes, using IsPortOpen, the given TCP ports of a sample of, at
+// most, sampleSize hosts evenly distributed in a CIDR. The hosts are probed in
+// rounds of exponentially increasing size, 1, 2, 4... hosts, and the sweep
+// stops as soon as a host with an open port is found. If no host responds
+// before the budget expires or all the hosts in the sample are probed, the
+// result is marked as likely empty. If no ports are specified the
+// DefaultSweepPorts are probed.
+func SweepCIDR(ctx context.Context, cidr string, ports []string, sampleSize int, budget time.Duration) (SweepResult, error) {
+	if len(ports) == 0 {
+		ports = DefaultSweepPorts
+	}
+	if sampleSize < 1 {
+		return SweepResult{}, errors.New("invalid sample size")
+	}
+	hosts, err := sampleCIDR(cidr, sampleSize)
+	if err != nil {
+		return SweepResult{}, err
+	}
+	budgetCtx, cancel := context.WithTimeout(ctx, budget)
+	defer cancel()
+	var res SweepResult
+	for size := 1; len(hosts) > 0; size *= 2 {
+		if size > len(hosts) {
+			size = len(hosts)
+		}
+		responsive, err := probeHosts(budgetCtx, hosts[:size], ports, budget)
+		res.Probed += size
+		hosts = hosts[size:]
+		if ctx.Err() != nil {
+			return SweepResult{}, ctx.Err()
+		}
+		if responsive != "" {
+			res.Responsive = responsive
+			return res, nil
+		}
+		if err != nil {
+			// The budget has expired.
+			break
+		}
+	}
+	res.LikelyEmpty = true
+	return res, nil
+}
+
+// probeHosts probes concurrently the given ports of the hosts and returns the
+// first host found with an open port, if any.
+func probeHosts(ctx context.Context, hosts, ports []string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg         sync.WaitGroup
+		mu         sync.Mutex
+		responsive string
+	)
+	for _, host := range hosts {
+		for _, port := range ports {
+			wg.Add(1)
+			go func(host, port string) {
+				defer wg.Done()
+				open, err := IsPortOpen(ctx, host, port, timeout)
+				if err != nil || !open {
+					return
+				}
+				mu.Lock()
+				if responsive == "" {
+					responsive = host
+				}
+				mu.Unlock()
+				cancel()
+			}(host, port)
+		}
+	}
+	wg.Wait()
+	if responsive != "" {
+		return responsive, nil
+	}
+	return "", ctx.Err()
+}
+
+// sampleCIDR returns, at most, n addresses evenly distributed in the given
+// CIDR. The network and broadcast addresses of IPv4 CIDRs are excluded.
+func sampleCIDR(cidr string, n int) ([]string, error) {
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	ip := ipnet.IP
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	ones, bits := ipnet.Mask.Size()
+	total := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	first := big.NewInt(0)
+	if bits == 32 && bits-ones >= 2 {
+		first = big.NewInt(1)
+		total.Sub(total, big.NewInt(2))
+	}
+	if total.Cmp(big.NewInt(int64(n))) < 0 {
+		n = int(total.Int64())
+	}
+	base := new(big.Int).SetBytes(ip)
+	step := new(big.Int).Div(total, big.NewInt(int64(n)))
+	hosts := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		offset := new(big.Int).Mul(step, big.NewInt(int64(i)))
+		offset.Add(offset, first)
+		addr := new(big.Int).Add(base, offset).Bytes()
+		// Restore the leading zero bytes removed by the big.Int.
+		hostIP := make(net.IP, len(ip))
+		copy(hostIP[len(ip)-len(addr):], addr)
+		hosts = append(hosts, hostIP.String())
+	}
+	return hosts, nil
+}
@@ -0,0 +1,114 @@
+package helpers
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// freePort returns a TCP port with no listeners in the loopback interface.
+func freePort(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+	ln.Close() // nolint
+	return port
+}
+
+func TestSweepCIDR(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close() // nolint
+	openPort := strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+	closedPort := freePort(t)
+
+	tests := []struct {
+		name    string
+		cidr    string
+		ports   []string
+		want    SweepResult
+		wantErr bool
+	}{
+		{
+			name:  "NoListeners",
+			cidr:  "127.0.0.0/29",
+			ports: []string{closedPort},
+			want:  SweepResult{Probed: 4, LikelyEmpty: true},
+		},
+		{
+			name:  "Listener",
+			cidr:  "127.0.0.1/32",
+			ports: []string{closedPort, openPort},
+			want:  SweepResult{Probed: 1, Responsive: "127.0.0.1"},
+		},
+		{
+			name:    "InvalidCIDR",
+			cidr:    "127.0.0.1",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SweepCIDR(context.Background(), tt.cidr, tt.ports, 4, 2*time.Second)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SweepCIDR() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("sweep result differs, diff %s", diff)
+			}
+		})
+	}
+}
+
+func TestSampleCIDR(t *testing.T) {
+	tests := []struct {
+		name string
+		cidr string
+		n    int
+		want []string
+	}{
+		{
+			name: "IPv4",
+			cidr: "10.0.0.0/24",
+			n:    4,
+			want: []string{"10.0.0.1", "10.0.0.64", "10.0.0.127", "10.0.0.190"},
+		},
+		{
+			name: "SampleBiggerThanCIDR",
+			cidr: "10.0.0.0/30",
+			n:    10,
+			want: []string{"10.0.0.1", "10.0.0.2"},
+		},
+		{
+			name: "SingleHost",
+			cidr: "10.0.0.1/32",
+			n:    3,
+			want: []string{"10.0.0.1"},
+		},
+		{
+			name: "IPv6",
+			cidr: "2001:db8::/126",
+			n:    2,
+			want: []string{"2001:db8::", "2001:db8::2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sampleCIDR(tt.cidr, tt.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("sample differs, diff %s", diff)
+			}
+		})
+	}
+}
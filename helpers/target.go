@@ -1,15 +1,19 @@
 package helpers
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
 	"net"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws/arn"
 	"github.com/miekg/dns"
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
 )
 
 const (
@@ -17,8 +21,15 @@ const (
 	noSuchHostErrorToken = "no such host"
 )
 
+// dnsExchanger defines the methods of a dns.Client used by the helpers to
+// query the DNS servers, this allows to replace the client in tests.
+type dnsExchanger interface {
+	ExchangeContext(ctx context.Context, m *dns.Msg, address string) (r *dns.Msg, rtt time.Duration, err error)
+}
+
 var (
-	dnsConf *dns.ClientConfig
+	dnsConf   *dns.ClientConfig
+	dnsClient dnsExchanger = &dns.Client{}
 	// ErrFailedToGetDNSAnswer represents error returned when unable to get a valid answer from the current configured dns
 	// servers.
 	ErrFailedToGetDNSAnswer = errors.New("failed to get a valid answer")
@@ -89,7 +100,7 @@ func (t Target) IsHostname() (bool, error) {
 		return *t.hostname, nil
 	}
 
-	r, err := net.LookupIP(t.Value)
+	r, err := resolver.LookupIPAddr(context.Background(), toASCIIHostname(t.Value))
 	if err != nil {
 		// We want to differentiate the error: errNoSuchHost = errors.New("no such host")
 		// defined in the package net but, as is not exported, we need to fallback to
@@ -147,6 +158,19 @@ func (t Target) IsDockerImage() bool {
 	return false
 }
 
+// RegistrableDomain returns the registrable domain, that is the effective top
+// level domain plus one label, of a hostname or URL target according to the
+// public suffix list, e.g.: the registrable domain of "a.b.example.co.uk" is
+// "example.co.uk". An error is returned for IP and CIDR targets.
+func (t Target) RegistrableDomain() (string, error) {
+	host := targetHost(strings.TrimSpace(t.Value))
+	if net.ParseIP(host) != nil || t.IsCIDR() {
+		return "", fmt.Errorf("target %s is not a hostname", t.Value)
+	}
+	host = strings.ToLower(strings.TrimSuffix(toASCIIHostname(host), "."))
+	return publicsuffix.EffectiveTLDPlusOne(host)
+}
+
 // IsDomainName returns true if a query to a domain server returns a SOA record for the target.
 func (t Target) IsDomainName() (bool, error) {
 	if t.domainName != nil {
@@ -169,10 +193,41 @@ func (t Target) IsDomainName() (bool, error) {
 // IsDomainName returns true if a query to a domain server returns a SOA record for the
 // asset value.
 func IsDomainName(asset string) (bool, error) {
-	return hasSOARecord(asset)
+	return hasSOARecord(toASCIIHostname(asset))
+}
+
+// toASCIIHostname returns the ASCII form of a hostname that can be
+// percent-encoded and contain unicode characters, i.e.: an internationalized
+// domain name, for instance: "b%C3%BCcher.de" and "bücher.de" are both
+// converted to "xn--bcher-kva.de". If the hostname can not be converted it's
+// returned as is.
+func toASCIIHostname(name string) string {
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+	ascii, err := idna.Lookup.ToASCII(name)
+	if err != nil {
+		return name
+	}
+	return ascii
+}
+
+// IsDomainNameContext is like IsDomainName but the queries to the domain
+// servers are cancelled when the given context is done.
+func IsDomainNameContext(ctx context.Context, asset string) (bool, error) {
+	return hasSOARecordContext(ctx, toASCIIHostname(asset))
 }
 
 func hasSOARecord(address string) (bool, error) {
+	return hasSOARecordContext(context.Background(), address)
+}
+
+// hasSOARecordContext queries in parallel the local configured dns servers
+// for the SOA record of the given address and uses the first successful
+// answer. If no server answers successfully, the answer of any server
+// that responded is used, so a failing server doesn't fail the lookup, and
+// only if every server fails an error is returned.
+func hasSOARecordContext(ctx context.Context, address string) (bool, error) {
 	var err error
 	// Read the local dns server config only the first time.
 	if dnsConf == nil {
@@ -181,25 +236,45 @@ func hasSOARecord(address string) (bool, error) {
 			return false, err
 		}
 	}
+	if len(dnsConf.Servers) == 0 {
+		return false, ErrFailedToGetDNSAnswer
+	}
 	m := &dns.Msg{}
 	address = address + "."
 	m.SetQuestion(address, dns.TypeSOA)
-	c := dns.Client{}
-	var r *dns.Msg
-	// Try to get an answer using local configured dns servers.
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type answer struct {
+		r   *dns.Msg
+		err error
+	}
+	answers := make(chan answer, len(dnsConf.Servers))
 	for _, srv := range dnsConf.Servers {
-		r = nil
-		r, _, err = c.Exchange(m, fmt.Sprintf("%s:%s", srv, dnsConf.Port))
-		if err != nil {
-			return false, err
+		go func(srv string) {
+			r, _, err := dnsClient.ExchangeContext(ctx, m.Copy(), net.JoinHostPort(srv, dnsConf.Port))
+			answers <- answer{r, err}
+		}(srv)
+	}
+	var r *dns.Msg
+	err = ErrFailedToGetDNSAnswer
+	for range dnsConf.Servers {
+		a := <-answers
+		if a.err != nil {
+			err = a.err
+			continue
 		}
-
-		if r.Rcode == dns.RcodeSuccess && r != nil {
+		if a.r == nil {
+			continue
+		}
+		if a.r.Rcode == dns.RcodeSuccess {
+			r = a.r
 			break
 		}
+		r = a.r
 	}
 	if r == nil {
-		return false, ErrFailedToGetDNSAnswer
+		return false, err
 	}
 	return soaHeaderForName(r, address), nil
 }
@@ -222,9 +297,15 @@ func soaHeaderForName(r *dns.Msg, name string) bool {
 // where we want to scan a domain that also is a hostname which
 // resolves to a private IP. In that case the domain won't be scanned
 // while it should.
+// AWS accounts are always scannable as they are checked through the AWS APIs
+// instead of connecting to them.
 func IsScannable(asset string) bool {
 	t := Target{Value: asset}
 
+	if t.IsAWSAccount() {
+		return true
+	}
+
 	if t.IsIP() || t.IsCIDR() {
 		log.Printf("%s is IP or CIDR", t.Value)
 		ok, _ := isAllowed(t.Value) // nolint
@@ -232,15 +313,35 @@ func IsScannable(asset string) bool {
 	}
 
 	if t.IsURL() {
-		u, _ := url.ParseRequestURI(t.Value) // nolint
-		asset = u.Hostname()
+		asset = targetHost(t.Value)
+		// The host of a URL can be an IP literal, including bracketed IPv6
+		// addresses, in that case there is no need to resolve it.
+		if net.ParseIP(asset) != nil {
+			ok, _ := isAllowed(asset) // nolint
+			return ok
+		}
 	}
 
-	addrs, _ := net.LookupHost(asset) // nolint
+	ipAddrs, _ := resolver.LookupIPAddr(context.Background(), toASCIIHostname(asset)) // nolint
+	addrs := make([]string, 0, len(ipAddrs))
+	for _, addr := range ipAddrs {
+		addrs = append(addrs, addr.IP.String())
+	}
 
 	return verifyIPs(addrs)
 }
 
+// targetHost returns the host of a URL target without the port and, in case of
+// an IPv6 literal, without the brackets. For targets that are not URLs the
+// value is returned as is.
+func targetHost(target string) string {
+	u, err := url.ParseRequestURI(target)
+	if err != nil || u.Host == "" {
+		return target
+	}
+	return u.Hostname()
+}
+
 func verifyIPs(addrs []string) bool {
 	for _, addr := range addrs {
 		if ok, err := isAllowed(addr); err != nil || !ok {
@@ -251,25 +352,28 @@ func verifyIPs(addrs []string) bool {
 }
 
 func isAllowed(addr string) (bool, error) {
-	addrCIDR := addr
-	var nets []*net.IPNet
-	if strings.Contains(addr, ".") {
-		if !strings.Contains(addr, "/") {
-			addrCIDR = fmt.Sprintf("%s/32", addr)
+	// The family of the address is determined by parsing it because checking
+	// if it contains a "." is not reliable for IPv6 addresses with an embedded
+	// IPv4 address, e.g.: ::ffff:10.0.0.1.
+	var ip net.IP
+	if strings.Contains(addr, "/") {
+		_, addrNet, err := net.ParseCIDR(addr)
+		if err != nil {
+			return false, fmt.Errorf("error parsing the ip address %s", addr)
 		}
-		nets = NotScannableNetsIPV4
+		ip = addrNet.IP
 	} else {
-		if !strings.Contains(addr, "/") {
-			addrCIDR = fmt.Sprintf("%s/128", addr)
+		ip = net.ParseIP(addr)
+		if ip == nil {
+			return false, fmt.Errorf("error parsing the ip address %s", addr)
 		}
-		nets = NotScannableNetsIPV6
 	}
-	_, addrNet, err := net.ParseCIDR(addrCIDR)
-	if err != nil {
-		return false, fmt.Errorf("error parsing the ip address %s", addr)
+	nets := NotScannableNetsIPV6
+	if ip.To4() != nil {
+		nets = NotScannableNetsIPV4
 	}
 	for _, n := range nets {
-		if n.Contains(addrNet.IP) {
+		if n.Contains(ip) {
 			return false, nil
 		}
 	}
@@ -1,8 +1,14 @@
 package helpers
 
 import (
+	"context"
+	"errors"
+	"net"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/miekg/dns"
 )
 
 func TestTarget_IsHostname(t *testing.T) {
@@ -100,6 +106,73 @@ func TestHasSOARecord(t *testing.T) {
 	}
 }
 
+// stubDNSExchanger answers the DNS queries sent to each server address using
+// the given functions.
+type stubDNSExchanger map[string]func(m *dns.Msg) (*dns.Msg, error)
+
+func (s stubDNSExchanger) ExchangeContext(ctx context.Context, m *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+	r, err := s[address](m)
+	return r, 0, err
+}
+
+func TestHasSOARecordFailover(t *testing.T) {
+	failing := func(m *dns.Msg) (*dns.Msg, error) {
+		return nil, errors.New("connection refused")
+	}
+	working := func(m *dns.Msg) (*dns.Msg, error) {
+		r := &dns.Msg{}
+		r.SetReply(m)
+		name := m.Question[0].Name
+		if name == "example.com." {
+			r.Answer = append(r.Answer, &dns.SOA{
+				Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeSOA, Class: dns.ClassINET},
+			})
+		}
+		return r, nil
+	}
+	tests := []struct {
+		name    string
+		servers stubDNSExchanger
+		domain  string
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:    "FailingAndWorkingServers",
+			servers: stubDNSExchanger{"192.0.2.1:53": failing, "192.0.2.2:53": working},
+			domain:  "example.com",
+			want:    true,
+		},
+		{
+			name:    "NotADomain",
+			servers: stubDNSExchanger{"192.0.2.1:53": failing, "192.0.2.2:53": working},
+			domain:  "www.example.com",
+			want:    false,
+		},
+		{
+			name:    "AllServersFailing",
+			servers: stubDNSExchanger{"192.0.2.1:53": failing, "192.0.2.2:53": failing},
+			domain:  "example.com",
+			wantErr: true,
+		},
+	}
+	prevConf, prevClient := dnsConf, dnsClient
+	defer func() { dnsConf, dnsClient = prevConf, prevClient }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dnsConf = &dns.ClientConfig{Servers: []string{"192.0.2.1", "192.0.2.2"}, Port: "53"}
+			dnsClient = tt.servers
+			got, err := IsDomainNameContext(context.Background(), tt.domain)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("IsDomainNameContext() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestTarget_IsDomainName(t *testing.T) {
 	var f bool
 	type fields struct {
@@ -271,6 +344,20 @@ func TestTarget_IsCIDR(t *testing.T) {
 	}
 }
 
+func TestIsScannableAWSAccount(t *testing.T) {
+	var lookups []string
+	defer withResolver(stubResolver(func(host string) ([]net.IPAddr, error) {
+		lookups = append(lookups, host)
+		return nil, errors.New("lookup " + host + ": no such host")
+	}))()
+	if !IsScannable("arn:aws:iam::111111111111:root") {
+		t.Errorf("want AWS account scannable")
+	}
+	if len(lookups) != 0 {
+		t.Errorf("want no DNS lookups, got lookups of %v", lookups)
+	}
+}
+
 func TestTarget_IsScannable(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -337,6 +424,26 @@ func TestTarget_IsScannable(t *testing.T) {
 			target: "https://localhost",
 			want:   false,
 		},
+		{
+			name:   "ReservedIPv6LiteralURL",
+			target: "http://[2001:db8::1]/",
+			want:   false,
+		},
+		{
+			name:   "LoopbackIPv6LiteralURLWithPort",
+			target: "http://[::1]:8080",
+			want:   false,
+		},
+		{
+			name:   "PublicIPv6LiteralURL",
+			target: "https://[2606:4700:4700::1111]:8443/path",
+			want:   true,
+		},
+		{
+			name:   "IPv4MappedIPv6PrivateIP",
+			target: "::ffff:10.0.0.1",
+			want:   false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -349,3 +456,145 @@ func TestTarget_IsScannable(t *testing.T) {
 		})
 	}
 }
+
+func TestTargetHost(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		want   string
+	}{
+		{
+			name:   "IPv6LiteralURL",
+			target: "http://[2001:db8::1]/",
+			want:   "2001:db8::1",
+		},
+		{
+			name:   "IPv6LiteralURLWithPort",
+			target: "http://[::1]:8080",
+			want:   "::1",
+		},
+		{
+			name:   "HostnameURLWithPort",
+			target: "https://www.example.com:8443/path",
+			want:   "www.example.com",
+		},
+		{
+			name:   "NotAURL",
+			target: "www.example.com",
+			want:   "www.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := targetHost(tt.target)
+			if got != tt.want {
+				t.Errorf("targetHost() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToASCIIHostname(t *testing.T) {
+	tests := []struct {
+		name     string
+		hostname string
+		want     string
+	}{
+		{
+			name:     "Unicode",
+			hostname: "bücher.de",
+			want:     "xn--bcher-kva.de",
+		},
+		{
+			name:     "PercentEncoded",
+			hostname: "b%C3%BCcher.de",
+			want:     "xn--bcher-kva.de",
+		},
+		{
+			name:     "Punycode",
+			hostname: "xn--bcher-kva.de",
+			want:     "xn--bcher-kva.de",
+		},
+		{
+			name:     "ASCII",
+			hostname: "www.example.com",
+			want:     "www.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := toASCIIHostname(tt.hostname); got != tt.want {
+				t.Errorf("toASCIIHostname(%q) = %q, want %q", tt.hostname, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTarget_IsHostnameIDN(t *testing.T) {
+	defer withResolver(stubResolver(func(host string) ([]net.IPAddr, error) {
+		if host != "xn--bcher-kva.de" {
+			return nil, &net.DNSError{Err: "no such host", Name: host}
+		}
+		return []net.IPAddr{{IP: net.ParseIP("203.0.113.10")}}, nil
+	}))()
+	for _, value := range []string{"bücher.de", "xn--bcher-kva.de"} {
+		got, err := Target{Value: value}.IsHostname()
+		if err != nil {
+			t.Fatalf("Target.IsHostname() for %q error = %v", value, err)
+		}
+		if !got {
+			t.Errorf("Target.IsHostname() for %q = false, want true", value)
+		}
+	}
+}
+
+func TestTarget_RegistrableDomain(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "MultiLabelSuffix",
+			target: "a.b.example.co.uk",
+			want:   "example.co.uk",
+		},
+		{
+			name:   "PrivateSuffix",
+			target: "x.github.io",
+			want:   "x.github.io",
+		},
+		{
+			name:   "URL",
+			target: "https://www.Example.com:8443/path",
+			want:   "example.com",
+		},
+		{
+			name:    "IP",
+			target:  "203.0.113.10",
+			wantErr: true,
+		},
+		{
+			name:    "CIDR",
+			target:  "203.0.113.0/24",
+			wantErr: true,
+		},
+		{
+			name:    "PublicSuffix",
+			target:  "co.uk",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Target{Value: tt.target}.RegistrableDomain()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Target.RegistrableDomain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Target.RegistrableDomain() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package helpers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strings"
+)
+
+// TargetMatcher matches targets against a list of IPs, CIDRs and hostname
+// globs.
+type TargetMatcher struct {
+	nets  []*net.IPNet
+	globs []string
+}
+
+// LoadTargetList reads the list of entries in the file with the given path and
+// returns a TargetMatcher for them. The file must contain one entry per line,
+// in the format accepted by NewTargetMatcher. Empty lines and lines starting
+// with "#" are ignored.
+func LoadTargetList(filePath string) (TargetMatcher, error) {
+	f, err := os.Open(filePath) // nolint
+	if err != nil {
+		return TargetMatcher{}, err
+	}
+	defer f.Close() // nolint
+
+	entries, err := ReadTargetLines(f)
+	if err != nil {
+		return TargetMatcher{}, err
+	}
+	return NewTargetMatcher(entries)
+}
+
+// ReadTargetLines reads a list of targets, one per line, from the given reader.
+// The leading and trailing whitespaces of each line are removed and empty
+// lines and lines starting with "#" are ignored.
+func ReadTargetLines(r io.Reader) ([]string, error) {
+	var targets []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		target := strings.TrimSpace(s.Text())
+		if target == "" || strings.HasPrefix(target, "#") {
+			continue
+		}
+		targets = append(targets, target)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+// NewTargetMatcher returns a TargetMatcher for the given entries, each entry can
+// be an IP, a CIDR or a hostname glob, e.g.: "*.example.com".
+func NewTargetMatcher(entries []string) (TargetMatcher, error) {
+	var m TargetMatcher
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			_, ipNet, err := net.ParseCIDR(entry)
+			if err != nil {
+				return TargetMatcher{}, fmt.Errorf("invalid CIDR %q: %v", entry, err)
+			}
+			m.nets = append(m.nets, ipNet)
+			continue
+		}
+		if ip := net.ParseIP(entry); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			m.nets = append(m.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		glob := strings.ToLower(strings.TrimSuffix(entry, "."))
+		if _, err := path.Match(glob, ""); err != nil {
+			return TargetMatcher{}, fmt.Errorf("invalid hostname glob %q: %v", entry, err)
+		}
+		m.globs = append(m.globs, glob)
+	}
+	return m, nil
+}
+
+// Matches returns true if the target matches any of the entries of the list.
+// IP targets match the listed IPs and CIDRs, CIDR targets match if they are
+// contained in a listed CIDR and hostname targets, or the host of URL targets,
+// match the listed hostname globs.
+func (m TargetMatcher) Matches(target string) bool {
+	host := targetHost(strings.TrimSpace(target))
+	if ip, ipNet, err := net.ParseCIDR(host); err == nil {
+		ones, _ := ipNet.Mask.Size()
+		for _, n := range m.nets {
+			listedOnes, _ := n.Mask.Size()
+			if n.Contains(ip) && listedOnes <= ones {
+				return true
+			}
+		}
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		for _, n := range m.nets {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, g := range m.globs {
+		if ok, _ := path.Match(g, host); ok { // nolint
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package helpers
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestLoadTargetList(t *testing.T) {
+	m, err := LoadTargetList("testdata/denylist.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		target string
+		want   bool
+	}{
+		{
+			name:   "IPInsideCIDR",
+			target: "10.1.2.3",
+			want:   true,
+		},
+		{
+			name:   "ListedIP",
+			target: "192.168.1.10",
+			want:   true,
+		},
+		{
+			name:   "NotListedIP",
+			target: "192.168.1.11",
+		},
+		{
+			name:   "IPv6InsideCIDR",
+			target: "2001:db8::1",
+			want:   true,
+		},
+		{
+			name:   "CIDRInsideCIDR",
+			target: "10.1.0.0/16",
+			want:   true,
+		},
+		{
+			name:   "CIDRContainingCIDR",
+			target: "10.0.0.0/7",
+		},
+		{
+			name:   "HostnameMatchingGlob",
+			target: "db.Internal.example.com",
+			want:   true,
+		},
+		{
+			name:   "URLMatchingGlob",
+			target: "https://db.internal.example.com:8443/path",
+			want:   true,
+		},
+		{
+			name:   "ListedHostname",
+			target: "legacy.example.com",
+			want:   true,
+		},
+		{
+			name:   "NotListedHostname",
+			target: "www.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.Matches(tt.target); got != tt.want {
+				t.Errorf("Matches(%q) = %v, want %v", tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadTargetListNotExisting(t *testing.T) {
+	if _, err := LoadTargetList("testdata/notexisting.txt"); err == nil {
+		t.Errorf("want error loading a not existing file")
+	}
+}
+
+func TestReadTargetLines(t *testing.T) {
+	f, err := os.Open("testdata/targets.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() // nolint
+	got, err := ReadTargetLines(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"www.example.com", "example.org", "10.0.0.1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want targets %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,80 @@
+package helpers
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+)
+
+const (
+	// TLSReasonExpired is the reason returned by VerifyTLSChain when a
+	// certificate of the chain is expired or not yet valid.
+	TLSReasonExpired = "expired"
+	// TLSReasonUntrustedRoot is the reason returned by VerifyTLSChain when the
+	// chain is not signed by a trusted root.
+	TLSReasonUntrustedRoot = "untrusted root"
+	// TLSReasonNameMismatch is the reason returned by VerifyTLSChain when the
+	// certificate is not valid for the host.
+	TLSReasonNameMismatch = "name mismatch"
+)
+
+// tlsRootCAs defines the roots used to verify the TLS chains, when nil the
+// system roots are used.
+var tlsRootCAs *x509.CertPool
+
+// VerifyTLSChain connects to the given host and port and returns true if the
+// TLS chain presented by the server is valid for the host and validates
+// against the system roots. When the verification fails, the function returns
+// false and the reason of the failure, that is one of: TLSReasonExpired,
+// TLSReasonUntrustedRoot, TLSReasonNameMismatch or, for any other
+// verification failure, the description of the error. An error is only
+// returned when the TLS handshake can not be performed for a reason not
+// related to the verification of the chain, e.g.: the port is closed. The
+// connection is established through the SOCKS5 proxy set with SetSOCKS5Proxy,
+// if any.
+func VerifyTLSChain(ctx context.Context, host, port string) (valid bool, reason string, err error) {
+	conn, err := dial(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return false, "", err
+	}
+	defer conn.Close() // nolint
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline) // nolint
+	}
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName: host,
+		RootCAs:    tlsRootCAs,
+	})
+	err = tlsConn.Handshake()
+	if err == nil {
+		return true, "", nil
+	}
+	reason = tlsVerificationReason(err)
+	if reason == "" {
+		return false, "", err
+	}
+	return false, reason, nil
+}
+
+// tlsVerificationReason returns the reason of a TLS handshake error if it was
+// caused by the verification of the chain, or an empty string otherwise.
+func tlsVerificationReason(err error) string {
+	var invalidErr x509.CertificateInvalidError
+	if errors.As(err, &invalidErr) {
+		if invalidErr.Reason == x509.Expired {
+			return TLSReasonExpired
+		}
+		return invalidErr.Error()
+	}
+	var authorityErr x509.UnknownAuthorityError
+	if errors.As(err, &authorityErr) {
+		return TLSReasonUntrustedRoot
+	}
+	var hostnameErr x509.HostnameError
+	if errors.As(err, &hostnameErr) {
+		return TLSReasonNameMismatch
+	}
+	return ""
+}
@@ -0,0 +1,76 @@
+package helpers
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestVerifyTLSChain(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trusted := x509.NewCertPool()
+	trusted.AddCert(srv.Certificate())
+
+	tests := []struct {
+		name       string
+		host       string
+		roots      *x509.CertPool
+		wantValid  bool
+		wantReason string
+	}{
+		{
+			name:       "SelfSigned",
+			host:       u.Hostname(),
+			wantReason: TLSReasonUntrustedRoot,
+		},
+		{
+			name:      "Trusted",
+			host:      u.Hostname(),
+			roots:     trusted,
+			wantValid: true,
+		},
+		{
+			// The certificate of the test server is only valid for
+			// example.com, 127.0.0.1 and ::1.
+			name:       "NameMismatch",
+			host:       "localhost",
+			roots:      trusted,
+			wantReason: TLSReasonNameMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev := tlsRootCAs
+			tlsRootCAs = tt.roots
+			defer func() { tlsRootCAs = prev }()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			valid, reason, err := VerifyTLSChain(ctx, tt.host, u.Port())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if valid != tt.wantValid {
+				t.Errorf("want valid %v, got %v", tt.wantValid, valid)
+			}
+			if reason != tt.wantReason {
+				t.Errorf("want reason %q, got %q", tt.wantReason, reason)
+			}
+		})
+	}
+}
+
+func TestVerifyTLSChainClosedPort(t *testing.T) {
+	port := freePort(t)
+	if _, _, err := VerifyTLSChain(context.Background(), "127.0.0.1", port); err == nil {
+		t.Error("want error verifying the chain of a closed port")
+	}
+}
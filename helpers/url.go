@@ -0,0 +1,91 @@
+package helpers
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// defaultPorts contains the port used by each supported scheme when the URL
+// does not specify one.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+}
+
+// URLSchemeInfo returns the lowercased scheme, the host and the port of the
+// given URL. When the URL does not contain a port the default port for the
+// scheme is returned. Only the schemes http, https, ws and wss are supported.
+func URLSchemeInfo(rawurl string) (scheme string, host string, port string, err error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", "", "", err
+	}
+	scheme = strings.ToLower(u.Scheme)
+	defPort, ok := defaultPorts[scheme]
+	if !ok {
+		return "", "", "", fmt.Errorf("unsupported scheme %q in url %s", u.Scheme, rawurl)
+	}
+	host = u.Hostname()
+	if host == "" {
+		return "", "", "", fmt.Errorf("url without host %s", rawurl)
+	}
+	port = u.Port()
+	if port == "" {
+		port = defPort
+	}
+	return scheme, host, port, nil
+}
+
+// CanonicalizeURL returns the canonical form of the given URL, so it can be
+// probed or compared with other URLs: the scheme and the host are lowercased,
+// the default port of the scheme is removed, the dot segments of the path are
+// resolved, an empty path is replaced by "/" and the fragment is removed. It
+// returns an error if the URL doesn't have a scheme or a host, if the scheme
+// is not one of http, https, ws or wss, or if the port is not valid.
+func CanonicalizeURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme == "" {
+		return "", fmt.Errorf("url without scheme %s", raw)
+	}
+	defPort, ok := defaultPorts[strings.ToLower(u.Scheme)]
+	if !ok {
+		return "", fmt.Errorf("unsupported scheme %q in url %s", u.Scheme, raw)
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return "", fmt.Errorf("url without host %s", raw)
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	port := u.Port()
+	if port == "" && strings.HasSuffix(u.Host, ":") {
+		return "", fmt.Errorf("url with empty port %s", raw)
+	}
+	if port != "" {
+		n, err := strconv.Atoi(port)
+		if err != nil || n < 1 || n > 65535 {
+			return "", fmt.Errorf("invalid port %q in url %s", port, raw)
+		}
+		// The port is formatted again to remove the leading zeros.
+		if port = strconv.Itoa(n); port != defPort {
+			host = host + ":" + port
+		}
+	}
+	ref := &url.URL{Path: u.Path, RawPath: u.RawPath, RawQuery: u.RawQuery}
+	if ref.Path == "" {
+		ref.Path = "/"
+	}
+	c := u.ResolveReference(ref)
+	c.Scheme = strings.ToLower(u.Scheme)
+	c.Host = host
+	c.Fragment = ""
+	return c.String(), nil
+}
@@ -0,0 +1,145 @@
+package helpers
+
+import "testing"
+
+func TestURLSchemeInfo(t *testing.T) {
+	tests := []struct {
+		name       string
+		rawurl     string
+		wantScheme string
+		wantHost   string
+		wantPort   string
+		wantErr    bool
+	}{
+		{
+			name:       "HTTPSDefaultPort",
+			rawurl:     "https://x.com",
+			wantScheme: "https",
+			wantHost:   "x.com",
+			wantPort:   "443",
+		},
+		{
+			name:       "HTTPExplicitPort",
+			rawurl:     "http://x.com:8080",
+			wantScheme: "http",
+			wantHost:   "x.com",
+			wantPort:   "8080",
+		},
+		{
+			name:       "WSDefaultPort",
+			rawurl:     "WS://x.com/socket",
+			wantScheme: "ws",
+			wantHost:   "x.com",
+			wantPort:   "80",
+		},
+		{
+			name:       "WSSIPv6",
+			rawurl:     "wss://[::1]/socket",
+			wantScheme: "wss",
+			wantHost:   "::1",
+			wantPort:   "443",
+		},
+		{
+			name:    "UnsupportedScheme",
+			rawurl:  "ftp://x.com",
+			wantErr: true,
+		},
+		{
+			name:    "NoHost",
+			rawurl:  "http:///path",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme, host, port, err := URLSchemeInfo(tt.rawurl)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("URLSchemeInfo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if scheme != tt.wantScheme || host != tt.wantHost || port != tt.wantPort {
+				t.Errorf("URLSchemeInfo() = %q, %q, %q, want %q, %q, %q", scheme, host, port, tt.wantScheme, tt.wantHost, tt.wantPort)
+			}
+		})
+	}
+}
+
+func TestCanonicalizeURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "UppercaseSchemeAndHost",
+			raw:  "HTTP://WWW.Example.COM/Path",
+			want: "http://www.example.com/Path",
+		},
+		{
+			name: "DefaultPort",
+			raw:  "https://www.example.com:443/",
+			want: "https://www.example.com/",
+		},
+		{
+			name: "NonDefaultPortWithLeadingZeros",
+			raw:  "http://www.example.com:08080",
+			want: "http://www.example.com:8080/",
+		},
+		{
+			name: "DotSegments",
+			raw:  "http://www.example.com/a/./b/../c/",
+			want: "http://www.example.com/a/c/",
+		},
+		{
+			name: "DotSegmentsAboveRoot",
+			raw:  "http://www.example.com/../../a",
+			want: "http://www.example.com/a",
+		},
+		{
+			name: "QueryKeptAndFragmentRemoved",
+			raw:  " https://www.example.com/search?q=a&b=c#results ",
+			want: "https://www.example.com/search?q=a&b=c",
+		},
+		{
+			name: "IPv6DefaultPort",
+			raw:  "http://[2001:DB8::1]:80/a",
+			want: "http://[2001:db8::1]/a",
+		},
+		{
+			name:    "NoScheme",
+			raw:     "www.example.com/path",
+			wantErr: true,
+		},
+		{
+			name:    "NoHost",
+			raw:     "http:///path",
+			wantErr: true,
+		},
+		{
+			name:    "UnsupportedScheme",
+			raw:     "mailto:admin@example.com",
+			wantErr: true,
+		},
+		{
+			name:    "InvalidPort",
+			raw:     "http://www.example.com:70000/",
+			wantErr: true,
+		},
+		{
+			name:    "EmptyPort",
+			raw:     "http://www.example.com:/",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CanonicalizeURL(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CanonicalizeURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("CanonicalizeURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
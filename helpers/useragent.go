@@ -0,0 +1,102 @@
+package helpers
+
+import (
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// sdkModulePath is the path of the module of the SDK.
+const sdkModulePath = "github.com/adevinta/vulcan-check-sdk"
+
+var (
+	// SDKVersion is the version of the SDK reported in the DefaultUserAgent.
+	// It's meant to be set at build time, e.g.:
+	// -ldflags "-X github.com/adevinta/vulcan-check-sdk/helpers.SDKVersion=1.2.0".
+	// When it's not set, the version of the module of the SDK in the build
+	// info of the binary is reported, or "unknown" if there is none.
+	SDKVersion string
+	// DefaultUserAgent is the User-Agent sent by the http helpers when no
+	// other is set with SetUserAgent.
+	DefaultUserAgent = "vulcan-check-sdk/" + sdkVersion()
+)
+
+// sdkVersion returns the version of the SDK set in SDKVersion or, if empty,
+// the one in the build info of the binary.
+func sdkVersion() string {
+	if SDKVersion != "" {
+		return SDKVersion
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	modules := append([]*debug.Module{&info.Main}, info.Deps...)
+	for _, m := range modules {
+		if m.Path != sdkModulePath {
+			continue
+		}
+		if m.Replace != nil {
+			m = m.Replace
+		}
+		if m.Version != "" && m.Version != "(devel)" {
+			return m.Version
+		}
+	}
+	return "unknown"
+}
+
+// httpTimeout is the time the requests sent with the client returned by
+// httpClient, including the redirects followed, take at most.
+var httpTimeout = 10 * time.Second
+
+var (
+	userAgentMu sync.RWMutex
+	userAgent   = DefaultUserAgent
+)
+
+// SetUserAgent sets the User-Agent header sent in the requests performed by
+// the http helpers. An empty value restores the DefaultUserAgent.
+func SetUserAgent(ua string) {
+	if ua == "" {
+		ua = DefaultUserAgent
+	}
+	userAgentMu.Lock()
+	defer userAgentMu.Unlock()
+	userAgent = ua
+}
+
+// UserAgent returns the User-Agent header sent in the requests performed by
+// the http helpers.
+func UserAgent() string {
+	userAgentMu.RLock()
+	defer userAgentMu.RUnlock()
+	return userAgent
+}
+
+// userAgentTransport sets the User-Agent of the http helpers in the requests
+// that don't have one before sending them using the wrapped RoundTripper.
+type userAgentTransport struct {
+	http.RoundTripper
+}
+
+func (t userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		// A RoundTripper must not modify the request.
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", UserAgent())
+	}
+	return t.RoundTripper.RoundTrip(req)
+}
+
+// httpClient returns an http client for the http helpers that, as the one
+// returned by guardedHTTPClient, connects through the dialer of the network
+// helpers and refuses to connect to the addresses not allowed by the guard
+// policy, also when following redirects, but that follows up to 10 redirects.
+// It times out after httpTimeout.
+func httpClient() *http.Client {
+	client := guardedHTTPClient(httpTimeout)
+	client.CheckRedirect = nil
+	return client
+}
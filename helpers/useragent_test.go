@@ -0,0 +1,116 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// userAgentRecorder is an http handler that records the User-Agent of the
+// requests it receives.
+type userAgentRecorder struct {
+	mu         sync.Mutex
+	userAgents []string
+}
+
+func (u *userAgentRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.mu.Lock()
+	u.userAgents = append(u.userAgents, r.Header.Get("User-Agent"))
+	u.mu.Unlock()
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestUserAgent(t *testing.T) {
+	defer withGuardPolicy(GuardPolicy{AllowPrivateIPs: true})()
+	tests := []struct {
+		name      string
+		userAgent string
+		want      string
+	}{
+		{
+			name: "Default",
+			want: DefaultUserAgent,
+		},
+		{
+			name:      "Custom",
+			userAgent: "custom-scanner/1.0",
+			want:      "custom-scanner/1.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetUserAgent(tt.userAgent)
+			defer SetUserAgent("")
+			recorder := &userAgentRecorder{}
+			srv := httptest.NewServer(recorder)
+			defer srv.Close()
+			u, err := url.Parse(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, _, err := IsRedirectingTo(srv.URL, "example.com"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := DetectVHost(context.Background(), u.Host, "www.example.com"); err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := FollowMetaRefresh(srv.URL, 1); err != nil {
+				t.Fatal(err)
+			}
+			if len(recorder.userAgents) != 4 {
+				t.Fatalf("want 4 requests, got %d", len(recorder.userAgents))
+			}
+			for _, got := range recorder.userAgents {
+				if got != tt.want {
+					t.Errorf("want User-Agent %q, got %q", tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPClientTimeout(t *testing.T) {
+	defer withGuardPolicy(GuardPolicy{AllowPrivateIPs: true})()
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+	prev := httpTimeout
+	httpTimeout = 50 * time.Millisecond
+	defer func() { httpTimeout = prev }()
+	if _, err := walkHTTPRedirects(srv.URL); err == nil {
+		t.Errorf("want error requesting a server that doesn't respond")
+	}
+}
+
+func TestHTTPClientGuarded(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	// The redirector is reached through its allow listed IP, but the target
+	// it redirects to, listening in a private IP too, is not allowed.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, strings.Replace(target.URL, "127.0.0.1", "localhost", 1), http.StatusFound)
+	}))
+	defer srv.Close()
+	allowList, err := NewTargetMatcher([]string{"127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer withGuardPolicy(GuardPolicy{AllowList: allowList})()
+	defer withResolver(stubResolver(func(host string) ([]net.IPAddr, error) {
+		return []net.IPAddr{{IP: net.ParseIP("127.0.0.2")}}, nil
+	}))()
+	if _, err := walkHTTPRedirects(srv.URL); !errors.Is(err, ErrAddressNotAllowed) {
+		t.Errorf("want error %v following a redirect to a private IP, got %v", ErrAddressNotAllowed, err)
+	}
+}
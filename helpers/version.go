@@ -0,0 +1,97 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/adevinta/vulcan-check-sdk/helpers/ratelimit"
+)
+
+// DefaultVersionRegex matches the first version, e.g.: "7.94" or "1.2.3-rc1",
+// in the output of a command.
+var DefaultVersionRegex = regexp.MustCompile(`(\d+(?:\.\d+)+\S*)`)
+
+// CommandVersion runs the given executable with the given args, e.g.:
+// "--version", and returns the first submatch of the regex in its output,
+// both the standard output and error are considered. A nil regex means the
+// DefaultVersionRegex.
+func CommandVersion(ctx context.Context, exe string, args []string, re *regexp.Regexp) (string, error) {
+	if re == nil {
+		re = DefaultVersionRegex
+	}
+	release, err := ratelimit.AcquireProcess(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	out, err := exec.CommandContext(ctx, exe, args...).CombinedOutput() // nolint
+	if err != nil {
+		return "", fmt.Errorf("can not run %s %s, check %s is installed: %v", exe, strings.Join(args, " "), exe, err)
+	}
+	match := re.FindSubmatch(out)
+	if len(match) < 2 {
+		return "", fmt.Errorf("can not find the %s version in the output %q", exe, out)
+	}
+	return string(match[1]), nil
+}
+
+// RequireVersion returns an error if the given version of the named tool is
+// lower than the min one, e.g.: "7.80". A non numeric suffix in the components
+// of the versions, like in "7.94SVN", is ignored when comparing them.
+func RequireVersion(name, version, min string) error {
+	minParts, err := parseVersion(min)
+	if err != nil {
+		return fmt.Errorf("invalid min %s version %s: %v", name, min, err)
+	}
+	parts, err := parseVersion(version)
+	if err != nil {
+		return fmt.Errorf("can not parse the %s version %s: %v", name, version, err)
+	}
+	if compareVersions(parts, minParts) < 0 {
+		return fmt.Errorf("%s version %s is installed but at least version %s is required", name, version, min)
+	}
+	return nil
+}
+
+// parseVersion returns the numeric components of a version. The non numeric
+// suffix of each component, if any, is ignored.
+func parseVersion(v string) ([]int, error) {
+	var parts []int
+	for _, p := range strings.Split(v, ".") {
+		end := strings.IndexFunc(p, func(r rune) bool { return r < '0' || r > '9' })
+		if end == -1 {
+			end = len(p)
+		}
+		n, err := strconv.Atoi(p[:end])
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, n)
+	}
+	return parts, nil
+}
+
+// compareVersions returns -1, 0 or 1 if the version a is lower, equal or
+// greater than the version b. The missing components are considered 0.
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,113 @@
+package helpers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// maxVHostBodyBytes is the maximum number of bytes of the bodies of the
+	// responses compared by DetectVHost.
+	maxVHostBodyBytes = 1 << 20
+	vhostTimeout      = 10 * time.Second
+)
+
+// guardedHTTPClient returns an http client that connects to the targets
+// using the dialer of the network helpers, so the connections go through the
+// SOCKS5 proxy set with SetSOCKS5Proxy, if any, that refuses to connect to the
+// addresses not allowed by the policy set with SetGuardPolicy, that times out
+// after the given duration, that does not follow redirects and that sends the
+// User-Agent set with SetUserAgent. The connections honor the Pin stored in
+// the context of the requests, if any.
+func guardedHTTPClient(timeout time.Duration) *http.Client {
+	return guardedHTTPClientWithPin(timeout, nil)
+}
+
+// guardedHTTPClientWithPin returns an http client like the one returned by
+// guardedHTTPClient that connects to the IP of the given pin, if not nil,
+// instead of to the IP of the pin stored in the context of the requests.
+func guardedHTTPClientWithPin(timeout time.Duration, pin *Pin) *http.Client {
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		p := pin
+		if p == nil {
+			p = contextPin(ctx)
+		}
+		return guardedDial(ctx, network, address, p)
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: userAgentTransport{&http.Transport{
+			DialContext:       dial,
+			DisableKeepAlives: true,
+		}},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// DetectVHost returns true if the web server listening in the given IP, which
+// can optionally contain a port, e.g.: "10.0.0.1:8080", serves different
+// content when requested using the given host in the Host header than when
+// requested using the IP. The requests are sent using http and the responses
+// are compared by status code, location header and body. Take into account
+// that servers returning dynamic content are detected as serving virtual
+// hosts.
+func DetectVHost(ctx context.Context, ip, host string) (servesVHost bool, err error) {
+	addr := ip
+	if parsed := net.ParseIP(ip); parsed != nil && strings.Contains(ip, ":") {
+		addr = "[" + ip + "]"
+	}
+	u := "http://" + addr + "/"
+	client := guardedHTTPClient(vhostTimeout)
+	withHost, err := vhostResponse(ctx, client, u, host)
+	if err != nil {
+		return false, err
+	}
+	withoutHost, err := vhostResponse(ctx, client, u, "")
+	if err != nil {
+		return false, err
+	}
+	return !withHost.equal(withoutHost), nil
+}
+
+type vhostResp struct {
+	status   int
+	location string
+	body     []byte
+}
+
+func (r vhostResp) equal(other vhostResp) bool {
+	return r.status == other.status && r.location == other.location && bytes.Equal(r.body, other.body)
+}
+
+func vhostResponse(ctx context.Context, client *http.Client, u, host string) (vhostResp, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return vhostResp{}, err
+	}
+	req = req.WithContext(ctx)
+	if host != "" {
+		req.Host = host
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return vhostResp{}, err
+	}
+	defer resp.Body.Close() // nolint
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxVHostBodyBytes))
+	if err != nil {
+		return vhostResp{}, err
+	}
+	return vhostResp{
+		status:   resp.StatusCode,
+		location: resp.Header.Get("Location"),
+		body:     body,
+	}, nil
+}
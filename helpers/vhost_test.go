@@ -0,0 +1,78 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestDetectVHost(t *testing.T) {
+	defer withGuardPolicy(GuardPolicy{AllowPrivateIPs: true})()
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    bool
+	}{
+		{
+			name: "DifferentBodyPerHost",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.Host == "www.example.com" {
+					fmt.Fprint(w, "example") // nolint
+					return
+				}
+				fmt.Fprint(w, "default") // nolint
+			},
+			want: true,
+		},
+		{
+			name: "RedirectOnlyForHost",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.Host == "www.example.com" {
+					http.Redirect(w, r, "https://www.example.com/", http.StatusFound)
+					return
+				}
+				fmt.Fprint(w, "default") // nolint
+			},
+			want: true,
+		},
+		{
+			name: "SameBody",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "default") // nolint
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+			u, err := url.Parse(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := DetectVHost(context.Background(), u.Host, "www.example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("DetectVHost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectVHostUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+	if _, err := DetectVHost(context.Background(), u.Host, "www.example.com"); err == nil {
+		t.Error("want error detecting virtual hosts in a closed server")
+	}
+}
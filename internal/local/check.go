@@ -2,12 +2,17 @@ package local
 
 import (
 	"context"
+	"errors"
+	"io"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 
 	"github.com/adevinta/vulcan-check-sdk/agent"
 	"github.com/adevinta/vulcan-check-sdk/config"
+	"github.com/adevinta/vulcan-check-sdk/helpers"
+	vreport "github.com/adevinta/vulcan-check-sdk/helpers/report"
 	astate "github.com/adevinta/vulcan-check-sdk/state"
 	report "github.com/adevinta/vulcan-report"
 	log "github.com/sirupsen/logrus"
@@ -23,37 +28,163 @@ type Check struct {
 	formatter  resultFormatter
 	ctx        context.Context
 	cancel     context.CancelFunc
-	done       chan error
 	exitSignal chan os.Signal
+	targets    []string
+	// results contains the results of the last run, in the same order than
+	// the targets.
+	results []*report.ResultData
+	// mu serializes the calls to the formatter.
+	mu sync.Mutex
 }
 
 // RunAndServe implements the behavior needed by the sdk for a check runner to
-// execute a check.
+// execute a check. The process exits with the code returned by exitCode.
 func (c *Check) RunAndServe() {
-	runtimeState := astate.State{
-		ResultData:       &c.checkState.state.Report.ResultData,
-		ProgressReporter: astate.ProgressReporterHandler(c.formatter.progress),
+	os.Exit(c.exitCode(c.run()))
+}
+
+// exitCode returns the exit code of a check run from the command line given
+// the error returned by run: 1 if the checker failed against any of the
+// targets, the code returned by gateExitCode if the checker succeeded.
+func (c *Check) exitCode(err error) int {
+	if err != nil {
+		return 1
 	}
+	return c.gateExitCode()
+}
+
+// run executes the checker against each of the targets of the check, running
+// at most the number of targets defined in the TargetConcurrency config
+// simultaneously, and writes the result of each execution using the formatter
+// in the same order than the targets. When the check is cancelled the targets
+// not yet started are not checked and a cancellation error is written as their
+// result. It returns the last error returned by the checker, if any.
+func (c *Check) run() error {
+	stop := make(chan struct{})
+	defer close(stop)
+	go c.cancelOnExitSignal(stop)
+
+	concurrency := c.config.TargetConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([]*targetResult, len(c.targets))
+	for i := range results {
+		results[i] = &targetResult{done: make(chan struct{})}
+	}
+	pending := make(chan int)
 	go func() {
-		c.done <- c.checker.Run(c.ctx, c.config.Check.Target, c.config.Check.Opts, runtimeState)
+		for i := range c.targets {
+			pending <- i
+		}
+		close(pending)
 	}()
-	var err error
-LOOP:
-	for {
-		select {
-		case <-c.exitSignal:
-			c.cancel()
-			c.exitSignal = nil
-		case err = <-c.done:
-			break LOOP
+	for w := 0; w < concurrency && w < len(c.targets); w++ {
+		go func() {
+			for i := range pending {
+				r := results[i]
+				if err := c.ctx.Err(); err != nil {
+					r.err = err
+				} else {
+					r.result, r.err = c.runTarget(c.targets[i])
+				}
+				close(r.done)
+			}
+		}()
+	}
+
+	var lastErr error
+	c.results = make([]*report.ResultData, len(c.targets))
+	for i, target := range c.targets {
+		r := results[i]
+		<-r.done
+		c.results[i] = r.result
+		c.mu.Lock()
+		if len(c.targets) > 1 {
+			c.formatter.target(target)
+		}
+		c.formatter.result(r.err, r.result)
+		c.mu.Unlock()
+		if r.err != nil {
+			lastErr = r.err
 		}
 	}
-	c.checker.CleanUp(context.Background(), c.config.Check.Target, c.config.Check.Opts)
-	c.formatter.result(err, runtimeState.ResultData)
+	return lastErr
+}
+
+// gateExitCode returns the exit code corresponding to the vulnerabilities
+// found in the last run given the FailOnSeverity config: the gating exit code
+// if any target has a vulnerability with a severity equal or higher than the
+// configured one and 0 otherwise. It returns 1 if the configured severity is
+// invalid, which can only happen when the config is built programmatically, as
+// the configs built by the config package are rejected when it is.
+func (c *Check) gateExitCode() int {
+	if c.config.FailOnSeverity == "" {
+		return 0
+	}
+	threshold, err := vreport.ParseSeverity(c.config.FailOnSeverity)
 	if err != nil {
-		os.Exit(0)
+		c.Logger.WithError(err).Error("Invalid fail on severity config")
+		return 1
+	}
+	for _, r := range c.results {
+		if code := vreport.ExitCode(r, threshold); code != 0 {
+			return code
+		}
+	}
+	return 0
+}
+
+// targetResult contains the result of running the checker against a target.
+// The done channel is closed when the result is available.
+type targetResult struct {
+	result *report.ResultData
+	err    error
+	done   chan struct{}
+}
+
+// cancelOnExitSignal cancels the context of the check when a SIGINT or SIGTERM
+// signal is received before the stop channel is closed.
+func (c *Check) cancelOnExitSignal(stop <-chan struct{}) {
+	select {
+	case <-c.exitSignal:
+		c.cancel()
+	case <-stop:
 	}
-	os.Exit(1)
+}
+
+func (c *Check) runTarget(target string) (*report.ResultData, error) {
+	checkConfig := c.config.Check
+	checkConfig.Target = target
+	checkState := &State{state: agent.State{Report: agent.NewReportFromConfig(checkConfig)}}
+	runtimeState := astate.State{
+		ResultData:       &checkState.state.Report.ResultData,
+		ProgressReporter: astate.ProgressReporterHandler(c.progress),
+	}
+	ctx := c.ctx
+	found := func() bool { return false }
+	if c.config.CancelOnFirstFinding {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(c.ctx)
+		defer cancel()
+		runtimeState.FindingSink, found = astate.CancelOnFinding(nil, cancel)
+	}
+	err := c.checker.Run(ctx, target, c.config.Check.Opts, runtimeState)
+	c.checker.CleanUp(context.Background(), target, c.config.Check.Opts)
+	// The cancellation of a checker after its first finding is not an error,
+	// unless the check has also been cancelled. Any other error is kept.
+	if found() && errors.Is(err, context.Canceled) && c.ctx.Err() == nil {
+		err = nil
+	}
+	return runtimeState.ResultData, err
+}
+
+// progress writes the progress reported by the checker using the formatter,
+// which is not safe to be used by multiple goroutines.
+func (c *Check) progress(p float32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.formatter.progress(p)
 }
 
 // Shutdown is needed to fullfil the check interface but we don't need to do
@@ -63,7 +194,9 @@ func (c *Check) Shutdown() error {
 }
 
 // NewCheck creates  new check to be run from the command line without having an agent.
-func NewCheck(name string, checker Checker, logger *log.Entry, conf *config.Config, json bool) *Check {
+// The jsonLines param, that applies only when json is true, makes the check
+// write each vulnerability as a json document in its own line.
+func NewCheck(name string, checker Checker, logger *log.Entry, conf *config.Config, json, jsonLines bool) *Check {
 	var formatter resultFormatter = &textFmt{
 		Stdout: os.Stdout,
 		Stderr: os.Stderr,
@@ -72,6 +205,7 @@ func NewCheck(name string, checker Checker, logger *log.Entry, conf *config.Conf
 		formatter = &jsonFmt{
 			Stderr: os.Stderr,
 			Stdout: os.Stdout,
+			Lines:  jsonLines,
 		}
 	}
 	c := &Check{
@@ -79,18 +213,52 @@ func NewCheck(name string, checker Checker, logger *log.Entry, conf *config.Conf
 		Logger:     logger,
 		config:     conf,
 		formatter:  formatter,
-		done:       make(chan error, 1),
 		exitSignal: make(chan os.Signal, 1),
 	}
 	signal.Notify(c.exitSignal, syscall.SIGINT, syscall.SIGTERM)
 	c.ctx, c.cancel = context.WithCancel(context.Background())
 	c.checker = checker
+	c.targets = []string{conf.Check.Target}
 	r := agent.NewReportFromConfig(conf.Check)
 	agentState := agent.State{Report: r}
 	c.checkState = &State{state: agentState}
 	return c
 }
 
+// UseGreenboneFormat makes the check write the results as a Greenbone
+// (OpenVAS) report, with one result per vulnerability, instead of using the
+// format selected when creating it.
+func (c *Check) UseGreenboneFormat() {
+	f := &greenboneFmt{
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+	// The formatter is only notified of the target being checked when there
+	// are more than one.
+	if len(c.targets) > 0 {
+		f.Host = c.targets[0]
+	}
+	c.formatter = f
+}
+
+// NewMultiTargetCheck creates a new check to be run from the command line,
+// without having an agent, that executes the checker against each of the given
+// targets, one after the other or, if the TargetConcurrency config is greater
+// than one, simultaneously. The text formatter writes the name of each
+// target before its result and the json formatter writes one json document, or
+// set of lines, per target in the same order than the targets.
+func NewMultiTargetCheck(name string, checker Checker, logger *log.Entry, conf *config.Config, json, jsonLines bool, targets []string) *Check {
+	c := NewCheck(name, checker, logger, conf, json, jsonLines)
+	c.targets = targets
+	return c
+}
+
+// ReadTargets reads a list of targets, one per line, from the given reader.
+// Empty lines and lines starting with "#" are ignored.
+func ReadTargets(r io.Reader) ([]string, error) {
+	return helpers.ReadTargetLines(r)
+}
+
 // State holds the state for a local check.
 type State struct {
 	state agent.State
@@ -104,5 +272,6 @@ type Checker interface {
 
 type resultFormatter interface {
 	progress(float32)
+	target(string)
 	result(error, *report.ResultData)
 }
@@ -0,0 +1,246 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adevinta/vulcan-check-sdk/config"
+	vreport "github.com/adevinta/vulcan-check-sdk/helpers/report"
+	"github.com/adevinta/vulcan-check-sdk/internal/logging"
+	astate "github.com/adevinta/vulcan-check-sdk/state"
+	report "github.com/adevinta/vulcan-report"
+)
+
+type recordingChecker struct {
+	targets []string
+}
+
+func (r *recordingChecker) Run(ctx context.Context, target string, opts string, s astate.State) error {
+	r.targets = append(r.targets, target)
+	s.AddVulnerabilities(report.Vulnerability{Summary: "Vulnerability in " + target})
+	return nil
+}
+
+func (r *recordingChecker) CleanUp(ctx context.Context, target string, opts string) {}
+
+func TestMultiTargetCheck(t *testing.T) {
+	// Pipe the targets as they would be read from the standard input.
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		pw.WriteString("# Targets\nwww.example.com\n\n  example.org  \n") // nolint
+		pw.Close()                                                        // nolint
+	}()
+	targets, err := ReadTargets(pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTargets := []string{"www.example.com", "example.org"}
+	if !reflect.DeepEqual(targets, wantTargets) {
+		t.Fatalf("want targets %v, got %v", wantTargets, targets)
+	}
+
+	stdout, err := ioutil.TempFile("", "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(stdout.Name()) // nolint
+	checker := &recordingChecker{}
+	conf := &config.Config{Log: config.LogConfig{LogLevel: "error"}}
+	c := NewMultiTargetCheck("multi", checker, logging.BuildRootLogWithConfig("local", conf), conf, false, false, targets)
+	c.formatter = &textFmt{Stdout: stdout, Stderr: stdout}
+	if err := c.run(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(checker.targets, wantTargets) {
+		t.Errorf("want targets processed %v, got %v", wantTargets, checker.targets)
+	}
+	out, err := ioutil.ReadFile(stdout.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, target := range wantTargets {
+		for _, want := range []string{"Target " + target, "Vulnerability in " + target} {
+			if !strings.Contains(string(out), want) {
+				t.Errorf("want %q in the output %q", want, out)
+			}
+		}
+	}
+	// The result of each target must only contain its own vulnerabilities.
+	if strings.Count(string(out), "Vulnerability in") != 2 {
+		t.Errorf("want one vulnerability per target in the output %q", out)
+	}
+}
+
+// concurrencyChecker records the maximum number of targets checked
+// simultaneously.
+type concurrencyChecker struct {
+	mu      sync.Mutex
+	running int
+	max     int
+	targets []string
+}
+
+func (c *concurrencyChecker) Run(ctx context.Context, target string, opts string, s astate.State) error {
+	c.mu.Lock()
+	c.running++
+	if c.running > c.max {
+		c.max = c.running
+	}
+	c.targets = append(c.targets, target)
+	c.mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	s.SetProgress(0.5)
+	s.AddVulnerabilities(report.Vulnerability{Summary: "Vulnerability in " + target})
+	c.mu.Lock()
+	c.running--
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *concurrencyChecker) CleanUp(ctx context.Context, target string, opts string) {}
+
+func TestMultiTargetCheckConcurrency(t *testing.T) {
+	targets := []string{"a.example.com", "b.example.com", "c.example.com", "d.example.com", "e.example.com"}
+	stdout, err := ioutil.TempFile("", "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(stdout.Name()) // nolint
+	checker := &concurrencyChecker{}
+	conf := &config.Config{Log: config.LogConfig{LogLevel: "error"}, TargetConcurrency: 2}
+	c := NewMultiTargetCheck("multi", checker, logging.BuildRootLogWithConfig("local", conf), conf, false, false, targets)
+	c.formatter = &textFmt{Stdout: stdout, Stderr: stdout}
+	if err := c.run(); err != nil {
+		t.Fatal(err)
+	}
+	if checker.max != 2 {
+		t.Errorf("want 2 targets checked simultaneously at most, got %d", checker.max)
+	}
+	if len(checker.targets) != len(targets) {
+		t.Errorf("want %d targets checked, got %v", len(targets), checker.targets)
+	}
+	out, err := ioutil.ReadFile(stdout.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The results must be written in the same order than the targets.
+	last := -1
+	for _, target := range targets {
+		i := strings.Index(string(out), "Target "+target)
+		if i < last {
+			t.Errorf("result of %s written out of order in the output %q", target, out)
+		}
+		last = i
+	}
+}
+
+func TestMultiTargetCheckCancelled(t *testing.T) {
+	targets := []string{"a.example.com", "b.example.com", "c.example.com"}
+	stdout, err := ioutil.TempFile("", "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(stdout.Name()) // nolint
+	checker := &concurrencyChecker{}
+	conf := &config.Config{Log: config.LogConfig{LogLevel: "error"}, TargetConcurrency: 2}
+	c := NewMultiTargetCheck("multi", checker, logging.BuildRootLogWithConfig("local", conf), conf, false, false, targets)
+	c.formatter = &textFmt{Stdout: stdout, Stderr: stdout}
+	c.cancel()
+	if err := c.run(); err != context.Canceled {
+		t.Errorf("want error %v, got %v", context.Canceled, err)
+	}
+	if len(checker.targets) != 0 {
+		t.Errorf("want no targets checked after cancelling the check, got %v", checker.targets)
+	}
+}
+
+// scoreChecker adds a vulnerability with the given score to the report and
+// returns the given error.
+type scoreChecker struct {
+	score float32
+	err   error
+}
+
+func (s scoreChecker) Run(ctx context.Context, target string, opts string, st astate.State) error {
+	st.AddVulnerabilities(report.Vulnerability{Summary: "Vulnerability in " + target, Score: s.score})
+	return s.err
+}
+
+func (s scoreChecker) CleanUp(ctx context.Context, target string, opts string) {}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name           string
+		failOnSeverity string
+		score          float32
+		err            error
+		want           int
+	}{
+		{
+			name:  "NotConfigured",
+			score: 9,
+			want:  0,
+		},
+		{
+			name:  "CheckerFailed",
+			score: 9,
+			err:   errors.New("failed"),
+			want:  1,
+		},
+		{
+			name:           "CheckerFailedWithHighFinding",
+			failOnSeverity: "high",
+			score:          8,
+			err:            errors.New("failed"),
+			want:           1,
+		},
+		{
+			name:           "HighFinding",
+			failOnSeverity: "high",
+			score:          8,
+			want:           vreport.GateExitCode,
+		},
+		{
+			name:           "LowFinding",
+			failOnSeverity: "high",
+			score:          2,
+			want:           0,
+		},
+		{
+			name:           "InvalidSeverity",
+			failOnSeverity: "urgent",
+			score:          8,
+			want:           1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, err := ioutil.TempFile("", "stdout")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(stdout.Name()) // nolint
+			conf := &config.Config{
+				Log:            config.LogConfig{LogLevel: "error"},
+				FailOnSeverity: tt.failOnSeverity,
+			}
+			checker := scoreChecker{score: tt.score, err: tt.err}
+			targets := []string{"www.example.com", "example.org"}
+			c := NewMultiTargetCheck("gate", checker, logging.BuildRootLogWithConfig("local", conf), conf, false, false, targets)
+			c.formatter = &textFmt{Stdout: stdout, Stderr: stdout}
+			if got := c.exitCode(c.run()); got != tt.want {
+				t.Errorf("want exit code %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"strings"
 	"text/tabwriter"
 
+	vreport "github.com/adevinta/vulcan-check-sdk/helpers/report"
+	astate "github.com/adevinta/vulcan-check-sdk/state"
 	report "github.com/adevinta/vulcan-report"
 )
 
@@ -24,6 +26,12 @@ var (
 type jsonFmt struct {
 	Stdout *os.File
 	Stderr *os.File
+	// Lines makes the formatter write each vulnerability as a json document
+	// in its own line instead of writing the whole result as one document.
+	// The rest of the fields of the result are written in a last line, as a
+	// document with the result, without the vulnerabilities, in its "result"
+	// field.
+	Lines bool
 }
 
 func (j *jsonFmt) progress(p float32) {
@@ -32,6 +40,11 @@ func (j *jsonFmt) progress(p float32) {
 	// finishes.
 }
 
+func (j *jsonFmt) target(target string) {
+	// The results of each target are written in the same order than the
+	// targets so nothing is written before them.
+}
+
 func (j *jsonFmt) result(err error, r *report.ResultData) {
 	if err != nil {
 		mustWriteError(err, j.Stderr)
@@ -40,15 +53,33 @@ func (j *jsonFmt) result(err error, r *report.ResultData) {
 	if r == nil {
 		return
 	}
-	data, err := json.MarshalIndent(r, "", " ")
-	// This is formatter is only used to run checks in the command line and
-	// write the result as a json so if we can not marshal the result we panic.
-	if err != nil {
-		panic(err)
+	// The result is encoded directly to the std out to avoid having the
+	// whole serialized result in memory.
+	enc := json.NewEncoder(j.Stdout)
+	if j.Lines {
+		for _, v := range r.Vulnerabilities {
+			mustEncode(enc, v)
+		}
+		rest := *r
+		rest.Vulnerabilities = nil
+		mustEncode(enc, jsonLinesResult{Result: rest})
+		return
 	}
-	_, err = j.Stdout.Write(data)
-	// Same here when trying to write to the std out.
-	if err != nil {
+	enc.SetIndent("", " ")
+	mustEncode(enc, r)
+}
+
+// jsonLinesResult is the last line written by the json formatter in lines
+// mode.
+type jsonLinesResult struct {
+	Result report.ResultData `json:"result"`
+}
+
+func mustEncode(enc *json.Encoder, v interface{}) {
+	// This is formatter is only used to run checks in the command line and
+	// write the result as a json so if we can not encode the result, or write
+	// it to the std out, we panic.
+	if err := enc.Encode(v); err != nil {
 		panic(err)
 	}
 }
@@ -63,15 +94,39 @@ func (t *textFmt) progress(p float32) {
 	mustWrite(progress, t.Stderr)
 }
 
+func (t *textFmt) target(target string) {
+	mustWrite(fmt.Sprintf("\nTarget %s\n", target), t.Stdout)
+}
+
 func (t *textFmt) result(err error, r *report.ResultData) {
 	if err != nil {
 		mustWriteError(err, t.Stderr)
 		return
 	}
-	if r == nil || len(r.Vulnerabilities) < 1 {
+	if r == nil {
+		mustWrite("\nNo vulnerabilities found\n", t.Stderr)
+		return
+	}
+	if len(r.Vulnerabilities) < 1 {
 		mustWrite("\nNo vulnerabilities found\n", t.Stderr)
+	} else {
+		t.vulnerabilities(r)
+	}
+	t.warnings(r)
+}
+
+func (t *textFmt) warnings(r *report.ResultData) {
+	warnings := astate.Warnings(r)
+	if len(warnings) < 1 {
 		return
 	}
+	mustWrite("\nWarnings\n", t.Stdout)
+	for _, w := range warnings {
+		mustWrite(fmt.Sprintf("- %s\n", w), t.Stdout)
+	}
+}
+
+func (t *textFmt) vulnerabilities(r *report.ResultData) {
 	sort.SliceStable(r.Vulnerabilities, func(i, j int) bool {
 		return r.Vulnerabilities[i].Score > r.Vulnerabilities[j].Score
 	})
@@ -85,12 +140,12 @@ func (t *textFmt) result(err error, r *report.ResultData) {
 		for _, recommendation := range vuln.Recommendations {
 			recommendations += fmt.Sprintf("%s\n", recommendation)
 		}
-		severity := severityNames[vuln.Severity()]
+		severity := severityNames[vreport.Severity(vuln)]
 		row := []string{vuln.Summary, severity, recommendations}
 		data = append(data, row)
 	}
 	w := tabwriter.NewWriter(t.Stdout, 0, 0, 1, ' ', 0)
-	_, err = fmt.Fprint(w, "\nName \tSeverity \tRecommendations \t\n")
+	_, err := fmt.Fprint(w, "\nName \tSeverity \tRecommendations \t\n")
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,178 @@
+package local
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	astate "github.com/adevinta/vulcan-check-sdk/state"
+	report "github.com/adevinta/vulcan-report"
+)
+
+func TestTextFmtWarnings(t *testing.T) {
+	stdout, err := ioutil.TempFile("", "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(stdout.Name()) // nolint
+	stderr, err := ioutil.TempFile("", "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(stderr.Name()) // nolint
+
+	r := &report.ResultData{Notes: "Scanned 2 ports."}
+	s := astate.State{ResultData: r}
+	s.AddVulnerabilities(report.Vulnerability{Summary: "Exposed SSH", Score: 5})
+	s.AddWarning("port 22 timed out")
+	s.AddWarning("resource /admin skipped")
+
+	wantWarnings := []string{"port 22 timed out", "resource /admin skipped"}
+	gotWarnings := astate.Warnings(r)
+	if strings.Join(gotWarnings, "|") != strings.Join(wantWarnings, "|") {
+		t.Fatalf("want warnings %v, got %v", wantWarnings, gotWarnings)
+	}
+	if len(r.Vulnerabilities) != 1 {
+		t.Fatalf("want 1 vulnerability, got %d", len(r.Vulnerabilities))
+	}
+
+	f := &textFmt{Stdout: stdout, Stderr: stderr}
+	f.result(nil, r)
+	out, err := ioutil.ReadFile(stdout.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.SplitN(string(out), "\nWarnings\n", 2)
+	if len(parts) != 2 {
+		t.Fatalf("no warnings section in output %q", out)
+	}
+	if !strings.Contains(parts[0], "Exposed SSH") {
+		t.Errorf("want vulnerability before the warnings section, got %q", parts[0])
+	}
+	want := "- port 22 timed out\n- resource /admin skipped\n"
+	if parts[1] != want {
+		t.Errorf("want warnings section %q, got %q", want, parts[1])
+	}
+}
+
+func TestJSONFmtResult(t *testing.T) {
+	want := &report.ResultData{
+		Vulnerabilities: []report.Vulnerability{
+			{Summary: "Exposed SSH", Score: 5, References: []string{"https://example.com/ssh"}},
+			{Summary: "Outdated TLS", Score: 3.9},
+		},
+		Data:  []byte(`{"key":"value"}`),
+		Notes: "notes",
+	}
+	tests := []struct {
+		name  string
+		lines bool
+	}{
+		{name: "Document"},
+		{name: "Lines", lines: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, err := ioutil.TempFile("", "stdout")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(stdout.Name()) // nolint
+			f := &jsonFmt{Stdout: stdout, Stderr: os.Stderr, Lines: tt.lines}
+			f.result(nil, want)
+			if _, err := stdout.Seek(0, 0); err != nil {
+				t.Fatal(err)
+			}
+			got := &report.ResultData{}
+			if !tt.lines {
+				if err := json.NewDecoder(stdout).Decode(got); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(want, got) {
+					t.Errorf("want result %+v, got %+v", want, got)
+				}
+				return
+			}
+			var lines [][]byte
+			s := bufio.NewScanner(stdout)
+			for s.Scan() {
+				lines = append(lines, append([]byte(nil), s.Bytes()...))
+			}
+			if len(lines) == 0 {
+				t.Fatal("no lines written")
+			}
+			var last jsonLinesResult
+			if err := json.Unmarshal(lines[len(lines)-1], &last); err != nil {
+				t.Fatalf("invalid last line %q: %v", lines[len(lines)-1], err)
+			}
+			*got = last.Result
+			for _, l := range lines[:len(lines)-1] {
+				var v report.Vulnerability
+				if err := json.Unmarshal(l, &v); err != nil {
+					t.Fatalf("invalid line %q: %v", l, err)
+				}
+				got.Vulnerabilities = append(got.Vulnerabilities, v)
+			}
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("want result %+v, got %+v", want, got)
+			}
+		})
+	}
+}
+
+func TestGreenboneFmtResult(t *testing.T) {
+	r := &report.ResultData{
+		Vulnerabilities: []report.Vulnerability{
+			{ID: "critical-id", Summary: "Remote code execution", Score: 9.8, Labels: []string{"CVE-2021-44228"}, CWEID: 502},
+			{Summary: "Exposed SSH", Score: 7, References: []string{"https://example.com/ssh"}},
+			{Summary: "Outdated TLS", Score: 5},
+			{Summary: "Missing header", Score: 2, Recommendations: []string{"Add the header"}},
+			{Summary: "Open port", Score: 0},
+		},
+	}
+	wantThreats := map[string]string{
+		"Remote code execution": "High",
+		"Exposed SSH":           "High",
+		"Outdated TLS":          "Medium",
+		"Missing header":        "Low",
+		"Open port":             "Log",
+	}
+	stdout, err := ioutil.TempFile("", "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(stdout.Name()) // nolint
+	f := &greenboneFmt{Stdout: stdout, Stderr: os.Stderr, Host: "www.example.com"}
+	f.result(nil, r)
+	if _, err := stdout.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	got := greenboneReport{}
+	if err := xml.NewDecoder(stdout).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Results) != len(r.Vulnerabilities) {
+		t.Fatalf("want %d results, got %d", len(r.Vulnerabilities), len(got.Results))
+	}
+	for _, res := range got.Results {
+		if want := wantThreats[res.Name]; res.Threat != want {
+			t.Errorf("want threat %q for %q, got %q", want, res.Name, res.Threat)
+		}
+		if res.Host != "www.example.com" {
+			t.Errorf("want host www.example.com for %q, got %q", res.Name, res.Host)
+		}
+	}
+	critical := got.Results[0]
+	if critical.Severity != "9.8" || critical.NVT.OID != "critical-id" {
+		t.Errorf("want severity 9.8 and oid critical-id, got %q and %q", critical.Severity, critical.NVT.OID)
+	}
+	wantRefs := []greenboneRef{{Type: "cve", ID: "CVE-2021-44228"}, {Type: "cwe", ID: "CWE-502"}}
+	if !reflect.DeepEqual(critical.NVT.Refs, wantRefs) {
+		t.Errorf("want refs %+v, got %+v", wantRefs, critical.NVT.Refs)
+	}
+}
@@ -0,0 +1,134 @@
+package local
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+
+	vreport "github.com/adevinta/vulcan-check-sdk/helpers/report"
+	report "github.com/adevinta/vulcan-report"
+)
+
+const (
+	// greenbonePort is the port of the results, as the vulnerabilities don't
+	// contain the port affected by them.
+	greenbonePort = "general/tcp"
+)
+
+// greenboneThreats maps the severities of the vulnerabilities to the threat
+// levels of Greenbone, that doesn't have a critical threat level and uses the
+// "Log" threat level for the results without severity.
+var greenboneThreats = map[report.SeverityRank]string{
+	report.SeverityNone:     "Log",
+	report.SeverityLow:      "Low",
+	report.SeverityMedium:   "Medium",
+	report.SeverityHigh:     "High",
+	report.SeverityCritical: "High",
+}
+
+type greenboneReport struct {
+	XMLName xml.Name          `xml:"report"`
+	Results []greenboneResult `xml:"results>result"`
+}
+
+type greenboneResult struct {
+	Name        string       `xml:"name"`
+	Host        string       `xml:"host"`
+	Port        string       `xml:"port"`
+	NVT         greenboneNVT `xml:"nvt"`
+	Threat      string       `xml:"threat"`
+	Severity    string       `xml:"severity"`
+	Description string       `xml:"description"`
+}
+
+type greenboneNVT struct {
+	OID      string         `xml:"oid,attr"`
+	Name     string         `xml:"name"`
+	CVSSBase string         `xml:"cvss_base"`
+	Tags     string         `xml:"tags"`
+	Solution string         `xml:"solution,omitempty"`
+	Refs     []greenboneRef `xml:"refs>ref,omitempty"`
+}
+
+type greenboneRef struct {
+	Type string `xml:"type,attr"`
+	ID   string `xml:"id,attr"`
+}
+
+// greenboneFmt writes the result of a check as a Greenbone (OpenVAS) report
+// with one result per vulnerability.
+type greenboneFmt struct {
+	Stdout *os.File
+	Stderr *os.File
+	// Host is the target the results are reported for.
+	Host string
+}
+
+func (g *greenboneFmt) progress(p float32) {
+	// As the json formatter, the greenbone formatter only writes the report
+	// when the check finishes.
+}
+
+func (g *greenboneFmt) target(target string) {
+	g.Host = target
+}
+
+func (g *greenboneFmt) result(err error, r *report.ResultData) {
+	if err != nil {
+		mustWriteError(err, g.Stderr)
+		return
+	}
+	if r == nil {
+		return
+	}
+	gr := greenboneReport{}
+	for _, v := range r.Vulnerabilities {
+		gr.Results = append(gr.Results, g.vulnResult(v))
+	}
+	enc := xml.NewEncoder(g.Stdout)
+	enc.Indent("", " ")
+	if err := enc.Encode(gr); err != nil {
+		panic(err)
+	}
+	mustWrite("\n", g.Stdout)
+}
+
+func (g *greenboneFmt) vulnResult(v report.Vulnerability) greenboneResult {
+	severity := fmt.Sprintf("%.1f", v.Score)
+	var tags []string
+	for _, tag := range [][2]string{
+		{"summary", v.Summary},
+		{"insight", v.Details},
+		{"impact", v.ImpactDetails},
+	} {
+		if tag[1] != "" {
+			tags = append(tags, tag[0]+"="+tag[1])
+		}
+	}
+	nvt := greenboneNVT{
+		OID:      v.ID,
+		Name:     v.Summary,
+		CVSSBase: severity,
+		Tags:     strings.Join(tags, "|"),
+		Solution: strings.Join(v.Recommendations, "\n"),
+	}
+	for _, cve := range vreport.CVEs(v) {
+		nvt.Refs = append(nvt.Refs, greenboneRef{Type: "cve", ID: cve})
+	}
+	if v.CWEID != 0 {
+		nvt.Refs = append(nvt.Refs, greenboneRef{Type: "cwe", ID: fmt.Sprintf("CWE-%d", v.CWEID)})
+	}
+	for _, ref := range v.References {
+		nvt.Refs = append(nvt.Refs, greenboneRef{Type: "url", ID: ref})
+	}
+	return greenboneResult{
+		Name:        v.Summary,
+		Host:        g.Host,
+		Port:        greenbonePort,
+		NVT:         nvt,
+		Threat:      greenboneThreats[vreport.Severity(v)],
+		Severity:    severity,
+		Description: v.Description,
+	}
+}
@@ -1,10 +1,17 @@
 package logging
 
 import (
+	"sync"
+
 	"github.com/adevinta/vulcan-check-sdk/config"
+	"github.com/adevinta/vulcan-check-sdk/helpers/redact"
 	log "github.com/sirupsen/logrus"
 )
 
+// redactHookOnce ensures the hook that masks the secrets in the logs is only
+// added once to the standard logger.
+var redactHookOnce sync.Once
+
 func getLogLevel(logLevel string) log.Level {
 	if logLevel == "" {
 		logLevel = "info"
@@ -17,13 +24,15 @@ func getLogLevel(logLevel string) log.Level {
 }
 
 // BuildLoggerWithConfigAndFields Self explanatory
+// The level and the formatter of the standard logger are set through its
+// setters, which are synchronized with the entries being logged, so loggers
+// can be built while other goroutines are logging, e.g.: when running several
+// processes concurrently.
 func BuildLoggerWithConfigAndFields(config config.LogConfig, fields log.Fields) *log.Entry {
 	logger := log.StandardLogger()
-	// NOTE: race condition found #2
-	logger.Level = getLogLevel(config.LogLevel)
+	logger.SetLevel(getLogLevel(config.LogLevel))
 
-	// NOTE: race condition found #3
-	logger.Formatter = &log.TextFormatter{
+	var formatter log.Formatter = &log.TextFormatter{
 		FullTimestamp:    true,
 		TimestampFormat:  "2006-01-02 15:04:05",
 		DisableTimestamp: false,
@@ -31,9 +40,12 @@ func BuildLoggerWithConfigAndFields(config config.LogConfig, fields log.Fields)
 	// By now the only valid log formatter names are 'text and 'json'.
 	// Anything different to 'json' will set the formatter to text.
 	if config.LogFmt == "json" {
-		logger.Formatter = &log.JSONFormatter{}
-
+		formatter = &log.JSONFormatter{}
 	}
+	logger.SetFormatter(formatter)
+	redactHookOnce.Do(func() {
+		logger.AddHook(redact.Hook{})
+	})
 	return logger.WithFields(fields)
 }
 
@@ -50,16 +62,40 @@ func BuildRootLogWithLevelFatal() *log.Entry {
 	return logger.WithField("vulcan-check-sdk", "local")
 }
 
+// truncatedSuffix is appended to the values of the fields truncated.
+const truncatedSuffix = "...(truncated)"
+
 // BuildRootLogWithConfig builds a new log setted up according to a given config.
+// Only the fields of the check listed in the LogFields of the config, or all of
+// them if the list is empty, are attached to the log.
+// The secrets in the options are masked before truncating them, as the hook
+// that masks the secrets can not parse truncated JSON documents.
 // This method is usefull for testing
 func BuildRootLogWithConfig(component string, config *config.Config) *log.Entry {
+	opts := config.Check.Opts
+	if redacted, masked := redact.JSON([]byte(opts)); masked {
+		opts = string(redacted)
+	}
+	if max := config.Log.LogMaxOptsLen; max > 0 && len(opts) > max {
+		opts = opts[:max] + truncatedSuffix
+	}
 	fields := log.Fields{
 		"target":           config.Check.Target,
-		"opts":             config.Check.Opts,
+		"opts":             opts,
 		"checkID":          config.Check.CheckID,
 		"checkTypeName":    config.Check.CheckTypeName,
 		"checkTypeVersion": config.Check.CheckTypeVersion,
-		"component":        component}
+	}
+	if len(config.Log.LogFields) > 0 {
+		selected := log.Fields{}
+		for _, f := range config.Log.LogFields {
+			if v, ok := fields[f]; ok {
+				selected[f] = v
+			}
+		}
+		fields = selected
+	}
+	fields["component"] = component
 	return BuildLoggerWithConfigAndFields(config.Log, fields)
 }
 
@@ -1,7 +1,10 @@
 package logging
 
 import (
+	"bytes"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 
 	log "github.com/sirupsen/logrus"
@@ -16,6 +19,8 @@ type buildRootLogTestArgs struct {
 	checkTypeVersion string
 	logLevel         string
 	formatter        string
+	fields           []string
+	maxOptsLen       int
 }
 
 type buildRootlogTest struct {
@@ -65,6 +70,48 @@ func TestBuildRootLog(t *testing.T) {
 				"component":        "sdk.test",
 			},
 		},
+		{
+			name: "ExcludeOpts",
+			args: buildRootLogTestArgs{
+				target:  "testTarget",
+				opt:     "{\"option\":\"a-option\"}",
+				checkID: "id",
+				fields:  []string{"target", "checkID", "unknown"},
+			},
+			wantFields: map[string]string{
+				"target":    "testTarget",
+				"checkID":   "id",
+				"component": "sdk.test",
+			},
+		},
+		{
+			name: "TruncateOpts",
+			args: buildRootLogTestArgs{
+				target:     "testTarget",
+				opt:        "{\"option\":\"a-option\"}",
+				checkID:    "id",
+				fields:     []string{"opts"},
+				maxOptsLen: 10,
+			},
+			wantFields: map[string]string{
+				"opts":      "{\"option\":" + truncatedSuffix,
+				"component": "sdk.test",
+			},
+		},
+		{
+			name: "RedactTruncatedOpts",
+			args: buildRootLogTestArgs{
+				target:     "testTarget",
+				opt:        "{\"apiPassword\":\"s3cr3t\",\"option\":\"a-option\"}",
+				checkID:    "id",
+				fields:     []string{"opts"},
+				maxOptsLen: 25,
+			},
+			wantFields: map[string]string{
+				"opts":      "{\"apiPassword\":\"********\"" + truncatedSuffix,
+				"component": "sdk.test",
+			},
+		},
 	}
 	for _, tt := range tests {
 		// Tests can not be executed in parallel because package level variables need to be assigned in each test,
@@ -78,8 +125,10 @@ func TestBuildRootLog(t *testing.T) {
 				CheckTypeName:    tt.args.checkTypeName,
 			},
 			Log: config.LogConfig{
-				LogFmt:   tt.args.formatter,
-				LogLevel: tt.args.logLevel,
+				LogFmt:        tt.args.formatter,
+				LogLevel:      tt.args.logLevel,
+				LogFields:     tt.args.fields,
+				LogMaxOptsLen: tt.args.maxOptsLen,
 			},
 		}
 
@@ -93,6 +142,48 @@ func TestBuildRootLog(t *testing.T) {
 	}
 }
 
+func TestBuildRootLogRedactsLongOpts(t *testing.T) {
+	secret := "s3cr3t-" + strings.Repeat("x", 200)
+	conf := &config.Config{
+		Check: config.CheckConfig{
+			Opts: `{"token":"` + secret + `","ports":["` + strings.Repeat("1,", 100) + `"]}`,
+		},
+		Log: config.LogConfig{
+			LogLevel:      "info",
+			LogMaxOptsLen: 50,
+		},
+	}
+	l := BuildRootLogWithConfig("sdk.test", conf)
+	var out bytes.Buffer
+	stdout := l.Logger.Out
+	l.Logger.SetOutput(&out)
+	defer l.Logger.SetOutput(stdout)
+	l.Info("Starting check")
+	if strings.Contains(out.String(), "s3cr3t") {
+		t.Errorf("want the secret in the options masked, got %s", out.String())
+	}
+	if !strings.Contains(out.String(), truncatedSuffix) {
+		t.Errorf("want the options truncated, got %s", out.String())
+	}
+}
+
+func TestBuildRootLogConcurrent(t *testing.T) {
+	conf := &config.Config{Log: config.LogConfig{LogLevel: "error"}}
+	l := BuildRootLogWithConfig("sdk.test", conf)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Building a logger must not race with the entries being logged
+			// by other goroutines.
+			BuildRootLogWithConfig("sdk.test", conf).Debug("built")
+			l.Debug("logging")
+		}()
+	}
+	wg.Wait()
+}
+
 func extractFields(out *log.Entry) map[string]string {
 	data := out.Data
 	result := make(map[string]string)
@@ -2,6 +2,7 @@ package push
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -11,7 +12,9 @@ import (
 	"github.com/adevinta/vulcan-check-sdk/config"
 	"github.com/adevinta/vulcan-check-sdk/helpers"
 	"github.com/adevinta/vulcan-check-sdk/internal/logging"
+	"github.com/adevinta/vulcan-check-sdk/internal/push/grpc"
 	"github.com/adevinta/vulcan-check-sdk/internal/push/rest"
+	"github.com/adevinta/vulcan-check-sdk/metrics"
 	"github.com/adevinta/vulcan-check-sdk/state"
 )
 
@@ -23,6 +26,20 @@ type checkAPI interface {
 	Shutdown() error
 }
 
+const (
+	// PhaseNotStarted is the lifecycle phase of a check that has been created
+	// but not started yet.
+	PhaseNotStarted = "NOT_STARTED"
+	// PhaseRunning is the lifecycle phase of a check while the checker is running.
+	PhaseRunning = "RUNNING"
+	// PhaseAborting is the lifecycle phase of a check that has been requested to
+	// abort but whose checker has not returned yet.
+	PhaseAborting = "ABORTING"
+	// PhaseFinished is the lifecycle phase of a check after the checker has
+	// returned.
+	PhaseFinished = "FINISHED"
+)
+
 // Check stores the 'pieces' needed to run a checker.
 type Check struct {
 	Logger          *log.Entry
@@ -34,6 +51,10 @@ type Check struct {
 	cancel          context.CancelFunc
 	ctx             context.Context
 	checkerFinished *sync.WaitGroup
+	phaseMu         sync.Mutex
+	phase           string
+	softAbort       chan struct{}
+	softAbortOnce   sync.Once
 }
 
 // Checker defines the shape a checker must have in order to be executed as vulcan-check.
@@ -43,32 +64,102 @@ type Checker interface {
 }
 
 // Abort recives the Abort message from the api that is listening for a term signal.
+// The abort is performed in two phases: first the soft abort signal is sent to
+// the checker and, after the configured grace period, the context of the
+// checker is cancelled.
 func (c *Check) Abort() (err error) {
 	c.Logger.Warn("Aborting check")
-	c.cancel()
+	c.phaseMu.Lock()
+	if c.phase == PhaseRunning {
+		c.phase = PhaseAborting
+	}
+	c.phaseMu.Unlock()
+	c.softAbortOnce.Do(func() {
+		close(c.softAbort)
+		grace := c.config.AbortGracePeriod
+		if grace <= 0 {
+			c.cancel()
+			return
+		}
+		c.Logger.WithField("grace_period", grace).Info("Waiting for the checker to finish before cancelling it")
+		time.AfterFunc(grace, c.cancel)
+	})
 	return
 }
 
+// Cancel aborts the check programmatically following the same path than when
+// a SIGINT or SIGTERM signal is received, that is: the checker receives the
+// soft abort signal and its context is cancelled after the configured grace
+// period. It can be safely called multiple times and from multiple goroutines.
+func (c *Check) Cancel() error {
+	c.Logger.Info("Check cancelled programmatically")
+	return c.Abort()
+}
+
+// Status returns the current lifecycle phase of the check, that is one of:
+// PhaseNotStarted, PhaseRunning, PhaseAborting or PhaseFinished. It's safe to
+// call it from multiple goroutines.
+func (c *Check) Status() string {
+	c.phaseMu.Lock()
+	defer c.phaseMu.Unlock()
+	return c.phase
+}
+
+func (c *Check) setPhase(phase string) {
+	c.phaseMu.Lock()
+	c.phase = phase
+	c.phaseMu.Unlock()
+}
+
 // Shutdown causes the Check to shutdown the API and the State provider. Also as a side effect, RunAndServe will also return.
+// The API is shut down even when the pending messages can not be sent to the
+// agent before the shutdown timeout expires, and the error of the state is
+// returned first.
 func (c *Check) Shutdown() error {
 	c.Logger.Debug("Shutting down check services")
-	var err error
 	// This ensures push state sent all the pending messages to the agent.
-	if err = c.checkState.Shutdown(); err != nil {
-		return err
-	}
+	stateErr := c.shutdownState()
 	// This ensures the goroutines for the api terminate gracefully.
-	if err = c.api.Shutdown(); err != nil {
-		return err
+	apiErr := c.api.Shutdown()
+	if stateErr != nil {
+		return stateErr
+	}
+	if apiErr != nil {
+		return apiErr
 	}
 	c.Logger.Debug("Check services shutted down")
-	return err
+	return nil
+}
+
+// shutdownState shuts down the push state waiting, at most, the configured
+// shutdown timeout for the pending messages to be sent to the agent.
+func (c *Check) shutdownState() error {
+	timeout := c.config.ShutdownTimeout
+	if timeout <= 0 {
+		return c.checkState.Shutdown()
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- c.checkState.Shutdown()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		fields := log.Fields{"timeout": timeout.String()}
+		if p, ok := c.checkState.pusher.(pendingPusher); ok {
+			fields["pending_msgs"] = p.Pending()
+		}
+		c.Logger.WithFields(fields).Error("Timeout waiting for the pending messages to be sent to the agent")
+		return ErrShutdownTimeout
+	}
 }
 
 // RunAndServe start running the check.
 func (c *Check) RunAndServe() {
 	// Initialize sync point for the checker and the push state to be finished.
 	c.checkerFinished.Add(1)
+	c.setPhase(PhaseRunning)
 	c.api.Run()
 	c.checkState.SetStatusRunning()
 	// Run the checker.
@@ -84,28 +175,47 @@ func (c *Check) executeChecker() {
 	var err error
 	defer c.checkerFinished.Done()
 	c.Logger.Info("Check start")
-	startTime := time.Now()
+	startTime := agent.Now()
 	runtimeCheckState := state.State{
 		ResultData:       &c.checkState.state.Report.ResultData,
 		ProgressReporter: c.checkState,
 	}
+	if c.config.StreamFindings {
+		runtimeCheckState.FindingSink = c.checkState
+	}
+	ctx := c.ctx
+	found := func() bool { return false }
+	if c.config.CancelOnFirstFinding {
+		var cancelRun context.CancelFunc
+		ctx, cancelRun = context.WithCancel(c.ctx)
+		defer cancelRun()
+		runtimeCheckState.FindingSink, found = state.CancelOnFinding(runtimeCheckState.FindingSink, cancelRun)
+	}
 
-	// Do not run checks against hostnames that resolve to private IPs unless allowed.
-	if ptrToBool(c.config.AllowPrivateIPs) || helpers.IsScannable(c.config.Check.Target) {
-		err = c.checker.Run(c.ctx, c.config.Check.Target, c.config.Check.Opts, runtimeCheckState)
-		// We always execute the cleanup function after the check has finished.
-		// We use a fresh new context because here the origin context created for
-		// running the check can be finalized.
-		c.checker.CleanUp(context.Background(), c.config.Check.Target, c.config.Check.Opts)
-	} else {
-		err = fmt.Errorf("target is not scannable")
+	if c.config.ActiveCheck {
+		if err := runtimeCheckState.SetActiveCheck(true); err != nil {
+			c.Logger.WithError(err).Error("Error recording the check as active in the report")
+		}
 	}
 
-	c.checkState.SetEndTime(time.Now())
-	elapsedTime := time.Since(startTime)
-	// If an error has been returned, we set the correct status.
-	if err != nil {
-		if err == context.Canceled {
+	err = c.runChecker(ctx, runtimeCheckState)
+
+	// The cancellation of a checker after its first finding is not an error,
+	// unless the check has also been aborted. Any other error is kept.
+	if found() && errors.Is(err, context.Canceled) && c.ctx.Err() == nil {
+		err = nil
+	}
+	c.checkState.SetEndTime(agent.Now())
+	elapsedTime := agent.Now().Sub(startTime)
+	// If an error has been returned, we set the correct status, unless the
+	// checker has already set the final status of the check.
+	if c.checkState.explicitStatus {
+		if err != nil {
+			c.Logger.WithError(err).Warn("Error running check after its status was set by the checker")
+		}
+		c.checkState.PushExplicitStatus()
+	} else if err != nil {
+		if errors.Is(err, context.Canceled) {
 			log.Info("Check aborted")
 			c.checkState.SetStatusAborted()
 		} else {
@@ -115,10 +225,109 @@ func (c *Check) executeChecker() {
 	} else {
 		c.checkState.SetStatusFinished()
 	}
+	c.setPhase(PhaseFinished)
 	currentState := c.checkState.State()
+	c.recordMetrics(elapsedTime, currentState)
 	c.Logger.WithFields(log.Fields{"time": elapsedTime, "state": currentState}).Info("Check finished")
 }
 
+// runChecker runs the checker if the target is allowed to be scanned and
+// returns the error that determines the final status of the check.
+func (c *Check) runChecker(ctx context.Context, s state.State) error {
+	// We always execute the cleanup function, even when the checker is not
+	// run, because the checker can allocate resources before the target is
+	// checked, e.g.: when it is created. We use a fresh new context because
+	// here the origin context created for running the check can be finalized.
+	defer c.checker.CleanUp(context.Background(), c.config.Check.Target, c.config.Check.Opts)
+	if c.config.ActiveCheck && c.config.DisallowActiveChecks {
+		// Refusing to run is not a failure of the check, it just can't tell
+		// anything about the target.
+		c.Logger.Warn("Active checks are not allowed, the checker is not run")
+		return c.checkState.SetInconclusive("active checks are not allowed")
+	}
+	denied, err := c.isDenied(c.config.Check.Target)
+	if err != nil {
+		return fmt.Errorf("can not load deny list: %v", err)
+	}
+	if denied {
+		return fmt.Errorf("target is in the deny list")
+	}
+	allowed, err := c.isScannable(c.config.Check.Target)
+	if err != nil {
+		return fmt.Errorf("invalid scannable allow list: %v", err)
+	}
+	if !allowed {
+		return fmt.Errorf("target is not scannable")
+	}
+	if reason := c.unreachableReason(ctx); reason != "" {
+		// The checker is not run and the status of the check is final.
+		return c.checkState.SetInconclusive(reason)
+	}
+	return c.checker.Run(ctx, c.config.Check.Target, c.config.Check.Opts, s)
+}
+
+func (c *Check) recordMetrics(elapsed time.Duration, s *agent.State) {
+	m := metrics.Default()
+	labels := map[string]string{"checktype": c.config.Check.CheckTypeName}
+	m.Observe(metrics.CheckDuration, elapsed.Seconds(), labels)
+	m.Observe(metrics.VulnerabilitiesEmitted, float64(len(s.Report.Vulnerabilities)), labels)
+	statusLabels := map[string]string{
+		"checktype": c.config.Check.CheckTypeName,
+		"status":    s.Status,
+	}
+	m.Increment(metrics.CheckStatus, statusLabels)
+}
+
+// unreachableReason returns, when the reachability preflight is enabled, the
+// reason why the target is not reachable, if it's not. Otherwise it returns an
+// empty string.
+func (c *Check) unreachableReason(ctx context.Context) string {
+	if !c.config.ReachabilityPreflight {
+		return ""
+	}
+	err := helpers.CheckReachable(ctx, c.config.Check.Target)
+	if !errors.Is(err, helpers.ErrUnreachable) {
+		// Other errors, like the context being cancelled, are handled when
+		// running the checker.
+		return ""
+	}
+	c.Logger.WithError(err).Info("Target unreachable")
+	return err.Error()
+}
+
+// isDenied returns true if the target is in the deny list file configured for
+// the check, if any.
+func (c *Check) isDenied(target string) (bool, error) {
+	if c.config.DenyListFile == "" {
+		return false, nil
+	}
+	m, err := helpers.LoadTargetList(c.config.DenyListFile)
+	if err != nil {
+		return false, err
+	}
+	return m.Matches(target), nil
+}
+
+// isScannable returns true if the target can be scanned, that is: scanning
+// private IPs is allowed, the target is in the scannable allow list or the
+// target is not, or does not resolve to, a private IP.
+func (c *Check) isScannable(target string) (bool, error) {
+	if ptrToBool(c.config.AllowPrivateIPs) {
+		return true, nil
+	}
+	if len(c.config.ScannableAllowList) > 0 {
+		m, err := helpers.NewTargetMatcher(c.config.ScannableAllowList)
+		if err != nil {
+			return false, err
+		}
+		if m.Matches(target) {
+			return true, nil
+		}
+	}
+	// Do not run checks against hostnames that resolve to private IPs unless allowed.
+	return helpers.IsScannable(target), nil
+}
+
 func ptrToBool(b *bool) bool {
 	if b != nil {
 		return *b
@@ -128,18 +337,61 @@ func ptrToBool(b *bool) bool {
 
 // NewCheckWithConfig creates a check with a given configuration
 func NewCheckWithConfig(name string, checker Checker, logger *log.Entry, conf *config.Config) *Check {
+	return NewCheckWithContext(context.Background(), name, checker, logger, conf)
+}
+
+// NewCheckWithContext creates a check with a given configuration whose
+// context, the one passed to the checker, is derived from the given parent
+// context. Cancelling the parent context cancels immediately the context of
+// the checker, without sending the soft abort signal nor waiting for the abort
+// grace period, and the check finishes in the aborted status.
+func NewCheckWithContext(ctx context.Context, name string, checker Checker, logger *log.Entry, conf *config.Config) *Check {
 	c := &Check{
 		Name:   name,
 		Logger: logger,
 		config: conf,
+		phase:  PhaseNotStarted,
+	}
+	c.softAbort = make(chan struct{})
+	ctx = state.ContextWithSoftAbort(ctx, c.softAbort)
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	var pussher StatePusher
+	if conf.CommMode == config.CommModeGRPC {
+		pushLogger := logging.BuildRootLogWithNameAndConfig("sdk.grpcPusher", conf, name)
+		pussher = grpc.NewPusher(conf.Push.AgentAddr, conf.Push.BufferLen, conf.Check.CheckID, pushLogger)
+	} else {
+		pushLogger := logging.BuildRootLogWithNameAndConfig("sdk.restPusher", conf, name)
+		pussher = rest.NewRestPusher(conf.Push, conf.Check.CheckID, pushLogger)
+	}
+	sinks := []StatePusher{pussher}
+	if conf.StateArchiveFile != "" {
+		archive, err := NewFilePusher(conf.StateArchiveFile)
+		if err != nil {
+			// The check can still send its states to the agent.
+			logger.WithError(err).Error("Error opening the state archive file")
+		} else {
+			sinks = append(sinks, archive)
+		}
+	}
+	if conf.TeeStatesToStdout {
+		sinks = append(sinks, NewStdoutPusher())
+	}
+	if conf.ProgressFile != "" {
+		progress, err := NewProgressPusher(conf.ProgressFile)
+		if err != nil {
+			logger.WithError(err).Error("Error opening the progress file")
+		} else {
+			sinks = append(sinks, progress)
+		}
+	}
+	if len(sinks) > 1 {
+		fanOutLogger := logging.BuildRootLogWithNameAndConfig("sdk.fanOutPusher", conf, name)
+		pussher = NewFanOutPusher(conf.StateSinksFailFast, fanOutLogger, sinks[0], sinks[1:]...)
 	}
-	c.ctx, c.cancel = context.WithCancel(context.Background())
-	pushLogger := logging.BuildRootLogWithNameAndConfig("sdk.restPusher", conf, name)
-	pussher := rest.NewRestPusher(conf.Push, conf.Check.CheckID, pushLogger)
 	r := agent.NewReportFromConfig(conf.Check)
 	stateLogger := logging.BuildRootLogWithNameAndConfig("sdk.pushState", conf, name)
 	agentState := agent.State{Report: r}
-	c.checkState = newState(agentState, pussher, stateLogger)
+	c.checkState = newState(agentState, pussher, stateLogger, conf.MaxReportBytes, conf.ReportPageSize)
 	c.api = newPushAPI(logger, c)
 	// Initialize a sync point for goroutines to wait for the checker run method
 	// to be finished, for instance a call to an abort method should wait in this sync point.
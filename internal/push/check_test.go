@@ -2,6 +2,14 @@ package push
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"sync"
 	"syscall"
 	"testing"
 	"time"
@@ -14,8 +22,9 @@ import (
 	"github.com/adevinta/vulcan-check-sdk/agent"
 	"github.com/adevinta/vulcan-check-sdk/config"
 	"github.com/adevinta/vulcan-check-sdk/internal/logging"
+	"github.com/adevinta/vulcan-check-sdk/internal/testagent"
+	"github.com/adevinta/vulcan-check-sdk/metrics"
 	"github.com/adevinta/vulcan-check-sdk/state"
-	"github.com/adevinta/vulcan-check-sdk/tools"
 	report "github.com/adevinta/vulcan-report"
 )
 
@@ -61,7 +70,7 @@ type pushIntParams struct {
 	checkRunner     CheckerHandleRun
 	checkCleaner    func(resourceToClean interface{}, ctx context.Context, target string, optJSON string)
 	resourceToClean interface{}
-	agent           *tools.Reporter
+	agent           *testagent.Reporter
 	checkName       string
 	config          *config.Config
 }
@@ -71,7 +80,7 @@ func TestIntegrationPushMode(t *testing.T) {
 		pushIntTest{
 			name: "HappyPath",
 			args: pushIntParams{
-				agent: tools.NewReporter("checkID"),
+				agent: testagent.NewReporter("checkID"),
 				config: &config.Config{
 					Check: config.CheckConfig{
 						CheckID:       "checkID",
@@ -180,7 +189,7 @@ func TestIntegrationPushMode(t *testing.T) {
 		pushIntTest{
 			name: "Abort",
 			args: pushIntParams{
-				agent: tools.NewReporter("checkID"),
+				agent: testagent.NewReporter("checkID"),
 				config: &config.Config{
 					Check: config.CheckConfig{
 						CheckID: "checkID",
@@ -321,3 +330,625 @@ func compareMsgs(got []agent.State, want []agent.State) (bool, interface{}) {
 	}
 	return ok, diffs
 }
+
+// newPushTestConfig returns the config of a check in push mode, against the
+// given target, that is allowed to scan private IPs.
+func newPushTestConfig(target string) *config.Config {
+	conf := &config.Config{
+		Check: config.CheckConfig{
+			CheckID: "checkID",
+			Target:  target,
+		},
+		Log: config.LogConfig{
+			LogFmt:   "text",
+			LogLevel: "debug",
+		},
+		CommMode: "push",
+	}
+	return conf.AllowPrivate(true)
+}
+
+// runPushCheck runs a check with the given config and handlers against a test
+// agent and returns the states received by the agent.
+func runPushCheck(t *testing.T, conf *config.Config, run CheckerHandleRun, clean CheckerHandleCleanUp) []agent.State {
+	t.Helper()
+	states := testagent.Record(conf)
+	l := logging.BuildRootLog("pushCheck")
+	c := NewCheckFromHandlerWithConfig("pushCheck", run, clean, conf, l)
+	c.RunAndServe()
+	msgs := states()
+	if len(msgs) == 0 {
+		t.Fatal("no messages received")
+	}
+	return msgs
+}
+
+func TestCheckStatus(t *testing.T) {
+	conf := newPushTestConfig("www.example.com")
+	states := testagent.Record(conf)
+	running := make(chan struct{})
+	release := make(chan struct{})
+	run := func(ctx context.Context, target string, optJSON string, state state.State) error {
+		close(running)
+		<-release
+		return ctx.Err()
+	}
+	l := logging.BuildRootLog("pushCheck")
+	c := NewCheckFromHandlerWithConfig("status", run, nil, conf, l)
+	if got := c.Status(); got != PhaseNotStarted {
+		t.Fatalf("want status %s, got %s", PhaseNotStarted, got)
+	}
+	done := make(chan struct{})
+	go func() {
+		c.RunAndServe()
+		close(done)
+	}()
+	<-running
+	if got := c.Status(); got != PhaseRunning {
+		t.Fatalf("want status %s, got %s", PhaseRunning, got)
+	}
+	if err := c.Abort(); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Status(); got != PhaseAborting {
+		t.Fatalf("want status %s, got %s", PhaseAborting, got)
+	}
+	close(release)
+	<-done
+	states()
+	if got := c.Status(); got != PhaseFinished {
+		t.Fatalf("want status %s, got %s", PhaseFinished, got)
+	}
+}
+
+func TestAbortGracePeriod(t *testing.T) {
+	conf := newPushTestConfig("www.example.com")
+	conf.AbortGracePeriod = 100 * time.Millisecond
+	states := testagent.Record(conf)
+	running := make(chan struct{})
+	partial := report.Vulnerability{Summary: "Partial finding"}
+	run := func(ctx context.Context, target string, optJSON string, s state.State) error {
+		close(running)
+		<-state.SoftAbort(ctx)
+		if ctx.Err() != nil {
+			return errors.New("context cancelled before the grace period expired")
+		}
+		s.AddVulnerabilities(partial)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	l := logging.BuildRootLog("pushCheck")
+	c := NewCheckFromHandlerWithConfig("softAbort", run, nil, conf, l)
+	go func() {
+		<-running
+		if err := c.Abort(); err != nil {
+			t.Error(err)
+		}
+	}()
+	c.RunAndServe()
+	gotMsgs := states()
+	if len(gotMsgs) == 0 {
+		t.Fatal("no messages received")
+	}
+	last := gotMsgs[len(gotMsgs)-1]
+	if last.Status != agent.StatusAborted {
+		t.Errorf("want status %s, got %s, error %s", agent.StatusAborted, last.Status, last.Report.Error)
+	}
+	want := []report.Vulnerability{partial}
+	if diff := cmp.Diff(want, last.Report.Vulnerabilities); diff != "" {
+		t.Errorf("want partial finding in the report, diff %s", diff)
+	}
+}
+
+type fakeMetrics struct {
+	mu           sync.Mutex
+	counters     map[string]map[string]string
+	observations map[string]float64
+}
+
+func (f *fakeMetrics) Increment(name string, labels map[string]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.counters[name] = labels
+}
+
+func (f *fakeMetrics) Observe(name string, value float64, labels map[string]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.observations[name] = value
+}
+
+func TestCheckMetrics(t *testing.T) {
+	m := &fakeMetrics{
+		counters:     map[string]map[string]string{},
+		observations: map[string]float64{},
+	}
+	metrics.Register(m)
+	defer metrics.Register(nil)
+	conf := newPushTestConfig("www.example.com")
+	conf.Check.CheckTypeName = "checkTypeName"
+	run := func(ctx context.Context, target string, optJSON string, s state.State) error {
+		s.AddVulnerabilities(report.Vulnerability{Summary: "Test Vulnerability"})
+		return nil
+	}
+	runPushCheck(t, conf, run, nil)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.observations[metrics.CheckDuration]; !ok {
+		t.Errorf("want observation %s recorded", metrics.CheckDuration)
+	}
+	if got := m.observations[metrics.VulnerabilitiesEmitted]; got != 1 {
+		t.Errorf("want observation %s with value 1, got %v", metrics.VulnerabilitiesEmitted, got)
+	}
+	wantLabels := map[string]string{"checktype": "checkTypeName", "status": agent.StatusFinished}
+	if diff := cmp.Diff(wantLabels, m.counters[metrics.CheckStatus]); diff != "" {
+		t.Errorf("want counter %s recorded with labels %v, diff %s", metrics.CheckStatus, wantLabels, diff)
+	}
+}
+
+func TestStateSetMetadata(t *testing.T) {
+	conf := newPushTestConfig("www.example.com")
+	run := func(ctx context.Context, target string, optJSON string, s state.State) error {
+		if err := s.SetMetadata(map[string]string{"run_id": "1", "env": "pre"}); err != nil {
+			return err
+		}
+		return s.SetMetadata(map[string]string{"env": "pro"})
+	}
+	gotMsgs := runPushCheck(t, conf, run, nil)
+	last := gotMsgs[len(gotMsgs)-1]
+	if last.Status != agent.StatusFinished {
+		t.Fatalf("want status %s, got %s, error %s", agent.StatusFinished, last.Status, last.Report.Error)
+	}
+	data := map[string]map[string]string{}
+	if err := json.Unmarshal(last.Report.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"run_id": "1", "env": "pro"}
+	if diff := cmp.Diff(want, data[state.MetadataKey]); diff != "" {
+		t.Errorf("metadata in the report != want, diff %s", diff)
+	}
+}
+
+func TestCheckTargetPolicy(t *testing.T) {
+	f, err := ioutil.TempFile("", "denylist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name()) // nolint
+	if _, err := f.WriteString("*.example.com\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close() // nolint
+	denyListConf := newPushTestConfig("www.example.com")
+	denyListConf.DenyListFile = f.Name()
+
+	allowListConf := newPushTestConfig("localhost").AllowPrivate(false)
+	allowListConf.ScannableAllowList = []string{"203.0.113.0/24", "localhost"}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := "http://" + ln.Addr().String() + "/"
+	// Close the listener so the port of the target is not open.
+	ln.Close() // nolint
+	preflightConf := newPushTestConfig(unreachable)
+	preflightConf.ReachabilityPreflight = true
+
+	tests := []struct {
+		name        string
+		conf        *config.Config
+		wantRun     bool
+		wantCleanUp bool
+		wantStatus  string
+		wantError   string
+		wantNotes   string
+	}{
+		{
+			name:       "DenyListed",
+			conf:       denyListConf,
+			wantStatus: agent.StatusFailed,
+			wantError:  "target is in the deny list",
+		},
+		{
+			name:       "AllowPrivate",
+			conf:       newPushTestConfig("127.0.0.1"),
+			wantRun:    true,
+			wantStatus: agent.StatusFinished,
+		},
+		{
+			name:       "AllowListedPrivateTarget",
+			conf:       allowListConf,
+			wantRun:    true,
+			wantStatus: agent.StatusFinished,
+		},
+		{
+			name:        "NotScannable",
+			conf:        newPushTestConfig("127.0.0.1").AllowPrivate(false),
+			wantCleanUp: true,
+			wantStatus:  agent.StatusFailed,
+			wantError:   "target is not scannable",
+		},
+		{
+			name:       "Unreachable",
+			conf:       preflightConf,
+			wantStatus: agent.StatusInconclusive,
+			wantNotes:  "target unreachable: port",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var scanned string
+			var cleanedUp bool
+			run := func(ctx context.Context, target string, optJSON string, s state.State) error {
+				scanned = target
+				return nil
+			}
+			cleanUp := func(ctx context.Context, target string, opts string) {
+				cleanedUp = true
+			}
+			gotMsgs := runPushCheck(t, tt.conf, run, cleanUp)
+			if tt.wantRun && scanned != tt.conf.Check.Target {
+				t.Errorf("want target %s scanned, got %q", tt.conf.Check.Target, scanned)
+			}
+			if !tt.wantRun && scanned != "" {
+				t.Errorf("want the checker not run, got target %s scanned", scanned)
+			}
+			if tt.wantCleanUp && !cleanedUp {
+				t.Error("want the clean up run")
+			}
+			last := gotMsgs[len(gotMsgs)-1]
+			if last.Status != tt.wantStatus || last.Report.Error != tt.wantError {
+				t.Errorf("want status %s with error %q, got %s with error %q", tt.wantStatus, tt.wantError, last.Status, last.Report.Error)
+			}
+			if !strings.Contains(last.Report.Notes, tt.wantNotes) {
+				t.Errorf("want %q in the notes of the report, got %q", tt.wantNotes, last.Report.Notes)
+			}
+		})
+	}
+}
+
+func TestCheckCancel(t *testing.T) {
+	tests := []struct {
+		name      string
+		cancelErr func(ctx context.Context) error
+	}{
+		{
+			name:      "Cancelled",
+			cancelErr: func(ctx context.Context) error { return ctx.Err() },
+		},
+		{
+			name:      "WrappedCancelled",
+			cancelErr: func(ctx context.Context) error { return fmt.Errorf("scanning: %w", ctx.Err()) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := newPushTestConfig("www.example.com")
+			states := testagent.Record(conf)
+			running := make(chan struct{})
+			run := func(ctx context.Context, target string, optJSON string, s state.State) error {
+				close(running)
+				<-ctx.Done()
+				return tt.cancelErr(ctx)
+			}
+			l := logging.BuildRootLog("pushCheck")
+			c := NewCheckFromHandlerWithConfig("cancel", run, nil, conf, l)
+			go func() {
+				<-running
+				if err := c.Cancel(); err != nil {
+					t.Error(err)
+				}
+				// Cancelling twice must not panic.
+				if err := c.Cancel(); err != nil {
+					t.Error(err)
+				}
+			}()
+			c.RunAndServe()
+			gotMsgs := states()
+			if len(gotMsgs) == 0 {
+				t.Fatal("no messages received")
+			}
+			last := gotMsgs[len(gotMsgs)-1]
+			if last.Status != agent.StatusAborted {
+				t.Errorf("want status %s, got %s, error %s", agent.StatusAborted, last.Status, last.Report.Error)
+			}
+		})
+	}
+}
+
+func TestCheckWithContext(t *testing.T) {
+	conf := newPushTestConfig("www.example.com")
+	states := testagent.Record(conf)
+	running := make(chan struct{})
+	checker := struct {
+		CheckerHandleRun
+		CheckerHandleCleanUp
+	}{
+		func(ctx context.Context, target string, optJSON string, s state.State) error {
+			close(running)
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		func(ctx context.Context, target string, opts string) {},
+	}
+	parent, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l := logging.BuildRootLog("pushCheck")
+	c := NewCheckWithContext(parent, "context", checker, l, conf)
+	go func() {
+		<-running
+		cancel()
+	}()
+	c.RunAndServe()
+	gotMsgs := states()
+	if len(gotMsgs) == 0 {
+		t.Fatal("no messages received")
+	}
+	last := gotMsgs[len(gotMsgs)-1]
+	if last.Status != agent.StatusAborted {
+		t.Errorf("want status %s, got %s, error %s", agent.StatusAborted, last.Status, last.Report.Error)
+	}
+	if got := c.Status(); got != PhaseFinished {
+		t.Errorf("want phase %s, got %s", PhaseFinished, got)
+	}
+}
+
+func TestCheckActive(t *testing.T) {
+	tests := []struct {
+		name           string
+		disallowActive bool
+		wantRun        bool
+		wantStatus     string
+		wantNotes      string
+	}{
+		{
+			name:       "ActiveAllowed",
+			wantRun:    true,
+			wantStatus: agent.StatusFinished,
+		},
+		{
+			name:           "ActiveDisallowed",
+			disallowActive: true,
+			wantStatus:     agent.StatusInconclusive,
+			wantNotes:      "Inconclusive: active checks are not allowed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := newPushTestConfig("www.example.com")
+			conf.ActiveCheck = true
+			conf.DisallowActiveChecks = tt.disallowActive
+			var run bool
+			checker := func(ctx context.Context, target string, optJSON string, s state.State) error {
+				run = true
+				return nil
+			}
+			gotMsgs := runPushCheck(t, conf, checker, nil)
+			if run != tt.wantRun {
+				t.Errorf("want checker run %v, got %v", tt.wantRun, run)
+			}
+			last := gotMsgs[len(gotMsgs)-1]
+			if last.Status != tt.wantStatus {
+				t.Errorf("want status %s, got %s, error %s", tt.wantStatus, last.Status, last.Report.Error)
+			}
+			if last.Report.Notes != tt.wantNotes {
+				t.Errorf("want notes %q, got %q", tt.wantNotes, last.Report.Notes)
+			}
+			data := map[string]interface{}{}
+			if err := json.Unmarshal(last.Report.Data, &data); err != nil {
+				t.Fatal(err)
+			}
+			if data[state.ActiveCheckKey] != true {
+				t.Errorf("want the check recorded as active in the report data %s", last.Report.Data)
+			}
+		})
+	}
+}
+
+// blockedPusher is a pusher that never finishes sending its pending messages.
+type blockedPusher struct {
+	pending int
+	blocked chan struct{}
+}
+
+func (b *blockedPusher) UpdateState(state interface{}) {
+	b.pending++
+}
+
+func (b *blockedPusher) Pending() int {
+	return b.pending
+}
+
+func (b *blockedPusher) Shutdown() {
+	<-b.blocked
+}
+
+// shutdownRecorderAPI is a checkAPI that records whether it has been shut
+// down.
+type shutdownRecorderAPI struct {
+	shutdown bool
+}
+
+func (a *shutdownRecorderAPI) Run() {}
+
+func (a *shutdownRecorderAPI) Shutdown() error {
+	a.shutdown = true
+	return nil
+}
+
+func TestCheckShutdownTimeout(t *testing.T) {
+	conf := &config.Config{
+		Check: config.CheckConfig{
+			CheckID: "checkID",
+			Target:  "www.example.com",
+		},
+		Log: config.LogConfig{
+			LogFmt:   "text",
+			LogLevel: "debug",
+		},
+		CommMode:        "push",
+		ShutdownTimeout: 100 * time.Millisecond,
+	}
+	run := func(ctx context.Context, target string, optJSON string, s state.State) error {
+		return nil
+	}
+	l := logging.BuildRootLog("pushCheck")
+	c := NewCheckFromHandlerWithConfig("shutdownTimeout", run, nil, conf, l)
+	p := &blockedPusher{blocked: make(chan struct{})}
+	defer close(p.blocked)
+	c.checkState.pusher = p
+	c.checkState.SetStatusRunning()
+	api := &shutdownRecorderAPI{}
+	c.api = api
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- c.Shutdown()
+	}()
+	select {
+	case err := <-errs:
+		if err != ErrShutdownTimeout {
+			t.Errorf("want error %v, got %v", ErrShutdownTimeout, err)
+		}
+		if !api.shutdown {
+			t.Error("want the api shut down after the timeout")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown hanged")
+	}
+}
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+func TestCheckClock(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	agent.SetClock(fixedClock(now))
+	defer agent.SetClock(nil)
+
+	conf := newPushTestConfig("www.example.com")
+	run := func(ctx context.Context, target string, optJSON string, s state.State) error {
+		return nil
+	}
+	gotMsgs := runPushCheck(t, conf, run, nil)
+	last := gotMsgs[len(gotMsgs)-1]
+	if !last.Report.StartTime.Equal(now) {
+		t.Errorf("want start time %v, got %v", now, last.Report.StartTime)
+	}
+	if !last.Report.EndTime.Equal(now) {
+		t.Errorf("want end time %v, got %v", now, last.Report.EndTime)
+	}
+}
+
+func TestCheckCancelOnFirstFinding(t *testing.T) {
+	tests := []struct {
+		name       string
+		cancelErr  func(ctx context.Context) error
+		wantStatus string
+		wantError  string
+	}{
+		{
+			name:       "Cancelled",
+			cancelErr:  func(ctx context.Context) error { return ctx.Err() },
+			wantStatus: agent.StatusFinished,
+		},
+		{
+			name:       "WrappedCancelled",
+			cancelErr:  func(ctx context.Context) error { return fmt.Errorf("scanning: %w", ctx.Err()) },
+			wantStatus: agent.StatusFinished,
+		},
+		{
+			name:       "OtherError",
+			cancelErr:  func(ctx context.Context) error { return errors.New("connection reset") },
+			wantStatus: agent.StatusFailed,
+			wantError:  "connection reset",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := newPushTestConfig("www.example.com")
+			conf.CancelOnFirstFinding = true
+			var cancelled bool
+			run := func(ctx context.Context, target string, optJSON string, s state.State) error {
+				if ctx.Err() != nil {
+					t.Error("context cancelled before the first finding")
+				}
+				s.AddVulnerabilities(report.Vulnerability{Summary: "first"})
+				select {
+				case <-ctx.Done():
+					cancelled = true
+					return tt.cancelErr(ctx)
+				case <-time.After(5 * time.Second):
+					s.AddVulnerabilities(report.Vulnerability{Summary: "second"})
+					return nil
+				}
+			}
+			gotMsgs := runPushCheck(t, conf, run, nil)
+			if !cancelled {
+				t.Error("want the context of the checker cancelled after the first finding")
+			}
+			last := gotMsgs[len(gotMsgs)-1]
+			if last.Status != tt.wantStatus || last.Report.Error != tt.wantError {
+				t.Errorf("want status %s with error %q, got %s with error %q", tt.wantStatus, tt.wantError, last.Status, last.Report.Error)
+			}
+			if n := len(last.Report.Vulnerabilities); n != 1 {
+				t.Errorf("want 1 vulnerability, got %d", n)
+			}
+		})
+	}
+}
+
+func TestCheckExplicitStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		pageSize int
+	}{
+		{
+			name: "FullReport",
+		},
+		{
+			name:     "PagedReport",
+			pageSize: 10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := newPushTestConfig("www.example.com")
+			conf.ReportPageSize = tt.pageSize
+			checker := func(ctx context.Context, target string, optJSON string, s state.State) error {
+				if err := s.Finish(); err != nil {
+					return err
+				}
+				s.AddVulnerabilities(report.Vulnerability{Summary: "found after finishing"})
+				return nil
+			}
+			gotMsgs := runPushCheck(t, conf, checker, nil)
+			last := gotMsgs[len(gotMsgs)-1]
+			if last.Status != agent.StatusFinished {
+				t.Errorf("want status %s, got %s, error %s", agent.StatusFinished, last.Status, last.Report.Error)
+			}
+			if last.Report.EndTime.IsZero() {
+				t.Error("want the end time in the last state sent")
+			}
+			var vulns []report.Vulnerability
+			for i, msg := range gotMsgs {
+				if msg.Page == nil {
+					vulns = msg.Report.Vulnerabilities
+					continue
+				}
+				vulns = append(vulns, msg.Report.Vulnerabilities...)
+				if complete := i == len(gotMsgs)-1; msg.Page.Complete != complete {
+					t.Errorf("got complete %v in push %d, want %v", msg.Page.Complete, i, complete)
+				}
+			}
+			if len(vulns) != 1 {
+				t.Errorf("want the vulnerability added after finishing sent, got %d vulnerabilities", len(vulns))
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package push
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/adevinta/vulcan-check-sdk/agent"
+)
+
+// failingPusher defines the methods of the StatePushers that report whether
+// sending the last state failed.
+type failingPusher interface {
+	Err() error
+}
+
+// FanOutPusher is a StatePusher that forwards the states to a primary pusher,
+// e.g.: the one sending the states to the agent, and to several secondary
+// ones, e.g.: an archive file. Only the failures of the pushers that implement
+// an Err() error method, returning the error sending the last state, are
+// detected. The failures of the secondary pushers are logged and never stop
+// the states from being forwarded to the other pushers. In best-effort mode
+// the states keep being forwarded to a failing secondary pusher, while, in
+// fail-fast mode, a secondary pusher stops receiving states after its first
+// failure. The pusher only reports, through its Err method, the failures of
+// the primary pusher.
+type FanOutPusher struct {
+	primary   StatePusher
+	secondary []StatePusher
+	failFast  bool
+	logger    *log.Entry
+	mu        sync.Mutex
+	// failed contains the indexes of the secondary pushers that don't
+	// receive states anymore.
+	failed map[int]bool
+	err    error
+}
+
+// NewFanOutPusher creates a StatePusher that forwards the states to the
+// primary pusher and to the secondary ones. It works in fail-fast mode if
+// failFast is true and in best-effort mode otherwise.
+func NewFanOutPusher(failFast bool, logger *log.Entry, primary StatePusher, secondary ...StatePusher) *FanOutPusher {
+	return &FanOutPusher{
+		primary:   primary,
+		secondary: secondary,
+		failFast:  failFast,
+		logger:    logger,
+		failed:    map[int]bool{},
+	}
+}
+
+// UpdateState forwards the state to the primary pusher and then to each
+// secondary pusher in the order they were given.
+func (p *FanOutPusher) UpdateState(state interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.primary.UpdateState(state)
+	p.err = pusherErr(p.primary)
+	if p.err != nil {
+		p.logger.WithError(p.err).Error("Error sending state to the primary sink")
+	}
+	for i, pusher := range p.secondary {
+		if p.failed[i] {
+			continue
+		}
+		pusher.UpdateState(state)
+		err := pusherErr(pusher)
+		if err == nil {
+			continue
+		}
+		p.logger.WithError(err).WithField("sink", i).Error("Error sending state to sink")
+		if p.failFast {
+			p.logger.WithField("sink", i).Error("Stopping sending states to the sink")
+			p.failed[i] = true
+		}
+	}
+}
+
+// Err returns the error sending the last state to the primary pusher, if any.
+func (p *FanOutPusher) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// pusherErr returns the error sending the last state of the given pusher if
+// it reports it.
+func pusherErr(pusher StatePusher) error {
+	if fp, ok := pusher.(failingPusher); ok {
+		return fp.Err()
+	}
+	return nil
+}
+
+// Pending returns the number of messages queued and not sent yet by the
+// pushers that report it.
+func (p *FanOutPusher) Pending() int {
+	var n int
+	for _, pusher := range p.all() {
+		if pp, ok := pusher.(pendingPusher); ok {
+			n += pp.Pending()
+		}
+	}
+	return n
+}
+
+// Shutdown shuts down all the pushers, so the states pending to be sent by
+// each of them are drained.
+func (p *FanOutPusher) Shutdown() {
+	for _, pusher := range p.all() {
+		pusher.Shutdown()
+	}
+}
+
+func (p *FanOutPusher) all() []StatePusher {
+	return append([]StatePusher{p.primary}, p.secondary...)
+}
+
+// stdout is where the states are written by the pushers created with
+// NewStdoutPusher, this allows to replace it in tests.
+var stdout io.Writer = os.Stdout
+
+// FilePusher is a StatePusher that archives the states in a file, one JSON
+// document per line.
+type FilePusher struct {
+	w io.Writer
+	// closer is nil when the file must not be closed on Shutdown.
+	closer io.Closer
+	err    error
+}
+
+// NewFilePusher creates a pusher that appends the states to the file in the
+// given path, creating it if it doesn't exist.
+func NewFilePusher(path string) (*FilePusher, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &FilePusher{w: f, closer: f}, nil
+}
+
+// NewStdoutPusher creates a pusher that writes the states to the standard
+// output, one JSON document per line, e.g.: to debug the states sent to the
+// agent. The standard output is not closed on Shutdown.
+func NewStdoutPusher() *FilePusher {
+	return &FilePusher{w: stdout}
+}
+
+// UpdateState writes the state to the file.
+func (p *FilePusher) UpdateState(state interface{}) {
+	content, err := json.Marshal(state)
+	if err != nil {
+		p.err = err
+		return
+	}
+	_, p.err = p.w.Write(append(content, '\n'))
+}
+
+// Err returns the error writing the last state, if any.
+func (p *FilePusher) Err() error {
+	return p.err
+}
+
+// Shutdown closes the file.
+func (p *FilePusher) Shutdown() {
+	if p.closer != nil {
+		p.closer.Close() // nolint
+	}
+}
+
+// ProgressPusher is a StatePusher that writes the status and the progress of
+// the states to a file, one JSON document per line, e.g.:
+// {"status":"RUNNING","progress":0.5}. A state with the same status and
+// progress than the previous one is not written.
+type ProgressPusher struct {
+	f       *os.File
+	last    progressLine
+	written bool
+	err     error
+}
+
+type progressLine struct {
+	Status   string  `json:"status"`
+	Progress float32 `json:"progress"`
+}
+
+// NewProgressPusher creates a pusher that writes the progress to the given
+// destination, that can be a file descriptor in the form "fd:N", e.g.:
+// "fd:3", or the path of a file, that is created if it doesn't exist.
+func NewProgressPusher(dest string) (*ProgressPusher, error) {
+	if strings.HasPrefix(dest, "fd:") {
+		fd, err := strconv.Atoi(strings.TrimPrefix(dest, "fd:"))
+		if err != nil || fd < 0 {
+			return nil, fmt.Errorf("invalid file descriptor %q", dest)
+		}
+		return &ProgressPusher{f: os.NewFile(uintptr(fd), dest)}, nil
+	}
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &ProgressPusher{f: f}, nil
+}
+
+// UpdateState writes the status and the progress of the state to the file.
+func (p *ProgressPusher) UpdateState(state interface{}) {
+	s, ok := state.(agent.State)
+	if !ok {
+		p.err = fmt.Errorf("unexpected state type %T", state)
+		return
+	}
+	line := progressLine{Status: s.Status, Progress: s.Progress}
+	if p.written && line == p.last {
+		p.err = nil
+		return
+	}
+	content, err := json.Marshal(line)
+	if err != nil {
+		p.err = err
+		return
+	}
+	_, p.err = p.f.Write(append(content, '\n'))
+	p.last = line
+	p.written = true
+}
+
+// Err returns the error writing the last state, if any.
+func (p *ProgressPusher) Err() error {
+	return p.err
+}
+
+// Shutdown closes the file.
+func (p *ProgressPusher) Shutdown() {
+	p.f.Close() // nolint
+}
@@ -0,0 +1,222 @@
+package push
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/adevinta/vulcan-check-sdk/agent"
+	"github.com/adevinta/vulcan-check-sdk/state"
+	report "github.com/adevinta/vulcan-report"
+	"github.com/google/go-cmp/cmp"
+	log "github.com/sirupsen/logrus"
+)
+
+// failingRecordingPusher records the states and fails sending the ones whose
+// status is the given one.
+type failingRecordingPusher struct {
+	recordingPusher
+	failStatus string
+	err        error
+}
+
+func (f *failingRecordingPusher) UpdateState(state interface{}) {
+	f.recordingPusher.UpdateState(state)
+	f.err = nil
+	if state.(agent.State).Status == f.failStatus {
+		f.err = errors.New("sink failure")
+	}
+}
+
+func (f *failingRecordingPusher) Err() error {
+	return f.err
+}
+
+func TestFanOutPusher(t *testing.T) {
+	states := []agent.State{
+		{Status: agent.StatusRunning, Progress: 0.1},
+		{Status: agent.StatusRunning, Progress: 0.5},
+		{Status: agent.StatusFinished, Progress: 1},
+	}
+	tests := []struct {
+		name              string
+		failFast          bool
+		primaryFailStatus string
+		failStatus        string
+		wantFailing       []agent.State
+		wantErr           bool
+	}{
+		{
+			name:        "AllSinksReceiveEveryUpdate",
+			wantFailing: states,
+		},
+		{
+			name:        "BestEffort",
+			failStatus:  agent.StatusRunning,
+			wantFailing: states,
+		},
+		{
+			name:        "FailFast",
+			failFast:    true,
+			failStatus:  agent.StatusRunning,
+			wantFailing: states[:1],
+		},
+		{
+			name:              "PrimaryFailure",
+			failFast:          true,
+			primaryFailStatus: agent.StatusFinished,
+			wantFailing:       states,
+			wantErr:           true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := &failingRecordingPusher{failStatus: tt.primaryFailStatus}
+			failing := &failingRecordingPusher{failStatus: tt.failStatus}
+			healthy := &recordingPusher{}
+			p := NewFanOutPusher(tt.failFast, log.NewEntry(log.New()), primary, failing, healthy)
+			for _, s := range states {
+				p.UpdateState(s)
+			}
+			p.Shutdown()
+			// The failures of the secondary sinks never stop the states
+			// from being sent to the other sinks.
+			if diff := cmp.Diff(states, primary.states); diff != "" {
+				t.Errorf("states received by the primary sink differ, diff %s", diff)
+			}
+			if diff := cmp.Diff(tt.wantFailing, failing.states); diff != "" {
+				t.Errorf("states received by the failing sink differ, diff %s", diff)
+			}
+			if diff := cmp.Diff(states, healthy.states); diff != "" {
+				t.Errorf("states received by the healthy sink differ, diff %s", diff)
+			}
+			if err := p.Err(); (err != nil) != tt.wantErr {
+				t.Errorf("want error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestFilePusher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filepusher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint
+	path := filepath.Join(dir, "states.jsonl")
+	p, err := NewFilePusher(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []agent.State{
+		{Status: agent.StatusRunning, Progress: 0.5},
+		{Status: agent.StatusFinished, Progress: 1},
+	}
+	for _, s := range want {
+		p.UpdateState(s)
+		if err := p.Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.Shutdown()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() // nolint
+	var got []agent.State
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var s agent.State
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, s)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("archived states differ, diff %s", diff)
+	}
+}
+
+func TestCheckProgressFile(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close() // nolint
+	// The check closes the file descriptor it writes to when it finishes, so
+	// it's given a duplicate of the write end of the pipe.
+	fd, err := syscall.Dup(int(w.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close() // nolint
+
+	conf := newPushTestConfig("www.example.com")
+	conf.ProgressFile = "fd:" + strconv.Itoa(fd)
+	run := func(ctx context.Context, target string, optJSON string, s state.State) error {
+		s.SetProgress(0.5)
+		return nil
+	}
+	lines := make(chan []progressLine)
+	go func() {
+		var got []progressLine
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			var l progressLine
+			if err := json.Unmarshal(scanner.Bytes(), &l); err != nil {
+				t.Error(err)
+			}
+			got = append(got, l)
+		}
+		lines <- got
+	}()
+	runPushCheck(t, conf, run, nil)
+
+	want := []progressLine{
+		{Status: agent.StatusRunning},
+		{Status: agent.StatusRunning, Progress: 0.5},
+		{Status: agent.StatusFinished, Progress: 1},
+	}
+	if diff := cmp.Diff(want, <-lines); diff != "" {
+		t.Errorf("progress lines differ, diff %s", diff)
+	}
+}
+
+func TestCheckTeeStatesToStdout(t *testing.T) {
+	buf := &bytes.Buffer{}
+	prev := stdout
+	stdout = buf
+	defer func() { stdout = prev }()
+
+	conf := newPushTestConfig("www.example.com")
+	conf.TeeStatesToStdout = true
+	run := func(ctx context.Context, target string, optJSON string, s state.State) error {
+		s.SetProgress(0.5)
+		s.AddVulnerabilities(report.Vulnerability{Summary: "vuln"})
+		return nil
+	}
+	gotMsgs := runPushCheck(t, conf, run, nil)
+
+	var teed []agent.State
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var s agent.State
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			t.Fatal(err)
+		}
+		teed = append(teed, s)
+	}
+	if diff := cmp.Diff(gotMsgs, teed); diff != "" {
+		t.Errorf("teed states differ from the ones received by the agent, diff %s", diff)
+	}
+}
@@ -0,0 +1,126 @@
+package grpc
+
+// The code in this file is the equivalent to the one protoc-gen-go-grpc
+// generates from the agent.proto file. It's written by hand because the messages
+// of the service are protobuf well known types, so no other code is needed. It
+// only requires google.golang.org/grpc v1.32.0, the first release with
+// grpc.ServiceRegistrar, and google.golang.org/protobuf v1.25.0, both of them
+// supporting the Go version used in the CI.
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	// CheckIDMetadataKey is the metadata key of the PushStates streams that
+	// contains the ID of the check sending the states.
+	CheckIDMetadataKey = "check-id"
+
+	pushStatesMethod = "/vulcan.agent.v1.Agent/PushStates"
+)
+
+// AgentClient is the client API for the Agent service.
+type AgentClient interface {
+	PushStates(ctx context.Context, opts ...grpc.CallOption) (Agent_PushStatesClient, error)
+}
+
+type agentClient struct {
+	cc grpc.ClientConnInterface
+}
+
+// NewAgentClient returns a client for the Agent service using the given
+// connection.
+func NewAgentClient(cc grpc.ClientConnInterface) AgentClient {
+	return &agentClient{cc}
+}
+
+func (c *agentClient) PushStates(ctx context.Context, opts ...grpc.CallOption) (Agent_PushStatesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &AgentServiceDesc.Streams[0], pushStatesMethod, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &agentPushStatesClient{stream}, nil
+}
+
+// Agent_PushStatesClient is the client side of the PushStates stream.
+type Agent_PushStatesClient interface { // nolint
+	Send(*wrapperspb.BytesValue) error
+	CloseAndRecv() (*emptypb.Empty, error)
+	grpc.ClientStream
+}
+
+type agentPushStatesClient struct {
+	grpc.ClientStream
+}
+
+func (x *agentPushStatesClient) Send(m *wrapperspb.BytesValue) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *agentPushStatesClient) CloseAndRecv() (*emptypb.Empty, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(emptypb.Empty)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// AgentServer is the server API for the Agent service.
+type AgentServer interface {
+	PushStates(Agent_PushStatesServer) error
+}
+
+// RegisterAgentServer registers the given implementation of the Agent service
+// in the server.
+func RegisterAgentServer(s grpc.ServiceRegistrar, srv AgentServer) {
+	s.RegisterService(&AgentServiceDesc, srv)
+}
+
+func agentPushStatesHandler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(AgentServer).PushStates(&agentPushStatesServer{stream})
+}
+
+// Agent_PushStatesServer is the server side of the PushStates stream.
+type Agent_PushStatesServer interface { // nolint
+	SendAndClose(*emptypb.Empty) error
+	Recv() (*wrapperspb.BytesValue, error)
+	grpc.ServerStream
+}
+
+type agentPushStatesServer struct {
+	grpc.ServerStream
+}
+
+func (x *agentPushStatesServer) SendAndClose(m *emptypb.Empty) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *agentPushStatesServer) Recv() (*wrapperspb.BytesValue, error) {
+	m := new(wrapperspb.BytesValue)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// AgentServiceDesc is the grpc.ServiceDesc for the Agent service.
+var AgentServiceDesc = grpc.ServiceDesc{
+	ServiceName: "vulcan.agent.v1.Agent",
+	HandlerType: (*AgentServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "PushStates",
+			Handler:       agentPushStatesHandler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "agent.proto",
+}
@@ -0,0 +1,204 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/adevinta/vulcan-check-sdk/metrics"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// errNoClient is the error sending the messages when the connection with the
+// agent could not be created.
+var errNoClient = errors.New("no connection with the agent")
+
+const (
+	defaultPushMsgBufferLen = 10
+	backPresureMsg          = "Push queue can't handle the pressure with current size, sdk is pushing back the pressure to the check."
+)
+
+// Pusher communicates state changes to the agent by streaming them to the
+// PushStates method of its gRPC Agent service.
+type Pusher struct {
+	logger     *log.Entry
+	checkID    string
+	msgsToSend chan interface{}
+	finished   *sync.WaitGroup
+	lastErr    *pushErr
+}
+
+// Err returns the error sending the last message to the agent, if any. The
+// messages are sent in the background, so the error doesn't necessarily
+// belong to the last state queued.
+func (p *Pusher) Err() error {
+	return p.lastErr.get()
+}
+
+// Pending returns the number of messages queued and not sent yet.
+func (p *Pusher) Pending() int {
+	return len(p.msgsToSend)
+}
+
+// UpdateState queues a state to be sent to the agent. As the RestPusher, the
+// function doesn't return any error because the pusher handles the errors
+// sending the messages by its own. WARN: Calling this method after calling
+// Shutdown will cause the program to panic.
+func (p *Pusher) UpdateState(state interface{}) {
+	l := p.logger.WithField("msg", state)
+	l.Debug("Queuing message to be sent to the agent.")
+	select {
+	case p.msgsToSend <- state:
+		l.Debug("Msg queued")
+	default:
+		l.WithField("QueueSize", len(p.msgsToSend)).Warn(backPresureMsg)
+		p.msgsToSend <- state
+	}
+}
+
+// Shutdown signals the pusher to stop accepting messages and waits for the
+// pending messages to be sent and for the agent to acknowledge the end of the
+// stream.
+func (p *Pusher) Shutdown() {
+	p.logger.Debug("Shutdown")
+	close(p.msgsToSend)
+	p.finished.Wait()
+	p.logger.Debug("Shutdown end")
+}
+
+// NewPusher creates a new push component that sends the state changes of the
+// check with the given ID to the gRPC agent listening in the given address,
+// in the form host:port. A bufferLen of 0 means the default length of the
+// queue of messages pending to be sent is used.
+func NewPusher(agentAddr string, bufferLen int, checkID string, logger *log.Entry) *Pusher {
+	logger.WithFields(log.Fields{"agent_addr": agentAddr, "check_id": checkID}).Debug("Creating gRPC pusher")
+	if bufferLen == 0 {
+		bufferLen = defaultPushMsgBufferLen
+	}
+	p := &Pusher{
+		logger:     logger,
+		checkID:    checkID,
+		msgsToSend: make(chan interface{}, bufferLen),
+		finished:   &sync.WaitGroup{},
+		lastErr:    &pushErr{},
+	}
+	p.finished.Add(1)
+	// The connection is established in the background, so dialing doesn't
+	// block when the agent is not listening yet.
+	conn, err := grpc.Dial(agentAddr, grpc.WithInsecure()) // nolint
+	s := &streamer{
+		conn:    conn,
+		checkID: checkID,
+		l:       logger.WithField("subcomponent", "streamer"),
+		lastErr: p.lastErr,
+	}
+	if err != nil {
+		// The messages are consumed anyway, so the check is not blocked, but
+		// they can't be sent.
+		logger.WithError(err).Error("Error creating the gRPC client for the agent")
+	} else {
+		s.client = NewAgentClient(conn)
+	}
+	go s.run(p.msgsToSend, p.finished)
+	logger.Debug("gRPC pusher created")
+	return p
+}
+
+// streamer sends the messages to the agent using a PushStates stream. The
+// stream is opened when the first message is sent and, if sending a message
+// fails, the message is sent again once using a new stream.
+type streamer struct {
+	conn *grpc.ClientConn
+	// client is nil when the connection with the agent could not be
+	// created.
+	client  AgentClient
+	checkID string
+	l       *log.Entry
+	// lastErr stores the result of sending the last message.
+	lastErr *pushErr
+	stream  Agent_PushStatesClient
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// run sends the messages received through the channel until it is closed.
+// Then it closes the stream and the connection with the agent.
+func (s *streamer) run(msgs chan interface{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for msg := range msgs {
+		if s.client == nil {
+			metrics.Default().Increment(metrics.PushFailures, nil)
+			s.lastErr.set(errNoClient)
+			continue
+		}
+		s.l.WithField("msg", msg).Debug("Sending message")
+		err := s.send(msg)
+		if err != nil {
+			// The message would be lost otherwise, and it can be the one
+			// with the final state of the check.
+			s.l.WithError(err).Warn("Error sending message to agent, retrying with a new stream")
+			s.reset()
+			if err = s.send(msg); err != nil {
+				s.l.WithError(err).Error("Error sending message to agent")
+				metrics.Default().Increment(metrics.PushFailures, nil)
+				s.reset()
+			}
+		}
+		s.lastErr.set(err)
+	}
+	if s.stream != nil {
+		if _, err := s.stream.CloseAndRecv(); err != nil {
+			s.l.WithError(err).Error("Error closing the stream with the agent")
+		}
+		s.reset()
+	}
+	if s.conn != nil {
+		s.conn.Close() // nolint
+	}
+}
+
+func (s *streamer) send(msg interface{}) error {
+	content, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if s.stream == nil {
+		s.ctx, s.cancel = context.WithCancel(context.Background())
+		ctx := metadata.AppendToOutgoingContext(s.ctx, CheckIDMetadataKey, s.checkID)
+		s.stream, err = s.client.PushStates(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return s.stream.Send(wrapperspb.Bytes(content))
+}
+
+// pushErr stores the error sending the last message to the agent.
+type pushErr struct {
+	sync.Mutex
+	err error
+}
+
+func (e *pushErr) get() error {
+	e.Lock()
+	defer e.Unlock()
+	return e.err
+}
+
+func (e *pushErr) set(err error) {
+	e.Lock()
+	defer e.Unlock()
+	e.err = err
+}
+
+// reset discards the current stream, if any.
+func (s *streamer) reset() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.stream, s.ctx, s.cancel = nil, nil, nil
+}
@@ -0,0 +1,174 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/adevinta/vulcan-check-sdk/agent"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// mockAgent implements the gRPC Agent service storing the states received.
+type mockAgent struct {
+	mu       sync.Mutex
+	states   []agent.State
+	checkIDs []string
+}
+
+func (m *mockAgent) PushStates(stream Agent_PushStatesServer) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	m.mu.Lock()
+	m.checkIDs = append(m.checkIDs, md.Get(CheckIDMetadataKey)...)
+	m.mu.Unlock()
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&emptypb.Empty{})
+		}
+		if err != nil {
+			return err
+		}
+		var s agent.State
+		if err := json.Unmarshal(msg.GetValue(), &s); err != nil {
+			return err
+		}
+		m.mu.Lock()
+		m.states = append(m.states, s)
+		m.mu.Unlock()
+	}
+}
+
+func startMockAgent(t *testing.T) (*mockAgent, string, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	a := &mockAgent{}
+	RegisterAgentServer(srv, a)
+	go srv.Serve(ln) // nolint
+	return a, ln.Addr().String(), srv.Stop
+}
+
+func TestPusherUpdateState(t *testing.T) {
+	a, addr, stop := startMockAgent(t)
+	defer stop()
+	want := []agent.State{
+		{Status: agent.StatusRunning},
+		{Status: agent.StatusRunning, Progress: 0.5},
+		{Status: agent.StatusFinished, Progress: 1},
+	}
+	l := log.New()
+	l.Level = log.DebugLevel
+	// Use a buffer smaller than the number of messages to exercise the back
+	// pressure.
+	p := NewPusher(addr, 1, "checkID", l.WithField("test", "UpdateState"))
+	for _, s := range want {
+		p.UpdateState(s)
+	}
+	// Shutdown must wait until all the messages are received by the agent.
+	p.Shutdown()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !reflect.DeepEqual(a.states, want) {
+		t.Errorf("want states %+v, got %+v", want, a.states)
+	}
+	if !reflect.DeepEqual(a.checkIDs, []string{"checkID"}) {
+		t.Errorf("want one stream for the check checkID, got check ids %v", a.checkIDs)
+	}
+}
+
+func TestPusherAgentDown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close() // nolint
+	p := NewPusher(addr, 0, "checkID", log.NewEntry(log.New()))
+	p.UpdateState(agent.State{Status: agent.StatusRunning})
+	p.UpdateState(agent.State{Status: agent.StatusFinished})
+	// Shutdown must not block when the messages can't be sent.
+	p.Shutdown()
+}
+
+// fakeStream is a PushStates stream that stores the messages sent and fails
+// sending them when err is not nil.
+type fakeStream struct {
+	grpc.ClientStream
+	err  error
+	sent [][]byte
+}
+
+func (f *fakeStream) Send(m *wrapperspb.BytesValue) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, m.GetValue())
+	return nil
+}
+
+func (f *fakeStream) CloseAndRecv() (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
+
+// fakeAgentClient returns the given streams, one per call to PushStates.
+type fakeAgentClient struct {
+	streams []*fakeStream
+	opened  int
+}
+
+func (f *fakeAgentClient) PushStates(ctx context.Context, opts ...grpc.CallOption) (Agent_PushStatesClient, error) {
+	s := f.streams[f.opened]
+	f.opened++
+	return s, nil
+}
+
+func TestStreamerRetriesOnNewStream(t *testing.T) {
+	broken := &fakeStream{err: errors.New("stream broken")}
+	healthy := &fakeStream{}
+	client := &fakeAgentClient{streams: []*fakeStream{broken, healthy}}
+	s := &streamer{
+		client:  client,
+		checkID: "checkID",
+		l:       log.NewEntry(log.New()),
+		lastErr: &pushErr{},
+	}
+	msgs := make(chan interface{}, 2)
+	msgs <- agent.State{Status: agent.StatusRunning}
+	msgs <- agent.State{Status: agent.StatusFinished}
+	close(msgs)
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	s.run(msgs, wg)
+
+	if client.opened != 2 {
+		t.Errorf("want 2 streams opened, got %d", client.opened)
+	}
+	if err := s.lastErr.get(); err != nil {
+		t.Errorf("want no error sending the last message, got %v", err)
+	}
+	var got []string
+	for _, content := range healthy.sent {
+		var st agent.State
+		if err := json.Unmarshal(content, &st); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, st.Status)
+	}
+	want := []string{agent.StatusRunning, agent.StatusFinished}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want statuses %v sent, got %v", want, got)
+	}
+}
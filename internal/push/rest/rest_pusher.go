@@ -7,6 +7,7 @@ import (
 
 	"net/url"
 
+	"github.com/adevinta/vulcan-check-sdk/metrics"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/resty.v1"
 )
@@ -18,10 +19,26 @@ const (
 	agentURLBase            = "check"
 )
 
+const (
+	// ProtocolVersionHeader is the name of the http header used by the SDK and
+	// the agent to communicate the version of the protocol, that is, of the
+	// format of the push messages, they implement.
+	ProtocolVersionHeader = "X-Vulcan-Protocol-Version"
+	// ProtocolVersion is the version of the protocol implemented by the SDK.
+	ProtocolVersion = "1"
+)
+
 // RestPusherConfig holds the configuration needed by a RestPusher to send push notifications to the agent
 type RestPusherConfig struct {
 	AgentAddr string
-	BufferLen int
+	// FallbackAgentAddr, if not empty, defines the address of the agent the
+	// pusher switches to when sending a message to AgentAddr fails.
+	FallbackAgentAddr string
+	BufferLen         int
+	// Transport, if not nil, is used by the pusher to perform the http calls
+	// to the agent. This allows, for instance, to sign or instrument the requests.
+	// It can only be set programmatically.
+	Transport http.RoundTripper `toml:"-" json:"-"`
 }
 
 // RestPusher communicate state changes to agent by performing http calls
@@ -31,6 +48,8 @@ type RestPusher struct {
 	checkID    string
 	msgsToSend chan pusherMsg
 	finished   *sync.WaitGroup
+	version    *agentVersion
+	lastErr    *pushErr
 }
 type pusherMsg struct {
 	id  string
@@ -54,6 +73,25 @@ func (p *RestPusher) UpdateState(state interface{}) {
 	}
 }
 
+// AgentProtocolVersion returns the version of the protocol implemented by the
+// agent, as returned by it in the last response to a push message, or an empty
+// string if the agent didn't return any.
+func (p *RestPusher) AgentProtocolVersion() string {
+	return p.version.get()
+}
+
+// Err returns the error sending the last message to the agent, if any. The
+// messages are sent in the background, so the error doesn't necessarily
+// belong to the last state queued.
+func (p *RestPusher) Err() error {
+	return p.lastErr.get()
+}
+
+// Pending returns the number of messages queued and not sent yet.
+func (p *RestPusher) Pending() int {
+	return len(p.msgsToSend)
+}
+
 // Shutdown signals the pusher to stop accepting messages and wait for the pending messages to be send.
 func (p *RestPusher) Shutdown() {
 	// Closing the pusher channel forces the pusher goroutine to send pending messages
@@ -69,14 +107,14 @@ func (p *RestPusher) Shutdown() {
 // of the check by using http rest calls.
 func NewRestPusher(config RestPusherConfig, checkID string, logger *log.Entry) *RestPusher {
 	logger.WithFields(log.Fields{"config": config, checkID: checkID}).Debug("Creating NewRestPusher with params")
-	hostURL := url.URL{
-		Host:   config.AgentAddr,
-		Scheme: agentURLScheme,
-		Path:   agentURLBase,
-	}
-	logger.WithField("agent_url", hostURL.String()).Debug("Setting agent URL end point")
+	hostURL := agentURL(config.AgentAddr)
+	logger.WithField("agent_url", hostURL).Debug("Setting agent URL end point")
 	client := resty.New()
-	client.SetHostURL(hostURL.String())
+	client.SetHostURL(hostURL)
+	client.SetHeader(ProtocolVersionHeader, ProtocolVersion)
+	if config.Transport != nil {
+		client.SetTransport(config.Transport)
+	}
 	// Assign a default value to buffer len.
 	if config.BufferLen == 0 {
 		config.BufferLen = defaultPushMsgBufferLen
@@ -87,17 +125,34 @@ func NewRestPusher(config RestPusherConfig, checkID string, logger *log.Entry) *
 		msgsToSend: make(chan pusherMsg, config.BufferLen),
 		logger:     logger,
 		finished:   &sync.WaitGroup{},
+		version:    &agentVersion{},
+		lastErr:    &pushErr{},
 	}
 	// The wg only has to monitor pusher state
 	r.finished.Add(1)
-	goPusher(r.msgsToSend, client, logger.WithField("subcomponent", "gopusher"), r.finished)
+	var fallbackURL string
+	if config.FallbackAgentAddr != "" {
+		fallbackURL = agentURL(config.FallbackAgentAddr)
+	}
+	goPusher(r.msgsToSend, client, fallbackURL, r.version, r.lastErr, logger.WithField("subcomponent", "gopusher"), r.finished)
 	logger.Debug("Creating NewRestPusher created")
 	return r
 }
 
+func agentURL(addr string) string {
+	u := url.URL{
+		Host:   addr,
+		Scheme: agentURLScheme,
+		Path:   agentURLBase,
+	}
+	return u.String()
+}
+
 /* Pusher loops over buffered channel. Range only exits when the channel
-is closed. */
-func goPusher(c chan pusherMsg, client *resty.Client, l *log.Entry, wg *sync.WaitGroup) {
+is closed. If a fallback URL is defined, the pusher switches to it the first
+time sending a message fails and resends the message. The result of sending
+each message is stored in lastErr. */
+func goPusher(c chan pusherMsg, client *resty.Client, fallbackURL string, v *agentVersion, lastErr *pushErr, l *log.Entry, wg *sync.WaitGroup) {
 	go func() {
 		// NOTE: race condition found #2
 		// NOTE: race condition found #3
@@ -105,27 +160,89 @@ func goPusher(c chan pusherMsg, client *resty.Client, l *log.Entry, wg *sync.Wai
 		defer wg.Done()
 		for msg := range c {
 			l.WithField("msg", msg.msg).Debug("Sending message")
-			sendPushMsg(msg.msg, msg.id, client, l.WithField("sendPushMsg", ""))
+			err := sendPushMsg(msg.msg, msg.id, client, v, l.WithField("sendPushMsg", ""))
+			if err != nil && fallbackURL != "" {
+				l.WithField("agent_url", fallbackURL).Warn("Switching to the fallback agent")
+				client.SetHostURL(fallbackURL)
+				fallbackURL = ""
+				err = sendPushMsg(msg.msg, msg.id, client, v, l.WithField("sendPushMsg", ""))
+			}
+			lastErr.set(err)
 		}
 	}()
 }
 
-func sendPushMsg(msg interface{}, id string, c *resty.Client, l *log.Entry) {
+func sendPushMsg(msg interface{}, id string, c *resty.Client, v *agentVersion, l *log.Entry) error {
 	r := c.R()
 	r.SetBody(msg)
 	resp, err := r.Patch(id)
 	if err != nil {
 		l.WithError(err).Error("Error sending message to agent")
+		metrics.Default().Increment(metrics.PushFailures, nil)
 		retry()
-		return
+		return err
 	}
+	v.set(resp.Header().Get(ProtocolVersionHeader), l)
 	if resp.StatusCode() != http.StatusOK {
 		err = fmt.Errorf("Error while sending msg to agent, received status %s, expected 200", resp.Status())
 		l.WithError(err).Error("Error sending message to agent")
+		metrics.Default().Increment(metrics.PushFailures, nil)
 		retry()
-		return
+		return err
 	}
 	l.WithField("msg", msg).Debug("Message sent to the agent")
+	return nil
+}
+
+// pushErr stores the error sending the last message to the agent.
+type pushErr struct {
+	sync.Mutex
+	err error
+}
+
+func (e *pushErr) get() error {
+	e.Lock()
+	defer e.Unlock()
+	return e.err
+}
+
+func (e *pushErr) set(err error) {
+	e.Lock()
+	defer e.Unlock()
+	e.err = err
+}
+
+// agentVersion stores the protocol version returned by the agent.
+type agentVersion struct {
+	sync.Mutex
+	version string
+}
+
+func (a *agentVersion) get() string {
+	a.Lock()
+	defer a.Unlock()
+	return a.version
+}
+
+// set stores the given version, if not empty, and logs a warning the first
+// time the agent returns a version that doesn't match the one implemented by
+// the SDK.
+func (a *agentVersion) set(version string, l *log.Entry) {
+	if version == "" {
+		return
+	}
+	a.Lock()
+	defer a.Unlock()
+	if version == a.version {
+		return
+	}
+	a.version = version
+	if version != ProtocolVersion {
+		l.WithFields(log.Fields{
+			"sdk_protocol_version":   ProtocolVersion,
+			"agent_protocol_version": version,
+		}).Warn("Agent protocol version mismatch")
+	}
 }
 
 func retry() {
@@ -11,6 +11,7 @@ import (
 
 	"github.com/kr/pretty"
 	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
 )
 
 func buildMockAgentRestAPI(checkID string) (*httptest.Server, *[]testPushMessage) {
@@ -126,3 +127,185 @@ func sendPushMessages(messages []testPushMessage, pusher *RestPusher) {
 		pusher.UpdateState(m)
 	}
 }
+
+type recordingRoundTripper struct {
+	requests []*http.Request
+}
+
+func (r *recordingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.requests = append(r.requests, req)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestUpdateStateCustomTransport(t *testing.T) {
+	checkID := "id"
+	messages := []testPushMessage{
+		testPushMessage{
+			Status: &(&struct{ p string }{"RUNNING"}).p,
+		},
+		testPushMessage{
+			Status: &(&struct{ p string }{"FINISHED"}).p,
+		},
+	}
+	srv, gotMsgs := buildMockAgentRestAPI(checkID)
+	defer srv.Close()
+	agentAddress, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := &recordingRoundTripper{}
+	c := RestPusherConfig{
+		AgentAddr: agentAddress.Hostname() + ":" + agentAddress.Port(),
+		Transport: rt,
+	}
+	l := log.New()
+	l.Level = log.DebugLevel
+	p := NewRestPusher(c, checkID, l.WithField("test", "CustomTransport"))
+	sendPushMessages(messages, p)
+	p.Shutdown()
+	if len(rt.requests) != len(messages) {
+		t.Fatalf("want %d requests sent through the transport, got %d", len(messages), len(rt.requests))
+	}
+	for _, req := range rt.requests {
+		if req.Method != http.MethodPatch {
+			t.Errorf("want method %s, got %s", http.MethodPatch, req.Method)
+		}
+	}
+	if len(*gotMsgs) != len(messages) {
+		t.Errorf("want %d messages received by the agent, got %d", len(messages), len(*gotMsgs))
+	}
+}
+
+func TestUpdateStateFallbackAgent(t *testing.T) {
+	checkID := "id"
+	messages := []testPushMessage{
+		testPushMessage{
+			Status: &(&struct{ p string }{"RUNNING"}).p,
+		},
+		testPushMessage{
+			Progress: &(&struct{ x float32 }{0.5}).x,
+			Status:   &(&struct{ p string }{"RUNNING"}).p,
+		},
+		testPushMessage{
+			Status: &(&struct{ p string }{"FINISHED"}).p,
+		},
+	}
+	// The primary agent is closed before sending any message so it's down.
+	primary, _ := buildMockAgentRestAPI(checkID)
+	primaryAddr, err := url.Parse(primary.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary.Close()
+	fallback, gotMsgs := buildMockAgentRestAPI(checkID)
+	defer fallback.Close()
+	fallbackAddr, err := url.Parse(fallback.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := RestPusherConfig{
+		AgentAddr:         primaryAddr.Host,
+		FallbackAgentAddr: fallbackAddr.Host,
+	}
+	l := log.New()
+	l.Level = log.DebugLevel
+	p := NewRestPusher(c, checkID, l.WithField("test", "FallbackAgent"))
+	sendPushMessages(messages, p)
+	p.Shutdown()
+	if len(*gotMsgs) != len(messages) {
+		t.Fatalf("want %d messages received by the fallback agent, got %d", len(messages), len(*gotMsgs))
+	}
+	equals, want, got := comparePushMessages(*gotMsgs, messages)
+	if !equals {
+		t.Errorf("messages received by the fallback agent != sent, want %s got %s", pretty.Sprint(want), pretty.Sprint(got))
+	}
+	if err := p.Err(); err != nil {
+		t.Errorf("want no error after sending to the fallback agent, got %v", err)
+	}
+}
+
+func TestUpdateStateErr(t *testing.T) {
+	checkID := "id"
+	// The agent is closed before sending any message so it's down.
+	agent, _ := buildMockAgentRestAPI(checkID)
+	agentAddr, err := url.Parse(agent.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent.Close()
+	l := log.New()
+	l.Level = log.DebugLevel
+	p := NewRestPusher(RestPusherConfig{AgentAddr: agentAddr.Host}, checkID, l.WithField("test", "Err"))
+	sendPushMessages([]testPushMessage{{Status: &(&struct{ p string }{"RUNNING"}).p}}, p)
+	p.Shutdown()
+	if p.Err() == nil {
+		t.Error("want the error sending the message to the agent")
+	}
+}
+
+func TestUpdateStateProtocolVersion(t *testing.T) {
+	tests := []struct {
+		name         string
+		agentVersion string
+		wantWarning  bool
+	}{
+		{
+			name:         "SameVersion",
+			agentVersion: ProtocolVersion,
+		},
+		{
+			name:         "VersionMismatch",
+			agentVersion: "0",
+			wantWarning:  true,
+		},
+		{
+			name: "NoAgentVersion",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotVersions []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotVersions = append(gotVersions, r.Header.Get(ProtocolVersionHeader))
+				if tt.agentVersion != "" {
+					w.Header().Set(ProtocolVersionHeader, tt.agentVersion)
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer srv.Close()
+			agentAddress, err := url.Parse(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			l, hook := test.NewNullLogger()
+			p := NewRestPusher(RestPusherConfig{AgentAddr: agentAddress.Host}, "id", l.WithField("test", tt.name))
+			sendPushMessages([]testPushMessage{
+				testPushMessage{Status: &(&struct{ p string }{"RUNNING"}).p},
+				testPushMessage{Status: &(&struct{ p string }{"FINISHED"}).p},
+			}, p)
+			p.Shutdown()
+
+			if len(gotVersions) != 2 {
+				t.Fatalf("want 2 messages received by the agent, got %d", len(gotVersions))
+			}
+			for _, v := range gotVersions {
+				if v != ProtocolVersion {
+					t.Errorf("want protocol version header %q, got %q", ProtocolVersion, v)
+				}
+			}
+			if got := p.AgentProtocolVersion(); got != tt.agentVersion {
+				t.Errorf("want agent protocol version %q, got %q", tt.agentVersion, got)
+			}
+			warnings := 0
+			for _, e := range hook.AllEntries() {
+				if e.Level == log.WarnLevel && e.Message == "Agent protocol version mismatch" {
+					warnings++
+				}
+			}
+			// The mismatch must only be logged once.
+			if want := map[bool]int{true: 1}[tt.wantWarning]; warnings != want {
+				t.Errorf("want %d protocol version mismatch warnings, got %d", want, warnings)
+			}
+		})
+	}
+}
@@ -1,12 +1,23 @@
 package push
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
-	log "github.com/sirupsen/logrus"
 	"github.com/adevinta/vulcan-check-sdk/agent"
+	"github.com/adevinta/vulcan-check-sdk/state"
+	report "github.com/adevinta/vulcan-report"
+	log "github.com/sirupsen/logrus"
 )
 
+// minHeartbeatInterval is the minimum time between a heartbeat and the
+// previous message sent to the agent for the heartbeat to be sent.
+const minHeartbeatInterval = 5 * time.Second
+
 // StatePusher defines the shape a pusher communications component must satisfy in order to be used
 // by the PushState. This is usefull to write unit tests because makes mocking dependencies of this component easier.
 type StatePusher interface {
@@ -14,12 +25,42 @@ type StatePusher interface {
 	Shutdown()
 }
 
+// pendingPusher defines the methods of the StatePushers that report the
+// number of messages pending to be sent.
+type pendingPusher interface {
+	Pending() int
+}
+
+// ErrShutdownTimeout is returned when the messages pending to be sent to the
+// agent are not sent before the shutdown timeout expires.
+var ErrShutdownTimeout = errors.New("timeout shutting down the check")
+
 // State implements a state that uses a pusher to send state changes to an agent. This implementation is  NOT SYNCHRONIZED, that is not
 // not suitable to be be used by multiple goroutines
 type State struct {
 	pusher StatePusher
 	logger *log.Entry
 	state  agent.State
+	// maxReportBytes defines the maximum size of the serialized state sent to
+	// the agent, 0 means no limit.
+	maxReportBytes int
+	// pageSize defines the maximum number of vulnerabilities sent in each
+	// push when the report is sent in pages, 0 means the report is not paged.
+	pageSize int
+	// sentVulns is the number of vulnerabilities already sent to the agent
+	// when the report is sent in pages.
+	sentVulns int
+	// pageFailed is true when the pusher has reported an error sending a page
+	// of the report since the last full report was sent.
+	pageFailed bool
+	// explicitStatus is true when the checker has set the final status of the
+	// check.
+	explicitStatus bool
+	// checkerReturned is true once the checker has returned, so no more
+	// changes to the report are expected.
+	checkerReturned bool
+	// lastPush is the time when the last message was sent to the agent.
+	lastPush time.Time
 }
 
 // State returns current state.
@@ -48,7 +89,88 @@ func (p *State) SetEndTime(t time.Time) {
 func (p *State) SetProgress(progress float32) {
 	if p.state.Status == agent.StatusRunning && progress > p.state.Progress {
 		p.state.Progress = progress
-		p.pusher.UpdateState(p.state)
+		p.push()
+	}
+}
+
+// AddFinding sends the current state to the agent when a vulnerability has been
+// added to the report while the check is running, so the agent receives the
+// findings as they are discovered. The vulnerability is already part of the
+// report, so it's not used.
+func (p *State) AddFinding(v report.Vulnerability) {
+	if p.state.Status == agent.StatusRunning {
+		p.push()
+	}
+}
+
+// Heartbeat sends the current state to the agent, without modifying it, when
+// the check is running and no other message has been sent in the last
+// minHeartbeatInterval, so the agent knows the check is alive.
+func (p *State) Heartbeat() {
+	if p.state.Status == agent.StatusRunning && time.Since(p.lastPush) >= minHeartbeatInterval {
+		p.push()
+	}
+}
+
+// SetInconclusive sets the status of the check to inconclusive, recording the
+// reason in the notes of the report, if the check is running. The status is
+// not overridden by the sdk when the checker finishes. This method sends a
+// notification to the agent.
+func (p *State) SetInconclusive(reason string) error {
+	if err := p.explicitTransition(agent.StatusInconclusive); err != nil {
+		return err
+	}
+	if reason != "" {
+		notes := p.state.Report.Notes
+		p.state.Report.Notes = strings.TrimPrefix(notes+"\n"+"Inconclusive: "+reason, "\n")
+	}
+	p.push()
+	return nil
+}
+
+// Finish sets the status of the check to finished, if the check is running.
+// The status is not overridden by the sdk when the checker finishes. This
+// method sends a notification to the agent.
+func (p *State) Finish() error {
+	if err := p.explicitTransition(agent.StatusFinished); err != nil {
+		return err
+	}
+	p.push()
+	return nil
+}
+
+// explicitTransition sets the given final status requested by the checker,
+// if the check is running, without sending it to the agent.
+func (p *State) explicitTransition(status string) error {
+	if p.state.Status != agent.StatusRunning {
+		return fmt.Errorf("%w: from %q to %q", state.ErrInvalidStatusTransition, p.state.Status, status)
+	}
+	p.state.Status = status
+	p.state.Progress = 1.0
+	p.state.Report.Status = status
+	p.explicitStatus = true
+	return nil
+}
+
+// ResetStatus discards the final status set by the checker, if any, and sets
+// the status of the check to running again, so the checker can be retried.
+// This method sends a notification to the agent if the status is reset.
+func (p *State) ResetStatus() {
+	if !p.explicitStatus {
+		return
+	}
+	p.explicitStatus = false
+	p.SetStatusRunning()
+}
+
+// PushExplicitStatus sends the current state to the agent, once the checker
+// has returned, when the checker has set the final status of the check, so the
+// agent receives the end time of the check and the vulnerabilities added to the
+// report after setting the status. The status set by the checker is kept.
+func (p *State) PushExplicitStatus() {
+	p.checkerReturned = true
+	if p.explicitStatus {
+		p.push()
 	}
 }
 
@@ -57,7 +179,7 @@ func (p *State) SetStatusRunning() {
 	p.state.Status = agent.StatusRunning
 	p.state.Progress = 0.0
 	p.state.Report.Status = string(agent.StatusRunning)
-	p.pusher.UpdateState(p.state)
+	p.push()
 }
 
 // SetStatusAborted sets the state of the current check to Running and the progress to 1.0.
@@ -66,7 +188,7 @@ func (p *State) SetStatusAborted() {
 	p.state.Status = agent.StatusAborted
 	p.state.Progress = 1.0
 	p.state.Report.Status = agent.StatusAborted
-	p.pusher.UpdateState(p.state)
+	p.push()
 }
 
 // SetStatusFinished sets the state of the current check to Running and the progress to 1.0.
@@ -75,7 +197,7 @@ func (p *State) SetStatusFinished() {
 	p.state.Status = agent.StatusFinished
 	p.state.Progress = 1.0
 	p.state.Report.Status = agent.StatusFinished
-	p.pusher.UpdateState(p.state)
+	p.push()
 
 }
 
@@ -86,7 +208,111 @@ func (p *State) SetStatusFailed(err error) {
 	p.state.Progress = 1.0
 	p.state.Report.Error = err.Error()
 	p.state.Report.Status = agent.StatusFailed
-	p.pusher.UpdateState(p.state)
+	p.push()
+}
+
+// push sends the current state to the agent, truncating the vulnerabilities of
+// the report if the state exceeds the maximum size allowed. The report is sent
+// as it is, the secrets are only masked in the logs.
+func (p *State) push() {
+	s := p.state
+	if p.pageSize > 0 {
+		p.pushPages(s)
+	} else {
+		p.pusher.UpdateState(p.limitSize(s))
+	}
+	p.lastPush = time.Now()
+}
+
+// pushPages sends the vulnerabilities of the report added since the previous
+// push in pages of, at most, pageSize vulnerabilities. If there are no new
+// vulnerabilities a page without them is sent, so the agent still receives
+// the rest of the state. The maximum size of the report is not enforced in
+// paged mode. The pages are sent on a best-effort basis: a page that can't be
+// sent is not retried, but, if the pusher reports the error through an Err
+// method, the final push sends the whole report again starting at offset 0.
+// Errors sending the pages still queued when the final push is made are not
+// detected.
+func (p *State) pushPages(s agent.State) {
+	vulns := s.Report.Vulnerabilities
+	if p.sentVulns > len(vulns) {
+		p.sentVulns = len(vulns)
+	}
+	// The final status set by the checker is sent before the checker returns,
+	// so the report is only complete in the push made after it returns.
+	final := (s.Status == agent.StatusFinished || s.Status == agent.StatusFailed || s.Status == agent.StatusAborted ||
+		s.Status == agent.StatusInconclusive) && (!p.explicitStatus || p.checkerReturned)
+	if final && p.pageFailed {
+		p.logger.Warn("Sending the whole report again because sending a page of it failed")
+		p.sentVulns = 0
+		p.pageFailed = false
+	}
+	pending := vulns[p.sentVulns:]
+	for {
+		n := len(pending)
+		if n > p.pageSize {
+			n = p.pageSize
+		}
+		more := n < len(pending)
+		page := s
+		page.Report.Vulnerabilities = pending[:n]
+		page.Page = &agent.Page{
+			Offset:   p.sentVulns,
+			More:     more,
+			Complete: final && !more,
+		}
+		p.pusher.UpdateState(page)
+		if pusherErr(p.pusher) != nil {
+			p.pageFailed = true
+		}
+		p.sentVulns += n
+		pending = pending[n:]
+		if !more {
+			return
+		}
+	}
+}
+
+// limitSize returns the given state if its serialized size is less or equal
+// than the maximum configured. Otherwise it returns a copy of the state keeping
+// only the vulnerabilities with the highest severity that fit, and a note
+// explaining the truncation.
+func (p *State) limitSize(s agent.State) agent.State {
+	if p.maxReportBytes <= 0 || stateSize(s) <= p.maxReportBytes {
+		return s
+	}
+	vulns := make([]report.Vulnerability, len(s.Report.Vulnerabilities))
+	copy(vulns, s.Report.Vulnerabilities)
+	sort.SliceStable(vulns, func(i, j int) bool {
+		return vulns[i].Score > vulns[j].Score
+	})
+	notes := s.Report.Notes
+	truncated := func(n int) agent.State {
+		t := s
+		t.Report.Vulnerabilities = vulns[:n]
+		note := fmt.Sprintf("The report has been truncated to fit the maximum size accepted by the agent: %d of %d vulnerabilities, the ones with lower severity, were removed.", len(vulns)-n, len(vulns))
+		t.Report.Notes = strings.TrimPrefix(notes+"\n"+note, "\n")
+		return t
+	}
+	// Find the maximum number of vulnerabilities that fit.
+	n := sort.Search(len(vulns)+1, func(i int) bool {
+		return stateSize(truncated(i)) > p.maxReportBytes
+	}) - 1
+	if n < 0 {
+		n = 0
+		p.logger.WithField("max_report_bytes", p.maxReportBytes).Warn("The report exceeds the maximum size even without vulnerabilities")
+	}
+	p.logger.WithFields(log.Fields{"max_report_bytes": p.maxReportBytes, "removed": len(vulns) - n}).Warn("Truncating the vulnerabilities of the report")
+	return truncated(n)
+}
+
+func stateSize(s agent.State) int {
+	content, err := json.Marshal(s)
+	if err != nil {
+		// If the state can not be serialized its size can not be limited.
+		return 0
+	}
+	return len(content)
 }
 
 // Shutdown the state gracefully.
@@ -96,11 +322,13 @@ func (p *State) Shutdown() error {
 }
 
 // newState creates a new synchronized State.
-func newState(s agent.State, p StatePusher, logger *log.Entry) *State {
+func newState(s agent.State, p StatePusher, logger *log.Entry, maxReportBytes, pageSize int) *State {
 	state := &State{
-		state:  s,
-		pusher: p,
-		logger: logger,
+		state:          s,
+		pusher:         p,
+		logger:         logger,
+		maxReportBytes: maxReportBytes,
+		pageSize:       pageSize,
 	}
 	return state
 }
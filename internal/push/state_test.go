@@ -0,0 +1,328 @@
+package push
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adevinta/vulcan-check-sdk/agent"
+	"github.com/adevinta/vulcan-check-sdk/state"
+	report "github.com/adevinta/vulcan-report"
+	log "github.com/sirupsen/logrus"
+)
+
+type recordingPusher struct {
+	states []agent.State
+}
+
+func (r *recordingPusher) UpdateState(state interface{}) {
+	r.states = append(r.states, state.(agent.State))
+}
+
+func (r *recordingPusher) Shutdown() {}
+
+func TestStateMaxReportBytes(t *testing.T) {
+	vulns := make([]report.Vulnerability, 0, 50)
+	for i := 0; i < 50; i++ {
+		vulns = append(vulns, report.Vulnerability{
+			Summary:     fmt.Sprintf("vuln %d", i),
+			Score:       float32(i % 10),
+			Description: strings.Repeat("a", 100),
+		})
+	}
+	unlimited := agent.State{}
+	unlimited.Report.Vulnerabilities = vulns
+	fullSize := stateSize(unlimited)
+
+	tests := []struct {
+		name           string
+		maxReportBytes int
+		wantTruncated  bool
+	}{
+		{
+			name:           "NoLimit",
+			maxReportBytes: 0,
+		},
+		{
+			name:           "UnderLimit",
+			maxReportBytes: fullSize * 2,
+		},
+		{
+			name:           "OverLimit",
+			maxReportBytes: fullSize / 3,
+			wantTruncated:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &recordingPusher{}
+			s := newState(agent.State{}, p, log.NewEntry(log.New()), tt.maxReportBytes, 0)
+			s.state.Report.Vulnerabilities = append([]report.Vulnerability{}, vulns...)
+			s.SetStatusFinished()
+
+			if len(p.states) != 1 {
+				t.Fatalf("got %d pushed states, want 1", len(p.states))
+			}
+			got := p.states[0]
+			if len(s.state.Report.Vulnerabilities) != len(vulns) {
+				t.Errorf("the vulnerabilities of the state were modified")
+			}
+			if !tt.wantTruncated {
+				if len(got.Report.Vulnerabilities) != len(vulns) {
+					t.Errorf("got %d vulnerabilities, want %d", len(got.Report.Vulnerabilities), len(vulns))
+				}
+				return
+			}
+			content, err := json.Marshal(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(content) > tt.maxReportBytes {
+				t.Errorf("got state size %d, want less than %d", len(content), tt.maxReportBytes)
+			}
+			n := len(got.Report.Vulnerabilities)
+			if n == 0 || n == len(vulns) {
+				t.Fatalf("got %d vulnerabilities, want a truncated report", n)
+			}
+			for _, v := range got.Report.Vulnerabilities {
+				if v.Score < got.Report.Vulnerabilities[n-1].Score {
+					t.Errorf("vulnerabilities not sorted by score")
+				}
+			}
+			// The vulnerabilities kept must have equal or higher score than any
+			// of the removed ones.
+			kept := map[string]bool{}
+			for _, v := range got.Report.Vulnerabilities {
+				kept[v.Summary] = true
+			}
+			lowest := got.Report.Vulnerabilities[n-1].Score
+			for _, v := range vulns {
+				if !kept[v.Summary] && v.Score > lowest {
+					t.Errorf("vulnerability %q with score %v removed while keeping score %v", v.Summary, v.Score, lowest)
+				}
+			}
+			want := fmt.Sprintf("%d of %d vulnerabilities", len(vulns)-n, len(vulns))
+			if !strings.Contains(got.Report.Notes, want) {
+				t.Errorf("got notes %q, want them to contain %q", got.Report.Notes, want)
+			}
+		})
+	}
+}
+
+func TestStateFindingSink(t *testing.T) {
+	p := &recordingPusher{}
+	s := newState(agent.State{}, p, log.NewEntry(log.New()), 0, 0)
+	s.SetStatusRunning()
+	checkState := state.State{
+		ResultData:       &s.state.Report.ResultData,
+		ProgressReporter: s,
+		FindingSink:      s,
+	}
+	checkState.AddVulnerabilities(report.Vulnerability{Summary: "vuln 1"})
+	checkState.AddVulnerabilities(report.Vulnerability{Summary: "vuln 2"}, report.Vulnerability{Summary: "vuln 3"})
+
+	// One push for the running status and one for each vulnerability.
+	if len(p.states) != 4 {
+		t.Fatalf("got %d pushed states, want 4", len(p.states))
+	}
+	for i, want := range []int{1, 3, 3} {
+		if got := len(p.states[i+1].Report.Vulnerabilities); got != want {
+			t.Errorf("got %d vulnerabilities in push %d, want %d", got, i+1, want)
+		}
+	}
+	if len(s.state.Report.Vulnerabilities) != 3 {
+		t.Errorf("got %d vulnerabilities in the report, want 3", len(s.state.Report.Vulnerabilities))
+	}
+}
+
+func TestStateReportNotRedacted(t *testing.T) {
+	p := &recordingPusher{}
+	s := newState(agent.State{}, p, log.NewEntry(log.New()), 0, 0)
+	data := `{"password_min_length":8,"token_count":2}`
+	s.state.Report.Data = []byte(data)
+	s.SetStatusFinished()
+	if len(p.states) != 1 {
+		t.Fatalf("got %d pushed states, want 1", len(p.states))
+	}
+	if got := string(p.states[0].Report.Data); got != data {
+		t.Errorf("got report data %s, want %s", got, data)
+	}
+}
+
+func TestStateHeartbeat(t *testing.T) {
+	p := &recordingPusher{}
+	s := newState(agent.State{}, p, log.NewEntry(log.New()), 0, 0)
+	checkState := state.State{
+		ResultData:       &s.state.Report.ResultData,
+		ProgressReporter: s,
+	}
+	// Heartbeats are only sent while the check is running.
+	checkState.Heartbeat()
+	if len(p.states) != 0 {
+		t.Fatalf("got %d pushed states before running, want 0", len(p.states))
+	}
+	s.SetStatusRunning()
+	checkState.SetProgress(0.5)
+	checkState.SetProgress(0.5)
+	if len(p.states) != 2 {
+		t.Fatalf("got %d pushed states, want 2", len(p.states))
+	}
+
+	// A heartbeat just after a push is not sent.
+	checkState.Heartbeat()
+	if len(p.states) != 2 {
+		t.Fatalf("got %d pushed states after a rate limited heartbeat, want 2", len(p.states))
+	}
+
+	s.lastPush = time.Now().Add(-minHeartbeatInterval)
+	checkState.Heartbeat()
+	if len(p.states) != 3 {
+		t.Fatalf("got %d pushed states after a heartbeat, want 3", len(p.states))
+	}
+	got := p.states[2]
+	if got.Status != agent.StatusRunning || got.Progress != 0.5 {
+		t.Errorf("got status %s and progress %v, want %s and 0.5", got.Status, got.Progress, agent.StatusRunning)
+	}
+}
+
+func TestStatePages(t *testing.T) {
+	p := &recordingPusher{}
+	s := newState(agent.State{}, p, log.NewEntry(log.New()), 0, 10)
+	checkState := state.State{
+		ResultData:       &s.state.Report.ResultData,
+		ProgressReporter: s,
+	}
+	var vulns []report.Vulnerability
+	for i := 0; i < 25; i++ {
+		vulns = append(vulns, report.Vulnerability{Summary: fmt.Sprintf("vuln %d", i)})
+	}
+	s.SetStatusRunning()
+	checkState.AddVulnerabilities(vulns[:3]...)
+	checkState.SetProgress(0.5)
+	checkState.AddVulnerabilities(vulns[3:]...)
+	s.SetStatusFinished()
+
+	// One page for the running status, one for the progress and three for the
+	// 22 vulnerabilities added before finishing.
+	if len(p.states) != 5 {
+		t.Fatalf("got %d pushed states, want 5", len(p.states))
+	}
+	var got []report.Vulnerability
+	for i, st := range p.states {
+		if st.Page == nil {
+			t.Fatalf("push %d without page", i)
+		}
+		if st.Page.Offset != len(got) {
+			t.Errorf("got offset %d in push %d, want %d", st.Page.Offset, i, len(got))
+		}
+		if len(st.Report.Vulnerabilities) > 10 {
+			t.Errorf("got %d vulnerabilities in push %d, want at most 10", len(st.Report.Vulnerabilities), i)
+		}
+		last := i == len(p.states)-1
+		if st.Page.Complete != last {
+			t.Errorf("got complete %v in push %d, want %v", st.Page.Complete, i, last)
+		}
+		wantMore := i == 2 || i == 3
+		if st.Page.More != wantMore {
+			t.Errorf("got more %v in push %d, want %v", st.Page.More, i, wantMore)
+		}
+		got = append(got, st.Report.Vulnerabilities...)
+	}
+	if len(got) != len(vulns) {
+		t.Fatalf("got %d reassembled vulnerabilities, want %d", len(got), len(vulns))
+	}
+	for i := range vulns {
+		if got[i].Summary != vulns[i].Summary {
+			t.Errorf("got vulnerability %q at position %d, want %q", got[i].Summary, i, vulns[i].Summary)
+		}
+	}
+}
+
+// pageFailingPusher is a recordingPusher that reports an error sending the
+// state pushed in the given position.
+type pageFailingPusher struct {
+	recordingPusher
+	fail int
+}
+
+func (f *pageFailingPusher) Err() error {
+	if len(f.states)-1 == f.fail {
+		return errors.New("page lost")
+	}
+	return nil
+}
+
+func TestStatePagesResentAfterFailure(t *testing.T) {
+	p := &pageFailingPusher{fail: 1}
+	s := newState(agent.State{}, p, log.NewEntry(log.New()), 0, 10)
+	checkState := state.State{
+		ResultData:       &s.state.Report.ResultData,
+		ProgressReporter: s,
+	}
+	s.SetStatusRunning()
+	checkState.AddVulnerabilities(report.Vulnerability{Summary: "vuln 0"}, report.Vulnerability{Summary: "vuln 1"})
+	checkState.SetProgress(0.5)
+	checkState.AddVulnerabilities(report.Vulnerability{Summary: "vuln 2"})
+	s.SetStatusFinished()
+
+	if len(p.states) != 3 {
+		t.Fatalf("got %d pushed states, want 3", len(p.states))
+	}
+	last := p.states[2]
+	if last.Page.Offset != 0 || !last.Page.Complete {
+		t.Errorf("got offset %d and complete %v in the final push, want 0 and true", last.Page.Offset, last.Page.Complete)
+	}
+	if n := len(last.Report.Vulnerabilities); n != 3 {
+		t.Errorf("got %d vulnerabilities in the final push, want the whole report with 3", n)
+	}
+}
+
+func TestStateStatusTransitions(t *testing.T) {
+	p := &recordingPusher{}
+	s := newState(agent.State{}, p, log.NewEntry(log.New()), 0, 0)
+	checkState := state.State{
+		ResultData:       &s.state.Report.ResultData,
+		ProgressReporter: s,
+	}
+	if err := checkState.Finish(); !errors.Is(err, state.ErrInvalidStatusTransition) {
+		t.Errorf("got error %v finishing before running, want %v", err, state.ErrInvalidStatusTransition)
+	}
+	s.SetStatusRunning()
+	if err := checkState.SetInconclusive("target not reachable"); err != nil {
+		t.Fatalf("got error %v setting inconclusive while running", err)
+	}
+	if err := checkState.Finish(); !errors.Is(err, state.ErrInvalidStatusTransition) {
+		t.Errorf("got error %v finishing an inconclusive check, want %v", err, state.ErrInvalidStatusTransition)
+	}
+	if err := checkState.SetInconclusive("again"); !errors.Is(err, state.ErrInvalidStatusTransition) {
+		t.Errorf("got error %v setting inconclusive twice, want %v", err, state.ErrInvalidStatusTransition)
+	}
+	if len(p.states) != 2 {
+		t.Fatalf("got %d pushed states, want 2", len(p.states))
+	}
+	got := p.states[1]
+	if got.Status != agent.StatusInconclusive || got.Report.Status != agent.StatusInconclusive {
+		t.Errorf("got status %s, want %s", got.Status, agent.StatusInconclusive)
+	}
+	if got.Report.Notes != "Inconclusive: target not reachable" {
+		t.Errorf("got notes %q", got.Report.Notes)
+	}
+
+	s = newState(agent.State{}, p, log.NewEntry(log.New()), 0, 0)
+	checkState.ProgressReporter = s
+	s.SetStatusRunning()
+	if err := checkState.Finish(); err != nil {
+		t.Errorf("got error %v finishing while running", err)
+	}
+	if s.state.Status != agent.StatusFinished {
+		t.Errorf("got status %s, want %s", s.state.Status, agent.StatusFinished)
+	}
+
+	// The status can not be set when the sdk doesn't support it.
+	if err := (state.State{}).Finish(); err != state.ErrStatusControlNotSupported {
+		t.Errorf("got error %v, want %v", err, state.ErrStatusControlNotSupported)
+	}
+}
@@ -0,0 +1,114 @@
+package testagent
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/adevinta/vulcan-check-sdk/agent"
+	"github.com/adevinta/vulcan-check-sdk/config"
+	"github.com/adevinta/vulcan-check-sdk/internal/push/rest"
+)
+
+// Reporter represents a "fake" agent suitable to be used in tests.
+type Reporter struct {
+	srv  *httptest.Server
+	URL  string
+	Msgs chan agent.State
+
+	mu      sync.Mutex
+	version string
+}
+
+// ProtocolVersion returns the protocol version sent by the check in the last
+// message received.
+func (r *Reporter) ProtocolVersion() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.version
+}
+
+func (r *Reporter) setProtocolVersion(version string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.version = version
+}
+
+// Stop the underlaying HTTPServer and closes the channel used to receive messages.
+func (r *Reporter) Stop() {
+	r.srv.Close()
+	// The call above only returns when all pending requests are processed, thus is safe to close the channel.
+	close(r.Msgs)
+
+}
+
+// NewReporter creates a minimal http server that receives and sends to a channel the messages received
+// by a check with a given checkID. Should be only used for test pourposes.
+func NewReporter(checkID string) *Reporter {
+	c := make(chan agent.State, 10)
+	r := &Reporter{
+		Msgs: c,
+	}
+	r.srv = buildHTTPServer(checkID, c, r.setProtocolVersion)
+	agentAddress, _ := url.Parse(r.srv.URL) //nolint
+	r.URL = agentAddress.Hostname() + ":" + agentAddress.Port()
+	return r
+}
+
+// buildHTTPServer creates the http server of the reporter. The server records,
+// using the setVersion function, and echoes back the protocol version received
+// in each message.
+func buildHTTPServer(checkID string, msgs chan<- agent.State, setVersion func(string)) (s *httptest.Server) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version := r.Header.Get(rest.ProtocolVersionHeader)
+		if version != "" {
+			w.Header().Set(rest.ProtocolVersionHeader, version)
+		}
+		// Check the the id if the check is present.
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) < 1 {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if parts[len(parts)-1] != checkID {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		decoder := json.NewDecoder(r.Body)
+		msg := agent.State{}
+		err := decoder.Decode(&msg)
+		if err != nil {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+		setVersion(version)
+		msgs <- msg
+		w.WriteHeader(http.StatusOK)
+	})
+	return httptest.NewServer(h)
+}
+
+// Record starts a reporter for the check defined in the given config and
+// points the config to it. The returned function stops the reporter and
+// returns the messages it received.
+func Record(conf *config.Config) func() []agent.State {
+	r := NewReporter(conf.Check.CheckID)
+	conf.Push.AgentAddr = r.URL
+	conf.Push.BufferLen = 10
+	var msgs []agent.State
+	received := make(chan struct{})
+	go func() {
+		for msg := range r.Msgs {
+			msgs = append(msgs, msg)
+		}
+		close(received)
+	}()
+	return func() []agent.State {
+		r.Stop()
+		<-received
+		return msgs
+	}
+}
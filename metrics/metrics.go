@@ -0,0 +1,62 @@
+// Package metrics defines the hooks the sdk calls to record metrics about the
+// execution of a check. By default the metrics are discarded, embedders can
+// plug their own collector by calling Register.
+package metrics
+
+import "sync"
+
+const (
+	// CheckDuration is the name of the observation recording the time, in
+	// seconds, it took to execute a checker.
+	CheckDuration = "check_duration_seconds"
+	// CheckStatus is the name of the counter incremented each time a check
+	// finishes, labeled with the final status of the check.
+	CheckStatus = "check_status_total"
+	// VulnerabilitiesEmitted is the name of the observation recording the
+	// number of vulnerabilities reported by a check.
+	VulnerabilitiesEmitted = "check_vulnerabilities"
+	// PushFailures is the name of the counter incremented each time a message
+	// can not be sent to the agent.
+	PushFailures = "push_failures_total"
+)
+
+// Metrics defines the shape a metrics collector must have in order to be used
+// by the sdk. Implementations must be safe to be used from multiple goroutines.
+type Metrics interface {
+	// Increment increments by one the counter with the given name and labels.
+	Increment(name string, labels map[string]string)
+	// Observe records a value for the observation with the given name and labels.
+	Observe(name string, value float64, labels map[string]string)
+}
+
+// Noop implements a Metrics collector that discards all the metrics.
+type Noop struct{}
+
+// Increment does nothing.
+func (Noop) Increment(name string, labels map[string]string) {}
+
+// Observe does nothing.
+func (Noop) Observe(name string, value float64, labels map[string]string) {}
+
+var (
+	mu      sync.RWMutex
+	current Metrics = Noop{}
+)
+
+// Register sets the collector used by the sdk to record metrics. Passing nil
+// restores the default collector that discards the metrics.
+func Register(m Metrics) {
+	if m == nil {
+		m = Noop{}
+	}
+	mu.Lock()
+	current = m
+	mu.Unlock()
+}
+
+// Default returns the collector currently registered.
+func Default() Metrics {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
@@ -0,0 +1,76 @@
+// Package prometheus provides an adapter to record the metrics of the sdk
+// using the Prometheus client.
+package prometheus
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Adapter implements the metrics.Metrics interface by mapping the counters to
+// Prometheus counters and the observations to Prometheus histograms. The
+// collectors are created and registered the first time a metric is recorded,
+// so all the calls for a given metric must have the same set of label names.
+type Adapter struct {
+	namespace  string
+	registerer prometheus.Registerer
+	mu         sync.Mutex
+	counters   map[string]*prometheus.CounterVec
+	histograms map[string]*prometheus.HistogramVec
+}
+
+// New creates an adapter that registers the collectors in the given
+// registerer using the given namespace.
+func New(registerer prometheus.Registerer, namespace string) *Adapter {
+	return &Adapter{
+		namespace:  namespace,
+		registerer: registerer,
+		counters:   map[string]*prometheus.CounterVec{},
+		histograms: map[string]*prometheus.HistogramVec{},
+	}
+}
+
+// Increment increments by one the Prometheus counter with the given name.
+func (a *Adapter) Increment(name string, labels map[string]string) {
+	a.mu.Lock()
+	c, ok := a.counters[name]
+	if !ok {
+		c = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: a.namespace,
+			Name:      name,
+			Help:      "Vulcan check sdk counter " + name,
+		}, labelNames(labels))
+		a.registerer.MustRegister(c)
+		a.counters[name] = c
+	}
+	a.mu.Unlock()
+	c.With(labels).Inc()
+}
+
+// Observe records a value in the Prometheus histogram with the given name.
+func (a *Adapter) Observe(name string, value float64, labels map[string]string) {
+	a.mu.Lock()
+	h, ok := a.histograms[name]
+	if !ok {
+		h = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: a.namespace,
+			Name:      name,
+			Help:      "Vulcan check sdk histogram " + name,
+		}, labelNames(labels))
+		a.registerer.MustRegister(h)
+		a.histograms[name] = h
+	}
+	a.mu.Unlock()
+	h.With(labels).Observe(value)
+}
+
+func labelNames(labels map[string]string) []string {
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
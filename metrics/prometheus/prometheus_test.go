@@ -0,0 +1,29 @@
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestAdapter(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	a := New(reg, "vulcan")
+	labels := map[string]string{"status": "FINISHED"}
+	a.Increment("check_status_total", labels)
+	a.Increment("check_status_total", labels)
+	a.Observe("check_duration_seconds", 1.5, nil)
+
+	got := testutil.ToFloat64(a.counters["check_status_total"].With(labels))
+	if got != 2 {
+		t.Errorf("want counter value 2, got %v", got)
+	}
+	n, err := testutil.GatherAndCount(reg, "vulcan_check_duration_seconds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("want 1 histogram registered, got %d", n)
+	}
+}
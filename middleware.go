@@ -0,0 +1,222 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/adevinta/vulcan-check-sdk/state"
+	report "github.com/adevinta/vulcan-report"
+	log "github.com/sirupsen/logrus"
+)
+
+// Middleware defines a function that wraps a Checker in order to add behavior
+// around its Run and CleanUp methods, e.g.: timing, logging or recovery. A
+// middleware can short-circuit the execution by not calling the wrapped
+// checker.
+type Middleware func(next Checker) Checker
+
+// WithMiddleware returns a Checker that wraps the given checker with the
+// middlewares. The first middleware is the outermost one, that is, the first
+// executed.
+func WithMiddleware(checker Checker, middlewares ...Middleware) Checker {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		checker = middlewares[i](checker)
+	}
+	return checker
+}
+
+// RunMiddleware returns a Middleware that only wraps the Run method of the
+// checkers using the given function. The CleanUp method of the wrapped checker
+// is called untouched.
+func RunMiddleware(wrap func(next CheckerHandleRun) CheckerHandleRun) Middleware {
+	return func(next Checker) Checker {
+		return struct {
+			CheckerHandleRun
+			CheckerHandleCleanUp
+		}{
+			wrap(next.Run),
+			next.CleanUp,
+		}
+	}
+}
+
+// Recovery returns a middleware that recovers from the panics raised by the
+// Run method of the checker and returns them as errors, so the check finishes
+// with a failed status instead of crashing.
+func Recovery() Middleware {
+	return RunMiddleware(func(next CheckerHandleRun) CheckerHandleRun {
+		return func(ctx context.Context, target string, opts string, s state.State) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("checker panicked: %v", r)
+				}
+			}()
+			return next(ctx, target, opts, s)
+		}
+	})
+}
+
+// Timing returns a middleware that logs, using the given logger, the time
+// taken by the Run method of the checker.
+func Timing(l *log.Entry) Middleware {
+	return RunMiddleware(func(next CheckerHandleRun) CheckerHandleRun {
+		return func(ctx context.Context, target string, opts string, s state.State) error {
+			start := time.Now()
+			err := next(ctx, target, opts, s)
+			l.WithFields(log.Fields{
+				"target":   target,
+				"duration": time.Since(start).String(),
+			}).Info("Checker run finished")
+			return err
+		}
+	})
+}
+
+// TempDir returns a middleware that creates a temporary directory before each
+// run of the checker, e.g.: to store the output files of the processes it
+// runs, and removes it, with all its contents, after the CleanUp method of
+// the checker returns. The checker gets the path of the directory using
+// state.TempDir with the context passed to its Run and CleanUp methods.
+func TempDir() Middleware {
+	return func(next Checker) Checker {
+		var mu sync.Mutex
+		// dirs contains the directory of each run by target, as the checker
+		// can be run concurrently against several targets.
+		dirs := map[string]string{}
+		run := func(ctx context.Context, target string, opts string, s state.State) error {
+			dir, err := ioutil.TempDir("", "vulcan-check")
+			if err != nil {
+				return fmt.Errorf("can not create temp dir: %w", err)
+			}
+			mu.Lock()
+			dirs[target] = dir
+			mu.Unlock()
+			return next.Run(state.ContextWithTempDir(ctx, dir), target, opts, s)
+		}
+		cleanUp := func(ctx context.Context, target string, opts string) {
+			mu.Lock()
+			dir, ok := dirs[target]
+			delete(dirs, target)
+			mu.Unlock()
+			if !ok {
+				next.CleanUp(ctx, target, opts)
+				return
+			}
+			defer os.RemoveAll(dir) // nolint
+			next.CleanUp(state.ContextWithTempDir(ctx, dir), target, opts)
+		}
+		return struct {
+			CheckerHandleRun
+			CheckerHandleCleanUp
+		}{
+			run,
+			cleanUp,
+		}
+	}
+}
+
+// RetryableError wraps the errors returned by the checkers that are transient,
+// so the Run method can succeed if it is re-invoked.
+type RetryableError struct {
+	Err error
+}
+
+func (e *RetryableError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *RetryableError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable returns the given error wrapped in a RetryableError.
+func Retryable(err error) error {
+	return &RetryableError{Err: err}
+}
+
+// RetryPolicy defines when the Run method of a checker is re-invoked after
+// failing.
+type RetryPolicy struct {
+	// MaxRetries is the maximum number of times Run is re-invoked.
+	MaxRetries int
+	// IsRetryable returns true if the given error, returned by Run, is
+	// transient. When nil only the errors wrapping a RetryableError are
+	// considered transient.
+	IsRetryable func(err error) bool
+	// Backoff is the time waited before re-invoking Run.
+	Backoff time.Duration
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if p.IsRetryable != nil {
+		return p.IsRetryable(err)
+	}
+	var retryable *RetryableError
+	return errors.As(err, &retryable)
+}
+
+// Retry returns a middleware that re-invokes the Run method of the checker,
+// up to the maximum number of retries of the policy, when it returns a
+// retryable error. Before each retry the CleanUp method of the checker is
+// called, the result data of the report is restored to the one it had before
+// the attempt and the final status set by the checker in the attempt, if any,
+// is discarded. The attempts are not retried when the context is done or when
+// the checker has sent findings to the FindingSink of the state, because they
+// have already been streamed and can't be discarded.
+func Retry(p RetryPolicy) Middleware {
+	return func(next Checker) Checker {
+		run := func(ctx context.Context, target string, opts string, s state.State) error {
+			for attempt := 0; ; attempt++ {
+				var snapshot report.ResultData
+				if s.ResultData != nil {
+					snapshot = copyResultData(*s.ResultData)
+				}
+				attemptState := s
+				var streamed bool
+				if s.FindingSink != nil {
+					attemptState.FindingSink = state.FindingSinkHandler(func(v report.Vulnerability) {
+						streamed = true
+						s.FindingSink.AddFinding(v)
+					})
+				}
+				err := next.Run(ctx, target, opts, attemptState)
+				if err == nil || attempt >= p.MaxRetries || !p.retryable(err) || ctx.Err() != nil || streamed {
+					return err
+				}
+				next.CleanUp(ctx, target, opts)
+				if s.ResultData != nil {
+					*s.ResultData = snapshot
+				}
+				if r, ok := s.ProgressReporter.(state.StatusResetter); ok {
+					r.ResetStatus()
+				}
+				select {
+				case <-time.After(p.Backoff):
+				case <-ctx.Done():
+					return err
+				}
+			}
+		}
+		return struct {
+			CheckerHandleRun
+			CheckerHandleCleanUp
+		}{
+			run,
+			next.CleanUp,
+		}
+	}
+}
+
+// copyResultData returns a copy of the given result data that doesn't share
+// the list of vulnerabilities nor the data with it.
+func copyResultData(r report.ResultData) report.ResultData {
+	r.Vulnerabilities = append([]report.Vulnerability(nil), r.Vulnerabilities...)
+	r.Data = append([]byte(nil), r.Data...)
+	return r
+}
@@ -0,0 +1,291 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/adevinta/vulcan-check-sdk/agent"
+	"github.com/adevinta/vulcan-check-sdk/config"
+	"github.com/adevinta/vulcan-check-sdk/internal/logging"
+	"github.com/adevinta/vulcan-check-sdk/internal/push"
+	"github.com/adevinta/vulcan-check-sdk/internal/testagent"
+	"github.com/adevinta/vulcan-check-sdk/state"
+	report "github.com/adevinta/vulcan-report"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWithMiddleware(t *testing.T) {
+	errShortCircuit := errors.New("short-circuited")
+	tests := []struct {
+		name         string
+		shortCircuit bool
+		wantCalls    []string
+		wantErr      error
+	}{
+		{
+			name:      "ObservesRun",
+			wantCalls: []string{"outer", "inner", "run", "cleanup"},
+		},
+		{
+			name:         "ShortCircuitsRun",
+			shortCircuit: true,
+			wantCalls:    []string{"outer", "cleanup"},
+			wantErr:      errShortCircuit,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			checker := struct {
+				CheckerHandleRun
+				CheckerHandleCleanUp
+			}{
+				func(ctx context.Context, target string, opts string, s state.State) error {
+					calls = append(calls, "run")
+					return nil
+				},
+				func(ctx context.Context, target string, opts string) {
+					calls = append(calls, "cleanup")
+				},
+			}
+			recorder := func(name string, shortCircuit bool) Middleware {
+				return RunMiddleware(func(next CheckerHandleRun) CheckerHandleRun {
+					return func(ctx context.Context, target string, opts string, s state.State) error {
+						calls = append(calls, name)
+						if shortCircuit {
+							return errShortCircuit
+						}
+						return next(ctx, target, opts, s)
+					}
+				})
+			}
+			c := WithMiddleware(checker, recorder("outer", tt.shortCircuit), recorder("inner", false))
+			err := c.Run(context.Background(), "www.example.com", "", state.State{})
+			c.CleanUp(context.Background(), "www.example.com", "")
+			if err != tt.wantErr {
+				t.Errorf("want error %v, got %v", tt.wantErr, err)
+			}
+			if diff := cmp.Diff(tt.wantCalls, calls); diff != "" {
+				t.Errorf("calls differ, diff %s", diff)
+			}
+		})
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	run := CheckerHandleRun(func(ctx context.Context, target string, opts string, s state.State) error {
+		panic("boom")
+	})
+	checker := struct {
+		CheckerHandleRun
+		CheckerHandleCleanUp
+	}{run, VoidCheckerCleanUp}
+	err := WithMiddleware(checker, Recovery()).Run(context.Background(), "www.example.com", "", state.State{})
+	if err == nil || err.Error() != "checker panicked: boom" {
+		t.Errorf("want panic returned as error, got %v", err)
+	}
+}
+
+func TestTempDir(t *testing.T) {
+	var runDir, cleanUpDir string
+	checker := struct {
+		CheckerHandleRun
+		CheckerHandleCleanUp
+	}{
+		func(ctx context.Context, target string, opts string, s state.State) error {
+			runDir = state.TempDir(ctx)
+			if runDir == "" {
+				return errors.New("no temp dir")
+			}
+			if _, err := os.Stat(runDir); err != nil {
+				return err
+			}
+			return ioutil.WriteFile(filepath.Join(runDir, "output.xml"), []byte("<output/>"), 0600)
+		},
+		func(ctx context.Context, target string, opts string) {
+			cleanUpDir = state.TempDir(ctx)
+			if _, err := os.Stat(filepath.Join(cleanUpDir, "output.xml")); err != nil {
+				t.Errorf("want the temp dir contents available in CleanUp: %v", err)
+			}
+		},
+	}
+	c := WithMiddleware(checker, TempDir())
+	if err := c.Run(context.Background(), "www.example.com", "", state.State{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(runDir); err != nil {
+		t.Errorf("want the temp dir kept until CleanUp: %v", err)
+	}
+	c.CleanUp(context.Background(), "www.example.com", "")
+	if cleanUpDir != runDir {
+		t.Errorf("want the same temp dir in Run and CleanUp, got %q and %q", runDir, cleanUpDir)
+	}
+	if _, err := os.Stat(runDir); !os.IsNotExist(err) {
+		t.Errorf("want the temp dir removed after CleanUp, got %v", err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	conf := &config.Config{
+		Check: config.CheckConfig{
+			CheckID: "checkID",
+			Target:  "www.example.com",
+		},
+		Log: config.LogConfig{
+			LogFmt:   "text",
+			LogLevel: "debug",
+		},
+		CommMode: "push",
+	}
+	conf.AllowPrivate(true)
+	states := testagent.Record(conf)
+
+	var runs, cleanUps int
+	checker := struct {
+		CheckerHandleRun
+		CheckerHandleCleanUp
+	}{
+		func(ctx context.Context, target string, opts string, s state.State) error {
+			runs++
+			s.AddVulnerabilities(report.Vulnerability{Summary: "vuln"})
+			if runs == 1 {
+				return Retryable(errors.New("transient failure"))
+			}
+			return nil
+		},
+		func(ctx context.Context, target string, opts string) {
+			cleanUps++
+		},
+	}
+	o := &checkOptions{conf: conf}
+	WithRetryPolicy(RetryPolicy{MaxRetries: 2})(o)
+	l := logging.BuildRootLog("pushCheck")
+	c := push.NewCheckWithConfig("retry", WithMiddleware(checker, o.middlewares...), l, conf)
+	c.RunAndServe()
+	gotMsgs := states()
+
+	if runs != 2 {
+		t.Errorf("want 2 runs, got %d", runs)
+	}
+	// One clean up between the attempts and the one performed by the sdk.
+	if cleanUps != 2 {
+		t.Errorf("want 2 clean ups, got %d", cleanUps)
+	}
+	if len(gotMsgs) == 0 {
+		t.Fatal("no messages received")
+	}
+	last := gotMsgs[len(gotMsgs)-1]
+	if last.Status != agent.StatusFinished {
+		t.Errorf("want status %s, got %s, error %s", agent.StatusFinished, last.Status, last.Report.Error)
+	}
+	// The vulnerability added by the failed attempt is discarded.
+	if n := len(last.Report.Vulnerabilities); n != 1 {
+		t.Errorf("want 1 vulnerability, got %d", n)
+	}
+}
+
+func TestRetryAttemptState(t *testing.T) {
+	tests := []struct {
+		name           string
+		streamFindings bool
+		run            func(runs int, s state.State) error
+		wantRuns       int
+		wantStatus     string
+	}{
+		{
+			name: "ExplicitStatusDiscarded",
+			run: func(runs int, s state.State) error {
+				if runs > 1 {
+					return nil
+				}
+				if err := s.SetInconclusive("flaky target"); err != nil {
+					return err
+				}
+				return Retryable(errors.New("transient failure"))
+			},
+			wantRuns:   2,
+			wantStatus: agent.StatusFinished,
+		},
+		{
+			name:           "FindingsStreamed",
+			streamFindings: true,
+			run: func(runs int, s state.State) error {
+				s.AddVulnerabilities(report.Vulnerability{Summary: "vuln"})
+				return Retryable(errors.New("transient failure"))
+			},
+			wantRuns:   1,
+			wantStatus: agent.StatusFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := &config.Config{
+				Check: config.CheckConfig{
+					CheckID: "checkID",
+					Target:  "www.example.com",
+				},
+				Log: config.LogConfig{
+					LogFmt:   "text",
+					LogLevel: "debug",
+				},
+				CommMode:       "push",
+				StreamFindings: tt.streamFindings,
+			}
+			conf.AllowPrivate(true)
+			states := testagent.Record(conf)
+			var runs int
+			checker := struct {
+				CheckerHandleRun
+				CheckerHandleCleanUp
+			}{
+				func(ctx context.Context, target string, opts string, s state.State) error {
+					runs++
+					return tt.run(runs, s)
+				},
+				VoidCheckerCleanUp,
+			}
+			l := logging.BuildRootLog("pushCheck")
+			retry := Retry(RetryPolicy{MaxRetries: 2})
+			c := push.NewCheckWithConfig("retry", WithMiddleware(checker, retry), l, conf)
+			c.RunAndServe()
+			gotMsgs := states()
+
+			if runs != tt.wantRuns {
+				t.Errorf("want %d runs, got %d", tt.wantRuns, runs)
+			}
+			if len(gotMsgs) == 0 {
+				t.Fatal("no messages received")
+			}
+			last := gotMsgs[len(gotMsgs)-1]
+			if last.Status != tt.wantStatus {
+				t.Errorf("want status %s, got %s, error %s", tt.wantStatus, last.Status, last.Report.Error)
+			}
+			if last.Report.Notes != "" {
+				t.Errorf("want the notes of the failed attempts discarded, got %q", last.Report.Notes)
+			}
+		})
+	}
+}
+
+func TestRetryNotRetryable(t *testing.T) {
+	var runs int
+	run := CheckerHandleRun(func(ctx context.Context, target string, opts string, s state.State) error {
+		runs++
+		return errors.New("permanent failure")
+	})
+	checker := struct {
+		CheckerHandleRun
+		CheckerHandleCleanUp
+	}{run, VoidCheckerCleanUp}
+	c := WithMiddleware(checker, Retry(RetryPolicy{MaxRetries: 3}))
+	if err := c.Run(context.Background(), "www.example.com", "", state.State{}); err == nil {
+		t.Errorf("want error")
+	}
+	if runs != 1 {
+		t.Errorf("want 1 run, got %d", runs)
+	}
+}
@@ -0,0 +1,113 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/adevinta/vulcan-check-sdk/helpers"
+	"github.com/adevinta/vulcan-check-sdk/state"
+)
+
+// Binary defines an executable required by a check.
+type Binary struct {
+	// Name is the name of the executable, looked up in the PATH, or its path.
+	Name string
+	// MinVersion is the minimum version of the executable required, e.g.:
+	// "7.80". An empty value means any version is valid.
+	MinVersion string
+	// VersionArgs are the args passed to the executable to make it print its
+	// version. When empty "--version" is used.
+	VersionArgs []string
+	// VersionRegex extracts the version from the output of the executable
+	// run with the VersionArgs, in its first submatch. When nil the
+	// helpers.DefaultVersionRegex is used.
+	VersionRegex *regexp.Regexp
+}
+
+// Prerequisites defines what a check needs from the environment where it
+// runs, e.g.: the tools it wraps.
+type Prerequisites struct {
+	// Binaries contains the executables required by the check.
+	Binaries []Binary
+	// Root requires the check to run as root, e.g.: to perform raw socket
+	// scans.
+	Root bool
+}
+
+// geteuid returns the effective user id of the process, this allows to
+// replace it in tests.
+var geteuid = os.Geteuid
+
+// Check returns an error describing all the prerequisites that are not met.
+func (p Prerequisites) Check(ctx context.Context) error {
+	var unmet []string
+	if p.Root && geteuid() != 0 {
+		unmet = append(unmet, "the check must run as root")
+	}
+	for _, b := range p.Binaries {
+		if err := b.check(ctx); err != nil {
+			unmet = append(unmet, err.Error())
+		}
+	}
+	if len(unmet) == 0 {
+		return nil
+	}
+	return fmt.Errorf("unmet prerequisites: %s", strings.Join(unmet, "; "))
+}
+
+func (b Binary) check(ctx context.Context) error {
+	path, err := exec.LookPath(b.Name)
+	if err != nil {
+		return fmt.Errorf("binary %s not found", b.Name)
+	}
+	if b.MinVersion == "" {
+		return nil
+	}
+	args := b.VersionArgs
+	if len(args) == 0 {
+		args = []string{"--version"}
+	}
+	v, err := helpers.CommandVersion(ctx, path, args, b.VersionRegex)
+	if err != nil {
+		return err
+	}
+	return helpers.RequireVersion(b.Name, v, b.MinVersion)
+}
+
+// RequirePrerequisites returns a middleware that checks the given
+// prerequisites before the Run method of the checker is called. When any of
+// them is not met the checker is not run and the status of the check is set
+// to inconclusive with a message describing the unmet prerequisites. If the
+// status of the check can not be set to inconclusive, the message is returned
+// as an error.
+func RequirePrerequisites(p Prerequisites) Middleware {
+	return RunMiddleware(func(next CheckerHandleRun) CheckerHandleRun {
+		return func(ctx context.Context, target string, opts string, s state.State) error {
+			unmet := p.Check(ctx)
+			if unmet == nil {
+				return next(ctx, target, opts, s)
+			}
+			if err := s.SetInconclusive(unmet.Error()); err != nil {
+				if errors.Is(err, state.ErrStatusControlNotSupported) {
+					return unmet
+				}
+				return err
+			}
+			return nil
+		}
+	})
+}
+
+// WithPrerequisites makes the check verify the given prerequisites before
+// running the checker. See the RequirePrerequisites middleware for the
+// details.
+func WithPrerequisites(p Prerequisites) CheckOption {
+	return func(o *checkOptions) {
+		o.middlewares = append(o.middlewares, RequirePrerequisites(p))
+	}
+}
@@ -0,0 +1,135 @@
+package check
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adevinta/vulcan-check-sdk/agent"
+	"github.com/adevinta/vulcan-check-sdk/config"
+	"github.com/adevinta/vulcan-check-sdk/internal/logging"
+	"github.com/adevinta/vulcan-check-sdk/internal/push"
+	"github.com/adevinta/vulcan-check-sdk/internal/testagent"
+	"github.com/adevinta/vulcan-check-sdk/state"
+)
+
+// fakeBinary creates an executable script that prints the given output and
+// returns its path and a function that removes it.
+func fakeBinary(t *testing.T, name, output string) (string, func()) {
+	dir, err := ioutil.TempDir("", "fakebinary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(dir, name)
+	contents := "#!/bin/sh\necho '" + output + "'\n"
+	if err := ioutil.WriteFile(script, []byte(contents), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return script, func() {
+		os.RemoveAll(dir) // nolint
+	}
+}
+
+func TestPrerequisitesCheck(t *testing.T) {
+	tool, remove := fakeBinary(t, "tool", "tool version 1.2.3 (linux)")
+	defer remove()
+	tests := []struct {
+		name    string
+		p       Prerequisites
+		euid    int
+		wantErr string
+	}{
+		{
+			name: "Met",
+			p: Prerequisites{
+				Binaries: []Binary{{Name: tool, MinVersion: "1.2"}},
+				Root:     true,
+			},
+			euid: 0,
+		},
+		{
+			name: "VersionTooOld",
+			p: Prerequisites{
+				Binaries: []Binary{{Name: tool, MinVersion: "1.10"}},
+			},
+			wantErr: "unmet prerequisites: " + tool + " version 1.2.3 is installed but at least version 1.10 is required",
+		},
+		{
+			name: "MissingBinaryAndNotRoot",
+			p: Prerequisites{
+				Binaries: []Binary{{Name: "vulcan-nonexistent-tool"}},
+				Root:     true,
+			},
+			euid:    1000,
+			wantErr: "unmet prerequisites: the check must run as root; binary vulcan-nonexistent-tool not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev := geteuid
+			geteuid = func() int { return tt.euid }
+			defer func() { geteuid = prev }()
+			err := tt.p.Check(context.Background())
+			var got string
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.wantErr {
+				t.Errorf("want error %q, got %q", tt.wantErr, got)
+			}
+		})
+	}
+}
+
+func TestWithPrerequisites(t *testing.T) {
+	conf := &config.Config{
+		Check: config.CheckConfig{
+			CheckID: "checkID",
+			Target:  "www.example.com",
+		},
+		Log: config.LogConfig{
+			LogFmt:   "text",
+			LogLevel: "debug",
+		},
+		CommMode: "push",
+	}
+	conf.AllowPrivate(true)
+	states := testagent.Record(conf)
+
+	var run bool
+	checker := struct {
+		CheckerHandleRun
+		CheckerHandleCleanUp
+	}{
+		func(ctx context.Context, target string, opts string, s state.State) error {
+			run = true
+			return nil
+		},
+		VoidCheckerCleanUp,
+	}
+	o := &checkOptions{conf: conf}
+	WithPrerequisites(Prerequisites{
+		Binaries: []Binary{{Name: "vulcan-nonexistent-tool"}},
+	})(o)
+	l := logging.BuildRootLog("pushCheck")
+	c := push.NewCheckWithConfig("prerequisites", WithMiddleware(checker, o.middlewares...), l, conf)
+	c.RunAndServe()
+	gotMsgs := states()
+
+	if run {
+		t.Error("want the checker not run when the prerequisites are not met")
+	}
+	if len(gotMsgs) == 0 {
+		t.Fatal("no messages received")
+	}
+	last := gotMsgs[len(gotMsgs)-1]
+	if last.Status != agent.StatusInconclusive {
+		t.Errorf("want status %s, got %s, error %s", agent.StatusInconclusive, last.Status, last.Report.Error)
+	}
+	if !strings.Contains(last.Report.Notes, "binary vulcan-nonexistent-tool not found") {
+		t.Errorf("want the unmet prerequisite in the notes of the report, got %q", last.Report.Notes)
+	}
+}
@@ -4,14 +4,19 @@ import (
 	"bufio"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"runtime"
+	"syscall"
 	"time"
 
-	log "github.com/sirupsen/logrus"
+	"github.com/adevinta/vulcan-check-sdk/helpers/ratelimit"
 	"github.com/adevinta/vulcan-check-sdk/internal/logging"
+	"github.com/adevinta/vulcan-check-sdk/state"
+	log "github.com/sirupsen/logrus"
 )
 
 const (
@@ -51,10 +56,20 @@ type ProcessCheck struct {
 	cancel     context.CancelFunc
 	splitFunc  bufio.SplitFunc
 	logger     *log.Entry
+	limiter    *ratelimit.Limiter
 }
 
-// Run starts the execution of the process.
+// Run starts the execution of the process. The process is not launched until
+// the limit set with ratelimit.SetMaxProcesses allows it.
 func (p *ProcessCheck) Run(ctx context.Context) (pState *os.ProcessState, err error) {
+	if err = p.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	release, err := ratelimit.AcquireProcess(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 	childCtx, cancel := context.WithCancel(ctx)
 	p.cancel = cancel
 	p.logger.WithFields(log.Fields{"process_exec": p.executable, "process_params": p.args}).Info("Running process")
@@ -96,6 +111,56 @@ func (p *ProcessCheck) Run(ctx context.Context) (pState *os.ProcessState, err er
 	return p.cmd.ProcessState, err
 }
 
+// ResourceUsage returns the resources consumed by the process executed by the
+// last call to Run. It returns the zero value if the process has not finished.
+func (p *ProcessCheck) ResourceUsage() ResourceUsage {
+	if p.cmd == nil {
+		return ResourceUsage{}
+	}
+	return ProcessResourceUsage(p.cmd.ProcessState)
+}
+
+// ResourceUsage contains the resources consumed by a finished process.
+type ResourceUsage struct {
+	UserTime   time.Duration
+	SystemTime time.Duration
+	// MaxRSS is the maximum resident set size of the process in bytes, 0 if
+	// it is not reported by the OS.
+	MaxRSS int64
+}
+
+// String returns a human readable description of the resource usage.
+func (u ResourceUsage) String() string {
+	return fmt.Sprintf("user time %s, system time %s, max RSS %d bytes", u.UserTime, u.SystemTime, u.MaxRSS)
+}
+
+// ProcessResourceUsage returns the resources consumed by a process given the
+// state returned by Run when it finishes. A nil state returns the zero value.
+func ProcessResourceUsage(ps *os.ProcessState) ResourceUsage {
+	if ps == nil {
+		return ResourceUsage{}
+	}
+	u := ResourceUsage{
+		UserTime:   ps.UserTime(),
+		SystemTime: ps.SystemTime(),
+	}
+	if ru, ok := ps.SysUsage().(*syscall.Rusage); ok {
+		u.MaxRSS = int64(ru.Maxrss)
+		// Darwin reports the max RSS in bytes while Linux reports it in
+		// kilobytes.
+		if runtime.GOOS != "darwin" {
+			u.MaxRSS *= 1024
+		}
+	}
+	return u
+}
+
+// AddResourceUsageNote records the resources consumed by the process with the
+// given name, for instance: "nmap", in a line of the notes of the report.
+func AddResourceUsageNote(s state.State, name string, u ResourceUsage) {
+	s.AddNote(fmt.Sprintf("Process %s used %s", name, u))
+}
+
 func (p *ProcessCheck) readAndProcess(ctx context.Context, src *io.ReadCloser,
 	splitFunc bufio.SplitFunc, done chan interface{}) {
 	p.logger.Debug("Start readAndProcess")
@@ -140,6 +205,14 @@ func NewProcessChecker(executable string, args []string, split bufio.SplitFunc,
 	return p
 }
 
+// NewProcessCheckerWithLimiter creates a new ProcessChecker, in the same way
+// NewProcessChecker does, that waits for the given limiter before launching the process.
+func NewProcessCheckerWithLimiter(executable string, args []string, split bufio.SplitFunc, checker ProcessChecker, limiter *ratelimit.Limiter) ProcessCheckRunner {
+	p := NewProcessChecker(executable, args, split, checker).(*ProcessCheck)
+	p.limiter = limiter
+	return p
+}
+
 // WaitForFile  waits for a file to be created.
 func WaitForFile(filepath string) (*os.File, error) {
 	for {
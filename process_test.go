@@ -0,0 +1,68 @@
+package check
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adevinta/vulcan-check-sdk/helpers/ratelimit"
+	"github.com/adevinta/vulcan-check-sdk/state"
+	report "github.com/adevinta/vulcan-report"
+)
+
+func TestProcessResourceUsage(t *testing.T) {
+	// Busy loop long enough to consume a measurable amount of CPU time.
+	script := "i=0; while [ $i -lt 300000 ]; do i=$((i+1)); done"
+	noop := ProcessCheckerProcessOutputHandler(func([]byte) bool { return true })
+	p := NewProcessChecker("sh", []string{"-c", script}, nil, noop)
+	ps, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := ProcessResourceUsage(ps)
+	if u.UserTime <= 0 {
+		t.Errorf("want non zero user time, got %s", u.UserTime)
+	}
+	if got := p.(*ProcessCheck).ResourceUsage(); got != u {
+		t.Errorf("want the resource usage of the last run %+v, got %+v", u, got)
+	}
+
+	s := state.State{ResultData: &report.ResultData{Notes: "previous note"}}
+	AddResourceUsageNote(s, "sh", u)
+	if !strings.HasPrefix(s.Notes, "previous note\nProcess sh used user time ") {
+		t.Errorf("want the resource usage recorded in the notes, got %q", s.Notes)
+	}
+}
+
+func TestProcessCheckerMaxProcesses(t *testing.T) {
+	ratelimit.SetMaxProcesses(1)
+	defer ratelimit.SetMaxProcesses(0)
+	n := 3
+	// The checkers, and their loggers, are built before running them
+	// concurrently.
+	output := ProcessCheckerProcessOutputHandler(func([]byte) bool { return true })
+	var checkers []ProcessCheckRunner
+	for i := 0; i < n; i++ {
+		checkers = append(checkers, NewProcessChecker("sleep", []string{"0.1"}, nil, output))
+	}
+	var wg sync.WaitGroup
+	start := time.Now()
+	for _, p := range checkers {
+		wg.Add(1)
+		go func(p ProcessCheckRunner) {
+			defer wg.Done()
+			if _, err := p.Run(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}(p)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	// The processes run one after the other.
+	min := time.Duration(n) * 100 * time.Millisecond
+	if elapsed < min-10*time.Millisecond {
+		t.Errorf("want the processes to take at least %s, got %s", min, elapsed)
+	}
+}
@@ -1,15 +1,259 @@
 package state
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/adevinta/vulcan-check-sdk/agent"
 	"github.com/adevinta/vulcan-report"
 )
 
+// MetadataKey is the key of the JSON object stored in the Data field of the
+// report under which the metadata set by a checker is stored.
+const MetadataKey = "vulcan_metadata"
+
+// PhaseTimingsKey is the key of the JSON object stored in the Data field of
+// the report under which the durations of the phases measured with StartPhase
+// are stored.
+const PhaseTimingsKey = "vulcan_phase_timings"
+
+// ActiveCheckKey is the key of the JSON object stored in the Data field of the
+// report under which the sdk records whether the check is active, that is,
+// whether it performs probes that can modify the state of the targets.
+const ActiveCheckKey = "vulcan_active_check"
+
+// CoverageKey is the key of the JSON object stored in the Data field of the
+// report under which the coverage of the scan set with SetCoverage is stored.
+const CoverageKey = "vulcan_coverage"
+
+// WarningPrefix is the prefix of the lines of the notes of the report that
+// contain the warnings added by a checker.
+const WarningPrefix = "WARN: "
+
+// ErrDataNotJSONObject is returned when trying to set metadata in a report
+// whose Data field contains something different than a JSON object.
+var ErrDataNotJSONObject = errors.New("the data of the report is not a JSON object")
+
+// ErrStatusControlNotSupported is returned when trying to set the status of a
+// check that is run in a mode that doesn't allow the checker to do it, for
+// instance, when the check is run from the command line.
+var ErrStatusControlNotSupported = errors.New("the status of the check can not be set by the checker")
+
+// ErrInvalidStatusTransition is returned when trying to set a status that can
+// not follow the current status of the check.
+var ErrInvalidStatusTransition = errors.New("invalid status transition")
+
+type softAbortKey struct{}
+
+type tempDirKey struct{}
+
 // State defines the fields and function a check must use to generare a result
 // and inform about the progress of the its execution.
 // The type is not intended be instanciated by external packages, the instances to be used will be provided by the sdk.
 type State struct {
 	ProgressReporter
 	*report.ResultData
+	// FindingSink, when set by the sdk, receives each vulnerability added to
+	// the report as soon as it is added.
+	FindingSink FindingSink
+}
+
+// AddVulnerabilities adds the given vulnerabilities to the report and, if the
+// state has a FindingSink, sends each of them to it.
+func (s State) AddVulnerabilities(vulns ...report.Vulnerability) {
+	s.ResultData.AddVulnerabilities(vulns...)
+	if s.FindingSink == nil {
+		return
+	}
+	for _, v := range vulns {
+		s.FindingSink.AddFinding(v)
+	}
+}
+
+// Heartbeat signals that the check is still alive, even if its progress has
+// not changed, so the sdk can send the current state again to the agent to
+// prevent it from considering the check idle. The sdk limits the rate of the
+// messages sent, so it can be called frequently, e.g.: inside a loop of a long
+// phase.
+func (s State) Heartbeat() {
+	if h, ok := s.ProgressReporter.(Heartbeater); ok {
+		h.Heartbeat()
+	}
+}
+
+// SetInconclusive sets the status of the check to inconclusive, that is, the
+// checker could not determine whether the target is vulnerable, and records
+// the reason in the notes of the report. The status set by the checker is
+// final, the sdk doesn't override it when the Run method returns. The status is
+// sent to the agent immediately and, when the Run method returns, it's sent
+// again with the end time of the check and the vulnerabilities reported after
+// setting it, so the agent receives two states with the final status. It
+// returns ErrInvalidStatusTransition if the check is not running.
+func (s State) SetInconclusive(reason string) error {
+	c, ok := s.ProgressReporter.(StatusController)
+	if !ok {
+		return ErrStatusControlNotSupported
+	}
+	return c.SetInconclusive(reason)
+}
+
+// Finish sets the status of the check to finished before the Run method of the
+// checker returns, so the checker can, for instance, release resources without
+// delaying the report. As with SetInconclusive, the status is final, it's sent
+// again to the agent when the Run method returns, and it returns
+// ErrInvalidStatusTransition if the check is not running.
+func (s State) Finish() error {
+	c, ok := s.ProgressReporter.(StatusController)
+	if !ok {
+		return ErrStatusControlNotSupported
+	}
+	return c.Finish()
+}
+
+// SetMetadata adds the given key/value pairs to the metadata of the report,
+// overriding the values of the keys that were already set. The metadata is
+// stored in the Data field of the report, that must be empty or contain a JSON
+// object, under the key MetadataKey.
+func (s State) SetMetadata(metadata map[string]string) error {
+	current := map[string]string{}
+	return s.updateData(MetadataKey, &current, func() {
+		for k, v := range metadata {
+			current[k] = v
+		}
+	})
+}
+
+// Coverage contains how much of the scan intended by a checker got a response,
+// so operators can judge its completeness. The ports are counted per host,
+// that is, scanning 10 ports of 2 hosts means 20 intended ports.
+type Coverage struct {
+	IntendedHosts  int     `json:"intended_hosts"`
+	RespondedHosts int     `json:"responded_hosts"`
+	HostsPercent   float64 `json:"hosts_percent"`
+	IntendedPorts  int     `json:"intended_ports"`
+	RespondedPorts int     `json:"responded_ports"`
+	PortsPercent   float64 `json:"ports_percent"`
+}
+
+// NewCoverage returns the coverage of a scan given the number of hosts and
+// ports intended to be scanned and the number of them that responded. The
+// percentages are 0 when nothing was intended to be scanned.
+func NewCoverage(intendedHosts, respondedHosts, intendedPorts, respondedPorts int) Coverage {
+	return Coverage{
+		IntendedHosts:  intendedHosts,
+		RespondedHosts: respondedHosts,
+		HostsPercent:   percent(respondedHosts, intendedHosts),
+		IntendedPorts:  intendedPorts,
+		RespondedPorts: respondedPorts,
+		PortsPercent:   percent(respondedPorts, intendedPorts),
+	}
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
+
+// SetCoverage records the given coverage of the scan in the Data field of the
+// report, under the key CoverageKey, overriding the one recorded before, if
+// any.
+func (s State) SetCoverage(c Coverage) error {
+	var current Coverage
+	return s.updateData(CoverageKey, &current, func() {
+		current = c
+	})
+}
+
+// SetActiveCheck records in the Data field of the report, under the key
+// ActiveCheckKey, whether the check is active. It is intended to be used by the
+// sdk.
+func (s State) SetActiveCheck(active bool) error {
+	var current bool
+	return s.updateData(ActiveCheckKey, &current, func() {
+		current = active
+	})
+}
+
+// StartPhase starts measuring the time spent by the checker in the phase with
+// the given name, for instance: "discovery", and returns a function that must
+// be called when the phase finishes. The function records the elapsed time, in
+// seconds, in the Data field of the report under the key PhaseTimingsKey. If the
+// Data field contains something different than a JSON object the elapsed time
+// is recorded in the notes of the report.
+func (s State) StartPhase(name string) func() {
+	start := agent.Now()
+	return func() {
+		elapsed := agent.Now().Sub(start)
+		timings := map[string]float64{}
+		err := s.updateData(PhaseTimingsKey, &timings, func() {
+			timings[name] = elapsed.Seconds()
+		})
+		if err != nil {
+			s.AddNote(fmt.Sprintf("Phase %s took %s", name, elapsed))
+		}
+	}
+}
+
+// updateData reads the value stored under the given key of the JSON object in
+// the Data field of the report into value, calls update and stores value back
+// under the same key.
+func (s State) updateData(key string, value interface{}, update func()) error {
+	data := map[string]json.RawMessage{}
+	if len(s.Data) > 0 {
+		if err := json.Unmarshal(s.Data, &data); err != nil || data == nil {
+			return ErrDataNotJSONObject
+		}
+	}
+	if raw, ok := data[key]; ok {
+		if err := json.Unmarshal(raw, value); err != nil {
+			return err
+		}
+	}
+	update()
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	data[key] = raw
+	content, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	s.Data = content
+	return nil
+}
+
+// AddWarning records a non fatal issue found by the checker while running, for
+// instance, a port that timed out or a resource that was skipped. The warnings
+// are not vulnerabilities, they are stored as lines of the notes of the report
+// prefixed with WarningPrefix.
+func (s State) AddWarning(warning string) {
+	s.AddNote(WarningPrefix + strings.ReplaceAll(warning, "\n", " "))
+}
+
+// AddNote appends the given line to the notes of the report.
+func (s State) AddNote(line string) {
+	if s.Notes != "" && !strings.HasSuffix(s.Notes, "\n") {
+		s.Notes += "\n"
+	}
+	s.Notes += line
+}
+
+// Warnings returns the warnings stored in the notes of the given result.
+func Warnings(r *report.ResultData) []string {
+	var warnings []string
+	for _, line := range strings.Split(r.Notes, "\n") {
+		if strings.HasPrefix(line, WarningPrefix) {
+			warnings = append(warnings, strings.TrimPrefix(line, WarningPrefix))
+		}
+	}
+	return warnings
 }
 
 // ProgressReporter is intended to be used by the sdk.
@@ -26,3 +270,88 @@ type ProgressReporterHandler func(progress float32)
 func (p ProgressReporterHandler) SetProgress(progress float32) {
 	p(progress)
 }
+
+// Heartbeater is intended to be implemented by the ProgressReporters of the sdk
+// that can send the state of a check to the agent without a change in its
+// progress.
+type Heartbeater interface {
+	Heartbeat()
+}
+
+// StatusController is intended to be implemented by the ProgressReporters of
+// the sdk that allow the checkers to set the final status of a check.
+type StatusController interface {
+	SetInconclusive(reason string) error
+	Finish() error
+}
+
+// StatusResetter is intended to be implemented by the StatusControllers of
+// the sdk that allow to discard the final status set by a checker, e.g.: to
+// retry the checker after a failed attempt.
+type StatusResetter interface {
+	ResetStatus()
+}
+
+// FindingSink is intended to be used by the sdk to receive the vulnerabilities
+// of a check as they are found.
+type FindingSink interface {
+	AddFinding(v report.Vulnerability)
+}
+
+// FindingSinkHandler allows to define a FindingSink using a function instead
+// of a struct.
+type FindingSinkHandler func(v report.Vulnerability)
+
+// AddFinding implements the required FindingSink interface from a function.
+func (f FindingSinkHandler) AddFinding(v report.Vulnerability) {
+	f(v)
+}
+
+// CancelOnFinding returns a FindingSink that forwards the vulnerabilities to
+// the given sink, if any, and calls the given cancel function when it receives
+// the first one. The returned function tells if a vulnerability has been
+// received. It is intended to be used by the sdk.
+func CancelOnFinding(next FindingSink, cancel context.CancelFunc) (FindingSink, func() bool) {
+	var found int32
+	sink := FindingSinkHandler(func(v report.Vulnerability) {
+		if next != nil {
+			next.AddFinding(v)
+		}
+		if atomic.CompareAndSwapInt32(&found, 0, 1) {
+			cancel()
+		}
+	})
+	return sink, func() bool { return atomic.LoadInt32(&found) == 1 }
+}
+
+// ContextWithSoftAbort returns a copy of the parent context that carries the
+// given channel as the soft abort signal. It is intended to be used by the sdk.
+func ContextWithSoftAbort(parent context.Context, abort <-chan struct{}) context.Context {
+	return context.WithValue(parent, softAbortKey{}, abort)
+}
+
+// SoftAbort returns a channel that is closed when an abort of the check has
+// been requested. When that happens the checker still has the configured grace
+// period to, for instance, store partial results, before its context is
+// cancelled. If the context does not carry a soft abort signal the function
+// returns nil, so receiving from the channel blocks forever.
+func SoftAbort(ctx context.Context) <-chan struct{} {
+	abort, _ := ctx.Value(softAbortKey{}).(<-chan struct{}) // nolint
+	return abort
+}
+
+// ContextWithTempDir returns a copy of the parent context that carries the
+// path of the temporary directory of the run of a checker. It is intended to
+// be used by the sdk.
+func ContextWithTempDir(parent context.Context, dir string) context.Context {
+	return context.WithValue(parent, tempDirKey{}, dir)
+}
+
+// TempDir returns the path of the temporary directory created for the current
+// run of the checker by the TempDir middleware of the sdk. The directory is
+// removed after the CleanUp method of the checker returns. If the context does
+// not carry a temporary directory the function returns an empty string.
+func TempDir(ctx context.Context) string {
+	dir, _ := ctx.Value(tempDirKey{}).(string) // nolint
+	return dir
+}
@@ -0,0 +1,67 @@
+package state
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adevinta/vulcan-check-sdk/agent"
+	report "github.com/adevinta/vulcan-report"
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeClock is an agent.Clock that returns the time in now.
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+func TestStateStartPhase(t *testing.T) {
+	s := State{ResultData: &report.ResultData{}}
+	if err := s.SetMetadata(map[string]string{"env": "pro"}); err != nil {
+		t.Fatal(err)
+	}
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	agent.SetClock(clock)
+	defer agent.SetClock(nil)
+	stopDiscovery := s.StartPhase("discovery")
+	clock.now = clock.now.Add(20 * time.Second)
+	stopDiscovery()
+	stopScan := s.StartPhase("scan")
+	clock.now = clock.now.Add(40 * time.Second)
+	stopScan()
+
+	data := map[string]json.RawMessage{}
+	if err := json.Unmarshal(s.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := data[MetadataKey]; !ok {
+		t.Errorf("want the metadata preserved, got data %s", s.Data)
+	}
+	timings := map[string]float64{}
+	if err := json.Unmarshal(data[PhaseTimingsKey], &timings); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{
+		"discovery": 20,
+		"scan":      40,
+	}
+	if diff := cmp.Diff(want, timings); diff != "" {
+		t.Errorf("phase timings differ, diff %s", diff)
+	}
+}
+
+func TestStateStartPhaseNotJSONData(t *testing.T) {
+	s := State{ResultData: &report.ResultData{Data: []byte("raw data")}}
+	s.StartPhase("discovery")()
+	if string(s.Data) != "raw data" {
+		t.Errorf("want data unmodified, got %s", s.Data)
+	}
+	if !strings.HasPrefix(s.Notes, "Phase discovery took ") {
+		t.Errorf("want the timing recorded in the notes, got %q", s.Notes)
+	}
+}
@@ -0,0 +1,64 @@
+package tools
+
+import (
+	"context"
+	"errors"
+
+	"github.com/adevinta/vulcan-check-sdk/agent"
+	"github.com/adevinta/vulcan-check-sdk/config"
+	"github.com/adevinta/vulcan-check-sdk/internal/logging"
+	"github.com/adevinta/vulcan-check-sdk/internal/push"
+	"github.com/adevinta/vulcan-check-sdk/state"
+)
+
+// Checker defines the methods a checktype must implement to be run by
+// RunCheckForTest.
+type Checker interface {
+	Run(ctx context.Context, target string, opts string, state state.State) error
+	CleanUp(ctx context.Context, target string, opts string)
+}
+
+// ErrNoStates is returned by RunCheckForTest when the check did not send any
+// state to the agent.
+var ErrNoStates = errors.New("no states received from the check")
+
+// RunCheckForTest runs the given checker end-to-end in push mode against a
+// Reporter, using the given config, and returns all the states sent by the
+// check to the agent in the order they were received, the last one being the
+// final state of the check. The given config is not modified, the check is run
+// with a copy of it whose agent address is the address of the Reporter and
+// whose CheckID, if the config doesn't have one, is a new run ID. Should be
+// only used for test pourposes.
+func RunCheckForTest(checker Checker, conf *config.Config) ([]agent.State, error) {
+	if conf == nil {
+		return nil, errors.New("nil config")
+	}
+	runConf := *conf
+	conf = &runConf
+	// A checkID is needed for the test agent.
+	conf.EnsureCheckID()
+	r := NewReporter(conf.Check.CheckID)
+	conf.Push.AgentAddr = r.URL
+
+	states := []agent.State{}
+	done := make(chan struct{})
+	go func() {
+		for msg := range r.Msgs {
+			states = append(states, msg)
+		}
+		close(done)
+	}()
+
+	logger := logging.BuildRootLogWithNameAndConfig("check", conf, conf.Check.CheckTypeName)
+	c := push.NewCheckWithConfig(conf.Check.CheckTypeName, checker, logger, conf)
+	c.RunAndServe()
+	// RunAndServe only returns after all the pending messages are sent, thus
+	// is safe to stop the Reporter.
+	r.Stop()
+	<-done
+
+	if len(states) == 0 {
+		return nil, ErrNoStates
+	}
+	return states, nil
+}
@@ -0,0 +1,87 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/adevinta/vulcan-check-sdk/agent"
+	"github.com/adevinta/vulcan-check-sdk/config"
+	"github.com/adevinta/vulcan-check-sdk/state"
+	report "github.com/adevinta/vulcan-report"
+)
+
+type trivialChecker struct{}
+
+func (trivialChecker) Run(ctx context.Context, target string, opts string, s state.State) error {
+	s.AddVulnerabilities(report.Vulnerability{Summary: "Vulnerability in " + target})
+	return nil
+}
+
+func (trivialChecker) CleanUp(ctx context.Context, target string, opts string) {}
+
+func TestRunCheckForTest(t *testing.T) {
+	allowPrivateIPs := true
+	conf := &config.Config{
+		Check: config.CheckConfig{
+			CheckID:       "checkID",
+			CheckTypeName: "trivial",
+			Target:        "localhost",
+		},
+		Log: config.LogConfig{
+			LogFmt:   "text",
+			LogLevel: "error",
+		},
+		CommMode:        "push",
+		AllowPrivateIPs: &allowPrivateIPs,
+	}
+	conf.Push.BufferLen = 10
+
+	states, err := RunCheckForTest(trivialChecker{}, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := states[len(states)-1]
+	if last.Status != agent.StatusFinished {
+		t.Errorf("got final status %s, want %s", last.Status, agent.StatusFinished)
+	}
+	if len(last.Report.Vulnerabilities) != 1 {
+		t.Fatalf("got %d vulnerabilities, want 1", len(last.Report.Vulnerabilities))
+	}
+	if got, want := last.Report.Vulnerabilities[0].Summary, "Vulnerability in localhost"; got != want {
+		t.Errorf("got vulnerability summary %q, want %q", got, want)
+	}
+}
+
+func TestRunCheckForTestWithoutCheckID(t *testing.T) {
+	var ids []string
+	for i := 0; i < 2; i++ {
+		conf := &config.Config{
+			Check: config.CheckConfig{
+				CheckTypeName: "trivial",
+				Target:        "localhost",
+			},
+			Log: config.LogConfig{
+				LogFmt:   "text",
+				LogLevel: "error",
+			},
+			CommMode: "push",
+		}
+		conf.AllowPrivate(true)
+		conf.Push.BufferLen = 10
+		states, err := RunCheckForTest(trivialChecker{}, conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id := states[len(states)-1].Report.CheckID
+		if id == "" {
+			t.Fatal("got an empty check ID")
+		}
+		if conf.Check.CheckID != "" || conf.Push.AgentAddr != "" {
+			t.Errorf("got the config modified, check ID %q, agent address %q", conf.Check.CheckID, conf.Push.AgentAddr)
+		}
+		ids = append(ids, id)
+	}
+	if ids[0] == ids[1] {
+		t.Errorf("got the same check ID %s in two runs, want distinct IDs", ids[0])
+	}
+}